@@ -10,6 +10,7 @@ func main() {
 	b := bootstrap.NewBootstrap(
 		bootstrap.WithHttpMiddleware(
 			middleware.Recovery(),
+			middleware.RequestID(),
 			middleware.Logger(),
 			middleware.Cors(),
 		), // add http global middleware
@@ -13,6 +13,7 @@ const (
 	ServerHttp      ServerType = "http"
 	ServerWebsocket ServerType = "websocket"
 	ServerGrpc      ServerType = "grpc"
+	ServerWorker    ServerType = "worker"
 )
 
 // Server server interface
@@ -79,6 +80,7 @@ func (b *Bootstrap) registerProviders() {
 	b.app.Register(providers.NewCacheServiceProvider())
 	b.app.Register(providers.NewStorageServiceProvider())
 	b.app.Register(providers.NewQueueServiceProvider())
+	b.app.Register(providers.NewOutboxServiceProvider())
 }
 
 // createServer creates a server instance based on the server type
@@ -92,7 +94,9 @@ func createServer(cfg *config.Config) Server {
 	case ServerWebsocket:
 		panic("websocket server not implemented yet")
 	case ServerGrpc:
-		return newGrpc()
+		return newGrpc(grpcOptions(cfg)...)
+	case ServerWorker:
+		return newWorker()
 	default:
 		panic("unsupported server type")
 	}
@@ -148,8 +152,9 @@ func WithGrpcService(registerService RegisterGrpcService) Option {
 	})
 }
 
-// Run starts the server
+// Run starts the server and shuts the application down once it stops
 func (b *Bootstrap) Run() {
+	defer b.app.Shutdown()
 	b.server.Run(b.app)
 }
 
@@ -2,9 +2,14 @@ package bootstrap
 
 import (
 	"fmt"
+	"github.com/gin-generator/sugar/config"
 	"github.com/gin-generator/sugar/foundation"
+	"github.com/gin-generator/sugar/services/database"
 	"google.golang.org/grpc"
 	"net"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // RegisterGrpcService gRPC service registration function type
@@ -16,13 +21,32 @@ type Grpc struct {
 }
 
 // newGrpc creates a new Grpc server instance
-func newGrpc() *Grpc {
+func newGrpc(opts ...grpc.ServerOption) *Grpc {
 	return &Grpc{
-		Server: grpc.NewServer(),
+		Server: grpc.NewServer(opts...),
 	}
 }
 
-// Run starts the gRPC server
+// grpcOptions builds gRPC server options from the configuration
+func grpcOptions(cfg *config.Config) []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	// Resolve tenants from metadata
+	if tenant := cfg.Database.Tenant; tenant != nil && tenant.Resolver == "metadata" {
+		key := tenant.Header
+		if key == "" {
+			key = "x-tenant-id"
+		}
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(database.TenantUnaryInterceptor(key)),
+			grpc.ChainStreamInterceptor(database.TenantStreamInterceptor(key)),
+		)
+	}
+
+	return opts
+}
+
+// Run starts the gRPC server until SIGINT or SIGTERM, then drains in-flight RPCs
 func (g *Grpc) Run(app *foundation.Application) {
 	cfg := app.Config
 
@@ -36,8 +60,31 @@ func (g *Grpc) Run(app *foundation.Application) {
 		panic("Failed to listen: " + err.Error())
 	}
 
+	ctx, stop := signal.NotifyContext(app, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- g.Server.Serve(listener)
+	}()
+
 	fmt.Printf("%s gRPC server start: %s...\n", name, address)
-	if err = g.Server.Serve(listener); err != nil {
+	select {
+	case err = <-errs:
 		panic("Failed to serve: " + err.Error())
+	case <-ctx.Done():
+	}
+
+	// Drain in-flight RPCs, force the stop once the timeout passes
+	stopped := make(chan struct{})
+	go func() {
+		g.Server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		g.Server.Stop()
 	}
+	fmt.Printf("%s gRPC server stopped\n", name)
 }
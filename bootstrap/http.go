@@ -1,11 +1,19 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-generator/sugar/foundation"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout bounds how long in-flight requests are drained on shutdown
+const shutdownTimeout = 30 * time.Second
+
 // RegisterRouter
 /**
  * @description: router registration function type
@@ -33,7 +41,7 @@ func newHttp(env string) *Http {
 	}
 }
 
-// Run starts the HTTP server
+// Run starts the HTTP server until SIGINT or SIGTERM, then drains in-flight requests
 func (h *Http) Run(app *foundation.Application) {
 	cfg := app.Config
 
@@ -41,11 +49,32 @@ func (h *Http) Run(app *foundation.Application) {
 	host := cfg.App.Host
 	port := cfg.App.Port
 
+	ctx, stop := signal.NotifyContext(app, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", host, port),
+		Handler: h.Engine,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
 	fmt.Printf("%s serve start: %s:%d...\n", name, host, port)
-	err := h.Engine.Run(fmt.Sprintf("%s:%d", host, port))
-	if err != nil {
+	select {
+	case err := <-errs:
 		panic("Unable to start server, error: " + err.Error())
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("%s serve shutdown: %s\n", name, err)
 	}
+	fmt.Printf("%s serve stopped\n", name)
 }
 
 // Use add middleware
@@ -0,0 +1,39 @@
+package bootstrap
+
+import (
+	"fmt"
+	"github.com/gin-generator/sugar/foundation"
+	"github.com/gin-generator/sugar/providers"
+	"github.com/gin-generator/sugar/services/queue"
+	"os/signal"
+	"syscall"
+)
+
+// Worker queue worker server, runs the configured workers instead of serving requests
+type Worker struct{}
+
+// newWorker creates a new worker server instance
+func newWorker() *Worker {
+	return &Worker{}
+}
+
+// Run runs the workers until SIGINT or SIGTERM, then drains in-flight jobs
+func (w *Worker) Run(app *foundation.Application) {
+	cfg := app.Config
+
+	configs := []queue.WorkerConfig{{}}
+	if cfg.Queue != nil && len(cfg.Queue.Workers) > 0 {
+		configs = cfg.Queue.Workers
+	}
+
+	ctx, stop := signal.NotifyContext(app, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	manager := foundation.MustMake[*queue.Manager](app, providers.ServiceQueue)
+
+	fmt.Printf("%s worker start: %d worker(s)...\n", cfg.App.Name, len(configs))
+	if err := queue.RunWorkers(ctx, manager, configs); err != nil {
+		panic("Unable to start worker, error: " + err.Error())
+	}
+	fmt.Printf("%s worker stopped\n", cfg.App.Name)
+}
@@ -0,0 +1,43 @@
+// Command gen generates gorm models and type-safe query code from a configured connection.
+//
+// Run it from the application directory, next to etc/env.yaml:
+//
+//	cd app/demo && go run github.com/gin-generator/sugar/cmd/gen
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gin-generator/sugar/config"
+	"github.com/gin-generator/sugar/services/database"
+	"os"
+)
+
+func main() {
+	path := flag.String("path", "./etc", "configuration directory")
+	file := flag.String("file", "env.yaml", "configuration file name")
+	connection := flag.String("connection", "", "connection name, overrides database.gen.connection")
+	flag.Parse()
+
+	cfg := config.NewConfig(*file, *path)
+	if cfg.Database.Gen == nil {
+		fmt.Fprintln(os.Stderr, "database.gen is not configured")
+		os.Exit(1)
+	}
+
+	genCfg := *cfg.Database.Gen
+	if *connection != "" {
+		genCfg.Connection = *connection
+	}
+
+	db, err := database.OpenGenConnection(genCfg.Connection, cfg.Database.Mysql, cfg.Database.Pgsql)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err = database.Generate(db, genCfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
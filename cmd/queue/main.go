@@ -0,0 +1,51 @@
+// Command queue manages the jobs that ran out of attempts in the configured failed job store.
+//
+// Run it from the application directory, next to etc/env.yaml:
+//
+//	cd app/demo && go run github.com/gin-generator/sugar/cmd/queue failed list
+//	go run github.com/gin-generator/sugar/cmd/queue failed retry all
+//	go run github.com/gin-generator/sugar/cmd/queue failed purge -hours 168
+//
+// Retried jobs are pushed on the connections of the queue provider. Applications
+// adding connections in code call queue.RunFailedCommand from their own binary.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gin-generator/sugar/config"
+	"github.com/gin-generator/sugar/foundation"
+	"github.com/gin-generator/sugar/providers"
+	"github.com/gin-generator/sugar/services/queue"
+	"os"
+)
+
+func main() {
+	path := flag.String("path", "./etc", "configuration directory")
+	file := flag.String("file", "env.yaml", "configuration file name")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 || args[0] != "failed" {
+		fmt.Fprintln(os.Stderr, "usage: queue [-path dir] [-file name] failed list | retry <id>... | forget <id>... | purge")
+		os.Exit(2)
+	}
+
+	app := foundation.NewApplication()
+	app.Config = config.NewConfig(*file, *path)
+	app.Register(providers.NewLoggerServiceProvider())
+	app.Register(providers.NewDatabaseServiceProvider())
+	app.Register(providers.NewCacheServiceProvider())
+	app.Register(providers.NewQueueServiceProvider())
+	if err := app.Boot(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	manager := foundation.MustMake[*queue.Manager](app, providers.ServiceQueue)
+	if err := queue.RunFailedCommand(context.Background(), manager, args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"github.com/gin-generator/sugar/package/validator"
+	"github.com/gin-generator/sugar/services/cache"
 	"github.com/gin-generator/sugar/services/database"
 	"github.com/gin-generator/sugar/services/logger"
+	"github.com/gin-generator/sugar/services/outbox"
+	"github.com/gin-generator/sugar/services/queue"
 	"github.com/spf13/viper"
 	"path/filepath"
 	"strings"
@@ -22,7 +25,7 @@ const (
 // App application configuration
 type App struct {
 	Name   string `validate:"required"`
-	Server string `validate:"required,oneof=http grpc websocket"`
+	Server string `validate:"required,oneof=http grpc websocket worker"`
 	Host   string `validate:"required"`
 	Port   int    `validate:"required,gt=0,lte=65535"`
 	Env    Mode   `validate:"required,oneof=debug release test"`
@@ -30,15 +33,33 @@ type App struct {
 
 // Database database configuration for validation
 type Database struct {
-	Mysql map[string]database.MysqlConfig `validate:"omitempty,dive"`
-	Pgsql map[string]database.PgsqlConfig `validate:"omitempty,dive"`
+	Mysql  map[string]database.MysqlConfig `validate:"omitempty,dive"`
+	Pgsql  map[string]database.PgsqlConfig `validate:"omitempty,dive"`
+	Tenant *database.TenantConfig          `validate:"omitempty"`
+	Gen    *database.GenConfig             `validate:"omitempty"`
+}
+
+// Cache cache configuration for validation
+type Cache struct {
+	Drive       string                `validate:"omitempty,oneof=redis memory file database tiered"`
+	Serializer  string                `validate:"omitempty,oneof=json msgpack gob"`                                                           // typed values encoding, defaults to json
+	Serializers map[string]string     `validate:"omitempty,dive,keys,oneof=redis memory file database tiered,endkeys,oneof=json msgpack gob"` // per store overrides
+	Redis       *cache.RedisConfig    `validate:"required_if=Drive redis,omitempty"`
+	Memory      *cache.MemoryConfig   `validate:"omitempty"`
+	File        *cache.FileConfig     `validate:"required_if=Drive file,omitempty"`
+	Database    *cache.DatabaseConfig `validate:"required_if=Drive database,omitempty"`
+	Tiered      *cache.TieredConfig   `validate:"required_if=Drive tiered,omitempty"`
+	Metrics     bool                  `validate:"omitempty"` // decorate every store with cache.Instrumented
 }
 
 // Config configuration structure
 type Config struct {
-	App      App           `validate:"required"`
-	Logger   logger.Config `validate:"required"`
-	Database Database      `validate:"omitempty"`
+	App      App            `validate:"required"`
+	Logger   logger.Config  `validate:"required"`
+	Database Database       `validate:"omitempty"`
+	Cache    Cache          `validate:"omitempty"`
+	Outbox   *outbox.Config `validate:"omitempty"`
+	Queue    *queue.Config  `validate:"omitempty"`
 }
 
 // NewConfig creates and validates configuration from file
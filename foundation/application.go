@@ -10,7 +10,8 @@ import (
 // Application application container
 type Application struct {
 	context.Context
-	mu sync.RWMutex
+	cancel context.CancelFunc
+	mu     sync.RWMutex
 
 	// Service container
 	services map[string]any
@@ -21,14 +22,19 @@ type Application struct {
 	// Configuration (direct access)
 	Config *config.Config
 
+	// Callbacks run on shutdown
+	terminating []func()
+
 	// Whether the application has been booted
 	booted bool
 }
 
 // NewApplication creates a new application instance
 func NewApplication() *Application {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Application{
-		Context:   context.Background(),
+		Context:   ctx,
+		cancel:    cancel,
 		services:  make(map[string]any),
 		providers: make([]ServiceProvider, 0),
 		booted:    false,
@@ -57,6 +63,27 @@ func (app *Application) Boot() error {
 	return nil
 }
 
+// Terminating registers a callback run on shutdown, callbacks run in reverse order
+func (app *Application) Terminating(callback func()) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.terminating = append(app.terminating, callback)
+}
+
+// Shutdown cancels the application context and runs the terminating callbacks
+func (app *Application) Shutdown() {
+	app.cancel()
+
+	app.mu.Lock()
+	callbacks := app.terminating
+	app.terminating = nil
+	app.mu.Unlock()
+
+	for i := len(callbacks) - 1; i >= 0; i-- {
+		callbacks[i]()
+	}
+}
+
 // Bind binds a service to the container
 func (app *Application) Bind(name string, service any) {
 	app.mu.Lock()
@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-generator/sugar/services/cache"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStatusHeader header reporting whether a response was served from cache
+const CacheStatusHeader = "X-Cache"
+
+// ResponseCacheConfig response cache options
+type ResponseCacheConfig struct {
+	Store   string                        // cache store, the default store when empty
+	TTL     time.Duration                 // used when the response sets no max-age, defaults to a minute
+	Query   []string                      // query parameters part of the key, all of them when empty
+	Headers []string                      // request headers part of the key besides those named by Vary
+	Tags    func(c *gin.Context) []string // tags entries are written under, flush them with cache.Tags
+	Skip    func(c *gin.Context) bool     // bypasses the cache for a request
+	Prefix  string                        // key prefix, defaults to response:
+}
+
+// cachedResponse stored response
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Shared bool        `json:"shared"` // marked public or s-maxage, may be served to credentialed requests
+}
+
+// unstoredHeaders response headers describing a single exchange rather than the
+// resource, never stored or replayed. Access-Control-* headers are skipped too.
+var unstoredHeaders = map[string]bool{
+	CacheStatusHeader:     true,
+	RequestIDHeader:       true,
+	"Content-Length":      true,
+	"Date":                true,
+	"Set-Cookie":          true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// varyRecord stored under the base key, names the request headers a response varies on
+type varyRecord struct {
+	Vary []string `json:"vary"`
+}
+
+// responseRecorder captures the body written by the handlers
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes and captures data
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString writes and captures a string
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ResponseCache caches successful GET responses in a store of the manager. The
+// request Cache-Control no-cache and no-store directives bypass the cache, and
+// responses marked no-store, no-cache or private, setting cookies or varying on
+// * are never stored. Requests carrying Authorization or Cookie are personal,
+// they are only served and stored when the response is marked public or
+// s-maxage. Streamed responses are buffered, skip them.
+func ResponseCache(manager *cache.Manager, cfg ResponseCacheConfig) gin.HandlerFunc {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "response:"
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet || (cfg.Skip != nil && cfg.Skip(c)) {
+			c.Next()
+			return
+		}
+
+		name := cfg.Store
+		if name == "" {
+			name = manager.DefaultName()
+		}
+		store, err := manager.Store(name)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		if cfg.Tags != nil {
+			if tags := cfg.Tags(c); len(tags) > 0 {
+				if tagged, err := cache.NewTaggedCache(store, tags...); err == nil {
+					store = tagged
+				}
+			}
+		}
+
+		requestDirectives := parseCacheControl(c.GetHeader("Cache-Control"))
+		if _, ok := requestDirectives["no-store"]; ok {
+			c.Next()
+			return
+		}
+
+		base := cfg.Prefix + responseKey(c.Request, cfg.Query, cfg.Headers)
+		credentialed := c.GetHeader("Authorization") != "" || c.GetHeader("Cookie") != ""
+		if _, ok := requestDirectives["no-cache"]; !ok {
+			if response, ok := loadResponse(c, store, base); ok && (response.Shared || !credentialed) {
+				for name, values := range response.Header {
+					c.Writer.Header()[name] = values
+				}
+				c.Header(CacheStatusHeader, "HIT")
+				c.Data(response.Status, response.Header.Get("Content-Type"), response.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		// Headers set by earlier middleware, e.g. the request ID, belong to this exchange
+		before := c.Writer.Header().Clone()
+		c.Header(CacheStatusHeader, "MISS")
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		storeResponse(c, store, base, recorder, before, cfg.TTL, credentialed)
+	}
+}
+
+// loadResponse reads the cached response of a request
+func loadResponse(c *gin.Context, store cache.Cache, base string) (cachedResponse, bool) {
+	var response cachedResponse
+	ctx := c.Request.Context()
+
+	raw, err := store.Get(ctx, base)
+	if err != nil {
+		return response, false
+	}
+
+	var vary varyRecord
+	if err = json.Unmarshal([]byte(raw), &vary); err != nil {
+		return response, false
+	}
+	raw, err = store.Get(ctx, variantKey(base, c.Request, vary.Vary))
+	if err != nil {
+		return response, false
+	}
+
+	if err = json.Unmarshal([]byte(raw), &response); err != nil {
+		return response, false
+	}
+	return response, true
+}
+
+// storeResponse stores the recorded response when it is cacheable, with the
+// headers added or changed by the handlers since before
+func storeResponse(c *gin.Context, store cache.Cache, base string, recorder *responseRecorder, before http.Header, ttl time.Duration, credentialed bool) {
+	header := recorder.Header()
+	if recorder.Status() != http.StatusOK || header.Get("Set-Cookie") != "" ||
+		strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return
+		}
+	}
+
+	_, public := directives["public"]
+	_, sMaxAge := directives["s-maxage"]
+	shared := public || sMaxAge
+	if credentialed && !shared {
+		return
+	}
+
+	if maxAge, ok := directives["s-maxage"]; ok {
+		ttl = time.Duration(maxAge) * time.Second
+	} else if maxAge, ok = directives["max-age"]; ok {
+		ttl = time.Duration(maxAge) * time.Second
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	vary := varyHeaders(header)
+	for _, name := range vary {
+		if name == "*" {
+			return
+		}
+	}
+
+	stored := make(http.Header, len(header))
+	for name, values := range header {
+		if unstoredHeaders[name] || strings.HasPrefix(name, "Access-Control-") {
+			continue
+		}
+		if previous, ok := before[name]; ok && slices.Equal(previous, values) {
+			continue
+		}
+		stored[name] = values
+	}
+
+	data, err := json.Marshal(cachedResponse{Status: recorder.Status(), Header: stored, Body: recorder.body.Bytes(), Shared: shared})
+	if err != nil {
+		return
+	}
+	record, _ := json.Marshal(varyRecord{Vary: vary})
+
+	// The variant is written first so a vary record never names a variant that was not stored
+	ctx := c.Request.Context()
+	err = store.Set(ctx, variantKey(base, c.Request, vary), data, ttl)
+	if err == nil {
+		err = store.Set(ctx, base, record, ttl)
+	}
+	if err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// responseKey hashes the method, path and selected query parameters and headers
+func responseKey(r *http.Request, params, headers []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+
+	query := r.URL.Query()
+	if len(params) > 0 {
+		selected := make(url.Values, len(params))
+		for _, name := range params {
+			if values, ok := query[name]; ok {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+	b.WriteByte('?')
+	b.WriteString(encodeSorted(query))
+
+	for _, name := range headers {
+		b.WriteByte('\n')
+		b.WriteString(textproto.CanonicalMIMEHeaderKey(name))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	sum := sha1.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// variantKey extends the base key with the request values of the Vary headers
+func variantKey(base string, r *http.Request, vary []string) string {
+	if len(vary) == 0 {
+		return base + ":v"
+	}
+
+	var b strings.Builder
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte('\n')
+	}
+	sum := sha1.Sum([]byte(b.String()))
+	return base + ":v:" + hex.EncodeToString(sum[:])
+}
+
+// encodeSorted encodes query values with sorted keys and values
+func encodeSorted(values url.Values) string {
+	sorted := make(url.Values, len(values))
+	for key, list := range values {
+		list = append([]string(nil), list...)
+		sort.Strings(list)
+		sorted[key] = list
+	}
+	return sorted.Encode()
+}
+
+// varyHeaders returns the sorted canonical header names of the Vary response header
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseCacheControl parses Cache-Control directives, directives without a numeric value map to 0
+func parseCacheControl(value string) map[string]int {
+	directives := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(strings.Trim(arg, `"`))
+		directives[strings.ToLower(name)] = n
+	}
+	return directives
+}
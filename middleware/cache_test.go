@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-generator/sugar/services/cache"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// responseCacheRouter serves a counting handler behind the response cache
+func responseCacheRouter(t *testing.T, cfg ResponseCacheConfig, handler func(c *gin.Context, calls int)) (*gin.Engine, *cache.Manager) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := cache.NewMemoryStore(cache.MemoryConfig{})
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	manager := cache.NewManager()
+	manager.AddStore("memory", store)
+
+	calls := 0
+	router := gin.New()
+	router.Use(ResponseCache(manager, cfg))
+	router.Any("/*path", func(c *gin.Context) {
+		calls++
+		handler(c, calls)
+	})
+	return router, manager
+}
+
+// get sends a request and returns the X-Cache header and the body
+func get(router http.Handler, method, target string, header http.Header) (string, string) {
+	r := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w.Header().Get(CacheStatusHeader), w.Body.String()
+}
+
+// counting responds with the number of handler calls
+func counting(cacheControl string) func(c *gin.Context, calls int) {
+	return func(c *gin.Context, calls int) {
+		if cacheControl != "" {
+			c.Header("Cache-Control", cacheControl)
+		}
+		c.String(http.StatusOK, strconv.Itoa(calls))
+	}
+}
+
+func TestResponseCacheHitAndMiss(t *testing.T) {
+	router, _ := responseCacheRouter(t, ResponseCacheConfig{Query: []string{"page"}}, counting(""))
+
+	if status, body := get(router, "GET", "/items?page=1&utm=a", nil); status != "MISS" || body != "1" {
+		t.Fatalf("expected a miss, got %s %s", status, body)
+	}
+	if status, body := get(router, "GET", "/items?utm=b&page=1", nil); status != "HIT" || body != "1" {
+		t.Fatalf("expected a hit ignoring unselected parameters, got %s %s", status, body)
+	}
+	if status, body := get(router, "GET", "/items?page=2", nil); status != "MISS" || body != "2" {
+		t.Fatalf("expected another page to miss, got %s %s", status, body)
+	}
+	if status, body := get(router, "POST", "/items?page=1", nil); status != "" || body != "3" {
+		t.Fatalf("expected non-GET requests to bypass the cache, got %s %s", status, body)
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	router, _ := responseCacheRouter(t, ResponseCacheConfig{}, func(c *gin.Context, calls int) {
+		c.Header("Vary", "Accept-Language")
+		c.String(http.StatusOK, c.GetHeader("Accept-Language")+strconv.Itoa(calls))
+	})
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+
+	if _, body := get(router, "GET", "/greeting", en); body != "en1" {
+		t.Fatalf("expected en1, got %s", body)
+	}
+	if status, body := get(router, "GET", "/greeting", fr); status != "MISS" || body != "fr2" {
+		t.Fatalf("expected another variant to miss, got %s %s", status, body)
+	}
+	if status, body := get(router, "GET", "/greeting", fr); status != "HIT" || body != "fr2" {
+		t.Fatalf("expected the fr variant, got %s %s", status, body)
+	}
+	// The vary record now names the fr variant last, en is still served its own
+	if status, body := get(router, "GET", "/greeting", en); status != "HIT" || body != "en1" {
+		t.Fatalf("expected the en variant, got %s %s", status, body)
+	}
+}
+
+func TestResponseCacheBypass(t *testing.T) {
+	router, _ := responseCacheRouter(t, ResponseCacheConfig{}, counting(""))
+
+	get(router, "GET", "/a", nil)
+	if status, body := get(router, "GET", "/a", http.Header{"Cache-Control": {"no-cache"}}); status != "MISS" || body != "2" {
+		t.Fatalf("expected request no-cache to revalidate, got %s %s", status, body)
+	}
+	if status, body := get(router, "GET", "/a", nil); status != "HIT" || body != "2" {
+		t.Fatalf("expected the revalidated response to be stored, got %s %s", status, body)
+	}
+	if status, body := get(router, "GET", "/a", http.Header{"Cache-Control": {"no-store"}}); status != "" || body != "3" {
+		t.Fatalf("expected request no-store to bypass the cache, got %s %s", status, body)
+	}
+
+	for _, directive := range []string{"no-store", "no-cache", "private", "max-age=0"} {
+		router, _ := responseCacheRouter(t, ResponseCacheConfig{}, counting(directive))
+		get(router, "GET", "/a", nil)
+		if status, _ := get(router, "GET", "/a", nil); status != "MISS" {
+			t.Errorf("expected a response marked %s not to be stored, got %s", directive, status)
+		}
+	}
+
+	router, _ = responseCacheRouter(t, ResponseCacheConfig{}, func(c *gin.Context, calls int) {
+		c.SetCookie("session", "secret", 0, "/", "", false, true)
+		c.String(http.StatusOK, strconv.Itoa(calls))
+	})
+	get(router, "GET", "/a", nil)
+	if status, _ := get(router, "GET", "/a", nil); status != "MISS" {
+		t.Errorf("expected a response setting a cookie not to be stored, got %s", status)
+	}
+}
+
+func TestResponseCacheCredentials(t *testing.T) {
+	authorized := http.Header{"Authorization": {"Bearer alice"}}
+	cookie := http.Header{"Cookie": {"session=bob"}}
+
+	// Personal responses are neither stored nor served to credentialed requests
+	router, _ := responseCacheRouter(t, ResponseCacheConfig{}, counting(""))
+	get(router, "GET", "/me", authorized)
+	if status, body := get(router, "GET", "/me", cookie); status != "MISS" || body != "2" {
+		t.Fatalf("expected a credentialed response not to be shared, got %s %s", status, body)
+	}
+	get(router, "GET", "/me", nil)
+	if status, body := get(router, "GET", "/me", authorized); status != "MISS" || body != "4" {
+		t.Fatalf("expected an anonymous response not to be served to a credentialed request, got %s %s", status, body)
+	}
+	if status, body := get(router, "GET", "/me", nil); status != "HIT" || body != "3" {
+		t.Fatalf("expected the anonymous response to be kept, got %s %s", status, body)
+	}
+
+	// Responses marked public are shared with everyone
+	for _, directive := range []string{"public", "s-maxage=60"} {
+		router, _ := responseCacheRouter(t, ResponseCacheConfig{}, counting(directive))
+		get(router, "GET", "/news", authorized)
+		if status, body := get(router, "GET", "/news", cookie); status != "HIT" || body != "1" {
+			t.Errorf("expected a response marked %s to be shared, got %s %s", directive, status, body)
+		}
+		if status, _ := get(router, "GET", "/news", nil); status != "HIT" {
+			t.Errorf("expected a response marked %s to be served anonymously, got %s", directive, status)
+		}
+	}
+}
+
+func TestResponseCacheTags(t *testing.T) {
+	cfg := ResponseCacheConfig{
+		TTL: time.Hour,
+		Tags: func(c *gin.Context) []string {
+			return []string{"products"}
+		},
+	}
+	router, manager := responseCacheRouter(t, cfg, counting(""))
+
+	get(router, "GET", "/products/1", nil)
+	get(router, "GET", "/products/2", nil)
+	if status, _ := get(router, "GET", "/products/1", nil); status != "HIT" {
+		t.Fatalf("expected a hit, got %s", status)
+	}
+
+	if err := manager.Tags("memory", "products").Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"/products/1", "/products/2"} {
+		if status, _ := get(router, "GET", target, nil); status != "MISS" {
+			t.Errorf("expected %s to be flushed with its tag, got %s", target, status)
+		}
+	}
+}
+
+func TestResponseCacheStoresHandlerHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := cache.NewMemoryStore(cache.MemoryConfig{})
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	manager := cache.NewManager()
+	manager.AddStore("memory", store)
+
+	router := gin.New()
+	router.Use(RequestID(), func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", c.GetHeader("Origin"))
+		c.Next()
+	}, ResponseCache(manager, ResponseCacheConfig{}))
+	router.GET("/items", func(c *gin.Context) {
+		c.Header("X-Total", "2")
+		c.String(http.StatusOK, "items")
+	})
+
+	request := func(id, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/items", nil)
+		r.Header.Set(RequestIDHeader, id)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	request("req-1", "https://a.example.com")
+	w := request("req-2", "https://b.example.com")
+	if w.Header().Get(CacheStatusHeader) != "HIT" {
+		t.Fatalf("expected a hit, got %q", w.Header().Get(CacheStatusHeader))
+	}
+	if id := w.Header().Values(RequestIDHeader); len(id) != 1 || id[0] != "req-2" {
+		t.Errorf("expected the request ID of the hit, got %v", id)
+	}
+	if origin := w.Header().Values("Access-Control-Allow-Origin"); len(origin) != 1 || origin[0] != "https://b.example.com" {
+		t.Errorf("expected the CORS headers of the hit, got %v", origin)
+	}
+	if w.Header().Get("X-Total") != "2" || w.Body.String() != "items" {
+		t.Errorf("expected the handler headers and body to be replayed, got %v %q", w.Header(), w.Body.String())
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-generator/sugar/services/database"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Tenant resolves the tenant of the request and stores it in the request context
+func Tenant(resolver database.TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := resolver.Resolve(c.Request)
+		if err == nil {
+			err = database.ValidateTenant(tenant)
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.Set(database.TenantKey, tenant)
+		c.Request = c.Request.WithContext(database.WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
@@ -1,8 +1,11 @@
 package providers
 
 import (
+	"fmt"
 	"github.com/gin-generator/sugar/foundation"
 	"github.com/gin-generator/sugar/services/cache"
+	"github.com/gin-generator/sugar/services/database"
+	"time"
 )
 
 // CacheServiceProvider cache service provider
@@ -23,17 +26,118 @@ func (p *CacheServiceProvider) Register(app *foundation.Application) {
 func (p *CacheServiceProvider) Boot(app *foundation.Application) error {
 	manager := foundation.MustMake[*cache.Manager](app, ServiceCache)
 
-	// TODO: 从 app.Config 中获取 cache 配置
-	// 目前 Config 中没有定义 cache 配置，需要后续添加
-	// cfg := app.Config.(*config.Config)
-	// driver := cfg.Cache.Drive
-	// switch driver {
-	// case "redis":
-	// redisStore := cache.NewRedisStore(cfg.Cache.Redis)
-	// manager.AddStore("redis", redisStore)
-	// default:
-	// // Add other cache drivers like memcached
-	// }
+	cfg := app.Config.Cache
+
+	// Initialize Redis store
+	if cfg.Redis != nil {
+		redisCfg := *cfg.Redis
+		if redisCfg.Prefix == "" {
+			redisCfg.Prefix = app.Config.App.Name + ":"
+		}
+
+		store, err := cache.NewRedisStore(redisCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create redis cache store: %w", err)
+		}
+		manager.AddStore("redis", store)
+	}
+
+	// Initialize file store
+	if cfg.File != nil {
+		store, err := cache.NewFileStore(*cfg.File)
+		if err != nil {
+			return fmt.Errorf("failed to create file cache store: %w", err)
+		}
+		manager.AddStore("file", store)
+	}
+
+	// Initialize database store
+	if cfg.Database != nil {
+		db, err := foundation.MustMake[*database.Manager](app, ServiceDB).Connection(cfg.Database.Connection)
+		if err != nil {
+			return fmt.Errorf("failed to resolve database cache connection: %w", err)
+		}
+
+		store, err := cache.NewDatabaseStore(db, *cfg.Database)
+		if err != nil {
+			return fmt.Errorf("failed to create database cache store: %w", err)
+		}
+		manager.AddStore("database", store)
+	}
+
+	// Initialize memory store, also the fallback when nothing else is configured
+	if cfg.Memory != nil || cfg.Drive == "memory" || (cfg.Redis == nil && cfg.File == nil && cfg.Database == nil) {
+		memoryCfg := cache.MemoryConfig{}
+		if cfg.Memory != nil {
+			memoryCfg = *cfg.Memory
+		}
+		manager.AddStore("memory", cache.NewMemoryStore(memoryCfg))
+	}
+
+	// Decorate the stores with counters, metrics and hooks
+	if cfg.Metrics {
+		manager.Wrap(func(name string, store cache.Cache) cache.Cache {
+			return cache.NewInstrumented(name, store)
+		})
+	}
+
+	// Initialize tiered store, a private memory tier in front of a remote store,
+	// created after decoration so its remote traffic is instrumented too
+	if cfg.Tiered != nil {
+		remote, err := manager.Store(cfg.Tiered.Remote)
+		if err != nil {
+			return fmt.Errorf("failed to resolve tiered cache remote store: %w", err)
+		}
+
+		memoryCfg := cache.MemoryConfig{}
+		if cfg.Tiered.Memory != nil {
+			memoryCfg = *cfg.Tiered.Memory
+		}
+
+		// Invalidations are broadcast over the remote Redis, other remotes keep them local
+		var bus cache.InvalidationBus
+		redisStore, ok := remote.(*cache.RedisStore)
+		if instrumented, wrapped := remote.(*cache.Instrumented); wrapped {
+			redisStore, ok = instrumented.Unwrap().(*cache.RedisStore)
+		}
+		if ok {
+			channel := cfg.Tiered.Channel
+			if channel == "" {
+				channel = redisStore.Prefix() + "cache:invalidations"
+			}
+			bus = cache.NewRedisBus(redisStore.Client(), channel)
+		}
+
+		store, err := cache.NewTieredStore(cache.NewMemoryStore(memoryCfg), remote, bus, time.Duration(cfg.Tiered.LocalTTL)*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to create tiered cache store: %w", err)
+		}
+		if cfg.Metrics {
+			manager.AddStore("tiered", cache.NewInstrumented("tiered", store))
+		} else {
+			manager.AddStore("tiered", store)
+		}
+	}
+
+	// Select the serializer of each store
+	for _, name := range []string{"redis", "memory", "file", "database", "tiered"} {
+		serializerName := cfg.Serializer
+		if override, ok := cfg.Serializers[name]; ok {
+			serializerName = override
+		}
+
+		serializer, err := cache.NewSerializer(serializerName)
+		if err != nil {
+			return err
+		}
+		manager.SetSerializer(name, serializer)
+	}
+
+	if cfg.Drive != "" {
+		if err := manager.SetDefault(cfg.Drive); err != nil {
+			return err
+		}
+	}
 
 	// Set global Facade
 	cache.SetManager(manager)
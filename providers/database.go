@@ -2,8 +2,10 @@ package providers
 
 import (
 	"fmt"
+	"github.com/gin-generator/sugar/config"
 	"github.com/gin-generator/sugar/foundation"
 	"github.com/gin-generator/sugar/services/database"
+	"gorm.io/gorm"
 )
 
 // DatabaseServiceProvider database service provider
@@ -48,12 +50,55 @@ func (p *DatabaseServiceProvider) Boot(app *foundation.Application) error {
 		manager.AddConnection(name, db)
 	}
 
+	// Initialize multi-tenant routing
+	if cfg.Database.Tenant != nil {
+		opener, err := tenantOpener(cfg.Database, cfg.Database.Tenant)
+		if err != nil {
+			return err
+		}
+
+		tenants := database.NewTenantManager(manager, *cfg.Database.Tenant, opener)
+		database.SetTenantManager(tenants)
+		app.Bind(ServiceTenant, tenants)
+		app.Terminating(tenants.Close)
+	}
+
 	// Set global Facade
 	database.SetManager(manager)
 
 	return nil
 }
 
+// tenantOpener builds the opener of tenant connections from the base connection config
+func tenantOpener(cfg config.Database, tenantCfg *database.TenantConfig) (database.TenantOpener, error) {
+	if tenantCfg.Mode == database.TenantModeColumn {
+		return nil, nil
+	}
+
+	if mysqlCfg, ok := cfg.Mysql[tenantCfg.Connection]; ok {
+		// MySQL has no schemas apart from databases
+		return func(tenant, name string) (*gorm.DB, error) {
+			c := mysqlCfg
+			c.Database = name
+			return database.NewMysqlConnection(tenantCfg.Connection+"-"+tenant, c)
+		}, nil
+	}
+
+	if pgsqlCfg, ok := cfg.Pgsql[tenantCfg.Connection]; ok {
+		return func(tenant, name string) (*gorm.DB, error) {
+			c := pgsqlCfg
+			if tenantCfg.Mode == database.TenantModeSchema {
+				c.SearchPath = name
+			} else {
+				c.Database = name
+			}
+			return database.NewPgsqlConnection(tenantCfg.Connection+"-"+tenant, c)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("tenant base connection %s not found", tenantCfg.Connection)
+}
+
 // Name returns the service provider name
 func (p *DatabaseServiceProvider) Name() string {
 	return "Database"
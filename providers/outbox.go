@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-generator/sugar/foundation"
+	"github.com/gin-generator/sugar/services/database"
+	"github.com/gin-generator/sugar/services/outbox"
+	"github.com/gin-generator/sugar/services/queue"
+)
+
+// OutboxServiceProvider transactional outbox service provider
+type OutboxServiceProvider struct{}
+
+// NewOutboxServiceProvider creates an outbox service provider
+func NewOutboxServiceProvider() *OutboxServiceProvider {
+	return &OutboxServiceProvider{}
+}
+
+// Register registers the service
+func (p *OutboxServiceProvider) Register(app *foundation.Application) {
+	// Outbox relay is initialized in Boot phase
+}
+
+// Boot boots the service
+func (p *OutboxServiceProvider) Boot(app *foundation.Application) error {
+	cfg := app.Config.Outbox
+	if cfg == nil {
+		return nil
+	}
+
+	db, err := foundation.MustMake[*database.Manager](app, ServiceDB).Connection(cfg.Connection)
+	if err != nil {
+		return fmt.Errorf("outbox connection: %w", err)
+	}
+
+	if cfg.AutoMigrate {
+		if err = outbox.Migrate(db); err != nil {
+			return fmt.Errorf("failed to migrate outbox table: %w", err)
+		}
+	}
+
+	publisher := outbox.NewQueuePublisher(foundation.MustMake[*queue.Manager](app, ServiceQueue), cfg.Queue)
+	relay := outbox.NewRelay(db, publisher, *cfg)
+	app.Bind(ServiceOutbox, relay)
+
+	if cfg.Relay {
+		// Stop with the application, waiting for the batch in flight
+		ctx, cancel := context.WithCancel(app)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			relay.Run(ctx)
+		}()
+		app.Terminating(func() {
+			cancel()
+			<-done
+		})
+	}
+
+	return nil
+}
+
+// Name returns the service provider name
+func (p *OutboxServiceProvider) Name() string {
+	return "Outbox"
+}
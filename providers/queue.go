@@ -1,8 +1,14 @@
 package providers
 
 import (
+	"fmt"
 	"github.com/gin-generator/sugar/foundation"
+	"github.com/gin-generator/sugar/services/cache"
+	"github.com/gin-generator/sugar/services/database"
 	"github.com/gin-generator/sugar/services/queue"
+	"gorm.io/gorm"
+	"sort"
+	"time"
 )
 
 // QueueServiceProvider queue service provider
@@ -21,12 +27,76 @@ func (p *QueueServiceProvider) Register(app *foundation.Application) {
 
 // Boot boots the service
 func (p *QueueServiceProvider) Boot(app *foundation.Application) error {
-	_ = foundation.MustMake[*queue.Manager](app, ServiceQueue)
+	manager := foundation.MustMake[*queue.Manager](app, ServiceQueue)
 
-	// Initialize queue connections here, such as Redis, RabbitMQ, etc.
-	// Example:
-	// redisQueue := queue.NewRedisQueue(...)
-	// manager.AddConnection("redis", redisQueue)
+	// Initialize configured queue connections, applications may add more in their own providers
+	if cfg := app.Config.Queue; cfg != nil {
+		names := make([]string, 0, len(cfg.Connections))
+		for name := range cfg.Connections {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			connCfg := cfg.Connections[name]
+			if connCfg.Redis != nil && connCfg.Redis.Prefix == "" {
+				redisCfg := *connCfg.Redis
+				redisCfg.Prefix = app.Config.App.Name + ":"
+				connCfg.Redis = &redisCfg
+			}
+
+			var db *gorm.DB
+			if connCfg.Driver == "database" {
+				conn, err := foundation.MustMake[*database.Manager](app, ServiceDB).Connection(connCfg.Connection)
+				if err != nil {
+					return fmt.Errorf("queue connection %s: %w", name, err)
+				}
+				db = conn
+			}
+
+			conn, err := queue.NewConnection(db, connCfg)
+			if err != nil {
+				return fmt.Errorf("failed to create queue connection %s: %w", name, err)
+			}
+			manager.AddConnection(name, conn)
+		}
+
+		if cfg.Default != "" {
+			if err := manager.SetDefault(cfg.Default); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Initialize the failed job store
+	if cfg := app.Config.Queue; cfg != nil && cfg.Failed != nil {
+		var db *gorm.DB
+		if cfg.Failed.Driver == "database" {
+			conn, err := foundation.MustMake[*database.Manager](app, ServiceDB).Connection(cfg.Failed.Connection)
+			if err != nil {
+				return fmt.Errorf("failed job store: %w", err)
+			}
+			db = conn
+		}
+
+		store, err := queue.NewFailedStore(db, *cfg.Failed)
+		if err != nil {
+			return fmt.Errorf("failed job store: %w", err)
+		}
+		manager.SetFailedStore(store)
+	}
+
+	// Initialize the batch repository
+	if cfg := app.Config.Queue; cfg != nil && cfg.Batches != nil {
+		repository, err := batchRepository(app, *cfg.Batches)
+		if err != nil {
+			return fmt.Errorf("queue batch repository: %w", err)
+		}
+		manager.SetBatchRepository(repository)
+	}
+
+	// Set global Facade
+	queue.SetManager(manager)
 
 	return nil
 }
@@ -35,3 +105,28 @@ func (p *QueueServiceProvider) Boot(app *foundation.Application) error {
 func (p *QueueServiceProvider) Name() string {
 	return "Queue"
 }
+
+// batchRepository creates the configured batch repository
+func batchRepository(app *foundation.Application, cfg queue.BatchConfig) (queue.BatchRepository, error) {
+	if cfg.Driver == "database" {
+		db, err := foundation.MustMake[*database.Manager](app, ServiceDB).Connection(cfg.Connection)
+		if err != nil {
+			return nil, err
+		}
+		return queue.NewDatabaseBatchRepository(db, cfg.Table, cfg.AutoMigrate)
+	}
+
+	caches := foundation.MustMake[*cache.Manager](app, ServiceCache)
+	name := cfg.Store
+	if name == "" {
+		name = caches.DefaultName()
+	}
+	store, err := caches.Store(name)
+	if err != nil {
+		return nil, err
+	}
+	if !cache.Supports[cache.Locker](store) {
+		return nil, fmt.Errorf("cache store %s does not support locks, required by queue batches", name)
+	}
+	return queue.NewCacheBatchRepository(store, time.Duration(cfg.TTL)*time.Hour), nil
+}
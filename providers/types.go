@@ -4,7 +4,9 @@ package providers
 const (
 	ServiceLogger  = "logger"
 	ServiceDB      = "db"
+	ServiceTenant  = "tenant"
 	ServiceCache   = "cache"
 	ServiceStorage = "storage"
 	ServiceQueue   = "queue"
+	ServiceOutbox  = "outbox"
 )
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Admin key listing bounds
+const (
+	adminDefaultLimit = 100
+	adminMaxLimit     = 1000
+)
+
+// adminStore store summary returned by the admin API
+type adminStore struct {
+	Name    string      `json:"name"`
+	Default bool        `json:"default"`
+	Stats   interface{} `json:"stats,omitempty"`
+}
+
+// adminKey key details returned by the admin API
+type adminKey struct {
+	Key    string `json:"key"`
+	Exists bool   `json:"exists"`
+	TTL    *int64 `json:"ttl_ms,omitempty"` // omitted when the store does not report expirations, 0 never expires
+}
+
+// RegisterAdminRoutes registers an HTTP API to inspect the stores of a manager
+// on router. It exposes cached data and deletes entries, mount it on a group
+// behind authentication:
+//
+//	GET    /stores                      stores and their counters
+//	GET    /stores/:store/keys          keys, filtered by ?prefix= and bounded by ?limit=
+//	GET    /stores/:store/keys/*key     existence and ttl of a key
+//	DELETE /stores/:store/keys/*key     deletes a key
+func RegisterAdminRoutes(router gin.IRouter, m *Manager) {
+	router.GET("/stores", func(c *gin.Context) {
+		stores := make([]adminStore, 0)
+		for _, name := range m.Names() {
+			store, err := m.Store(name)
+			if err != nil {
+				continue
+			}
+			stores = append(stores, adminStore{
+				Name:    name,
+				Default: name == m.DefaultName(),
+				Stats:   storeStats(store),
+			})
+		}
+		c.JSON(http.StatusOK, stores)
+	})
+
+	router.GET("/stores/:store/keys", func(c *gin.Context) {
+		store, ok := adminResolve(c, m)
+		if !ok {
+			return
+		}
+		scanner, ok := unwrapStore(store).(Scanner)
+		if !ok {
+			adminError(c, http.StatusNotImplemented, "store does not support listing keys")
+			return
+		}
+
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit <= 0 {
+			limit = adminDefaultLimit
+		}
+		limit = min(limit, adminMaxLimit)
+
+		keys, err := scanner.Keys(c.Request.Context(), c.Query("prefix"), limit)
+		if err != nil {
+			adminError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, keys)
+	})
+
+	router.GET("/stores/:store/keys/*key", func(c *gin.Context) {
+		store, ok := adminResolve(c, m)
+		if !ok {
+			return
+		}
+
+		key := adminKey{Key: adminKeyParam(c)}
+		if expirer, ok := unwrapStore(store).(Expirer); ok {
+			remaining, err := expirer.TTL(c.Request.Context(), key.Key)
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				adminError(c, http.StatusInternalServerError, err.Error())
+				return
+			default:
+				key.Exists = true
+				ms := remaining.Milliseconds()
+				key.TTL = &ms
+			}
+		} else {
+			exists, err := store.Has(c.Request.Context(), key.Key)
+			if err != nil {
+				adminError(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			key.Exists = exists
+		}
+
+		status := http.StatusOK
+		if !key.Exists {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, key)
+	})
+
+	router.DELETE("/stores/:store/keys/*key", func(c *gin.Context) {
+		store, ok := adminResolve(c, m)
+		if !ok {
+			return
+		}
+		if err := store.Delete(c.Request.Context(), adminKeyParam(c)); err != nil {
+			adminError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// adminResolve resolves the store named in the path, writing a 404 when it is unknown
+func adminResolve(c *gin.Context, m *Manager) (Cache, bool) {
+	store, err := m.Store(c.Param("store"))
+	if err != nil {
+		adminError(c, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	return store, true
+}
+
+// adminKeyParam returns the key of the path, which may contain slashes
+func adminKeyParam(c *gin.Context) string {
+	return strings.TrimPrefix(c.Param("key"), "/")
+}
+
+// unwrapStore returns the store under instrumentation, whose capabilities are not forwarded blindly
+func unwrapStore(store Cache) Cache {
+	for {
+		instrumented, ok := store.(*Instrumented)
+		if !ok {
+			return store
+		}
+		store = instrumented.Unwrap()
+	}
+}
+
+// storeStats returns the counters a store exposes, nil when it has none
+func storeStats(store Cache) interface{} {
+	switch s := store.(type) {
+	case *Instrumented:
+		stats := map[string]interface{}{"operations": s.Stats()}
+		if inner := storeStats(s.Unwrap()); inner != nil {
+			stats["store"] = inner
+		}
+		return stats
+	case *MemoryStore:
+		return s.Stats()
+	case *TieredStore:
+		return storeStats(s.Local())
+	}
+	return nil
+}
+
+// adminError writes a JSON error response
+func adminError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"message": message})
+}
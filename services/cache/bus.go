@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-generator/sugar/services/logger"
+	"github.com/redis/go-redis/v9"
+	"sync"
+)
+
+// Invalidation tells other instances to drop local copies of keys, or all of them on Flush
+type Invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Flush  bool     `json:"flush,omitempty"`
+}
+
+// InvalidationBus broadcasts invalidations between instances
+type InvalidationBus interface {
+	Publish(ctx context.Context, invalidation Invalidation) error
+	// Subscribe delivers invalidations to handler until the bus is closed
+	Subscribe(ctx context.Context, handler func(Invalidation)) error
+	Close() error
+}
+
+// RedisBus invalidation bus over Redis pub/sub. Messages published while an
+// instance is disconnected are lost, so local copies need a short ttl.
+type RedisBus struct {
+	client  redis.UniversalClient
+	channel string
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+}
+
+// NewRedisBus creates a bus publishing on channel
+func NewRedisBus(client redis.UniversalClient, channel string) *RedisBus {
+	return &RedisBus{
+		client:  client,
+		channel: channel,
+	}
+}
+
+// Publish broadcasts an invalidation
+func (b *RedisBus) Publish(ctx context.Context, invalidation Invalidation) error {
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe starts delivering invalidations to handler in the background
+func (b *RedisBus) Subscribe(ctx context.Context, handler func(Invalidation)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	// Wait for the confirmation so no message published after Subscribe returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	b.mu.Lock()
+	b.pubsub = pubsub
+	b.mu.Unlock()
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var invalidation Invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+				logger.ReportError("cache invalidation decode failed", err)
+				continue
+			}
+			handler(invalidation)
+		}
+	}()
+	return nil
+}
+
+// Close stops the subscription
+func (b *RedisBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pubsub == nil {
+		return nil
+	}
+	err := b.pubsub.Close()
+	b.pubsub = nil
+	return err
+}
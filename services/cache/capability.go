@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Supports reports whether a store provides capability T. Decorators exposing
+// Unwrap, such as Instrumented, forward every capability, so the decorated store
+// must provide it too.
+func Supports[T any](store Cache) bool {
+	for {
+		if _, ok := store.(T); !ok {
+			return false
+		}
+		decorator, ok := store.(interface{ Unwrap() Cache })
+		if !ok {
+			return true
+		}
+		store = decorator.Unwrap()
+	}
+}
+
+// increment adds delta on a store implementing Counter
+func increment(ctx context.Context, store Cache, key string, delta int64) (int64, error) {
+	counter, ok := store.(Counter)
+	if !ok {
+		return 0, fmt.Errorf("cache store %T does not support counters", store)
+	}
+	if delta < 0 {
+		return counter.Decrement(ctx, key, -delta)
+	}
+	return counter.Increment(ctx, key, delta)
+}
+
+// add sets a value if absent on a store implementing Adder
+func add(ctx context.Context, store Cache, key string, value interface{}, expiration time.Duration) (bool, error) {
+	adder, ok := store.(Adder)
+	if !ok {
+		return false, fmt.Errorf("cache store %T does not support add", store)
+	}
+	return adder.Add(ctx, key, value, expiration)
+}
+
+// getMany reads several keys, one by one when the store is not a Batcher
+func getMany(ctx context.Context, store Cache, keys []string) (map[string]string, error) {
+	if batcher, ok := store.(Batcher); ok {
+		return batcher.GetMany(ctx, keys)
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, err := store.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// setMany writes several keys, one by one when the store is not a Batcher
+func setMany(ctx context.Context, store Cache, values map[string]interface{}, expiration time.Duration) error {
+	if batcher, ok := store.(Batcher); ok {
+		return batcher.SetMany(ctx, values, expiration)
+	}
+
+	for key, value := range values {
+		if err := store.Set(ctx, key, value, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMany removes several keys, one by one when the store is not a Batcher
+func deleteMany(ctx context.Context, store Cache, keys []string) error {
+	if batcher, ok := store.(Batcher); ok {
+		return batcher.DeleteMany(ctx, keys...)
+	}
+
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ttl reads the expiration of a key on a store implementing Expirer
+func ttl(ctx context.Context, store Cache, key string) (time.Duration, error) {
+	expirer, ok := store.(Expirer)
+	if !ok {
+		return 0, fmt.Errorf("cache store %T does not support ttl", store)
+	}
+	return expirer.TTL(ctx, key)
+}
+
+// touch resets the expiration of a key on a store implementing Expirer
+func touch(ctx context.Context, store Cache, key string, expiration time.Duration) (bool, error) {
+	expirer, ok := store.(Expirer)
+	if !ok {
+		return false, fmt.Errorf("cache store %T does not support ttl", store)
+	}
+	return expirer.Touch(ctx, key, expiration)
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math/rand/v2"
+	"time"
+)
+
+// DatabaseConfig database store configuration
+type DatabaseConfig struct {
+	Connection    string `validate:"required"`       // database connection name
+	Table         string `validate:"omitempty"`      // defaults to cache
+	LockTable     string `validate:"omitempty"`      // defaults to the table name followed by _locks
+	AutoMigrate   bool   `validate:"omitempty"`      // create the table on boot
+	GCProbability int    `validate:"omitempty,gt=0"` // a write deletes expired rows with probability 1/GCProbability, defaults to 1000
+}
+
+// cacheRecord cache table row
+type cacheRecord struct {
+	Key        string `gorm:"primaryKey;size:191"`
+	Value      []byte
+	Expiration int64 `gorm:"not null;default:0;index"` // unix milliseconds, 0 never expires
+}
+
+// lockRecord lock table row
+type lockRecord struct {
+	Key        string `gorm:"primaryKey;size:191"`
+	Owner      string `gorm:"size:64;not null"`
+	Expiration int64  `gorm:"not null;default:0"` // unix milliseconds, 0 never expires
+}
+
+// DatabaseStore database cache store backed by a table
+type DatabaseStore struct {
+	db            *gorm.DB
+	table         string
+	lockTable     string
+	gcProbability int
+}
+
+// NewDatabaseStore creates a database store on a connection
+func NewDatabaseStore(db *gorm.DB, cfg DatabaseConfig) (*DatabaseStore, error) {
+	d := &DatabaseStore{
+		db:            db,
+		table:         cfg.Table,
+		lockTable:     cfg.LockTable,
+		gcProbability: cfg.GCProbability,
+	}
+	if d.table == "" {
+		d.table = "cache"
+	}
+	if d.lockTable == "" {
+		d.lockTable = d.table + "_locks"
+	}
+	if d.gcProbability <= 0 {
+		d.gcProbability = 1000
+	}
+
+	if cfg.AutoMigrate {
+		if err := d.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Migrate creates or updates the cache and lock tables
+func (d *DatabaseStore) Migrate() error {
+	if err := d.db.Table(d.table).AutoMigrate(&cacheRecord{}); err != nil {
+		return err
+	}
+	return d.db.Table(d.lockTable).AutoMigrate(&lockRecord{})
+}
+
+// query starts a query on the cache table
+func (d *DatabaseStore) query(ctx context.Context) *gorm.DB {
+	return d.db.WithContext(ctx).Table(d.table)
+}
+
+// notExpired restricts a query to entries that have not expired
+func notExpired(db *gorm.DB) *gorm.DB {
+	return db.Where("expiration = 0 OR expiration > ?", time.Now().UnixMilli())
+}
+
+// keyIs matches a key, quoted for the dialect since key is reserved in MySQL
+func keyIs(key string) clause.Expression {
+	return clause.Eq{Column: clause.Column{Name: "key"}, Value: key}
+}
+
+// Get retrieves a value from cache, a missing key returns ErrNotFound
+func (d *DatabaseStore) Get(ctx context.Context, key string) (string, error) {
+	var record cacheRecord
+	err := d.query(ctx).Scopes(notExpired).Where(keyIs(key)).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(record.Value), nil
+}
+
+// Set stores a value in cache, a zero expiration never expires
+func (d *DatabaseStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	str, err := toString(value)
+	if err != nil {
+		return err
+	}
+
+	record := cacheRecord{Key: key, Value: []byte(str)}
+	if expiration > 0 {
+		record.Expiration = time.Now().Add(expiration).UnixMilli()
+	}
+
+	err = d.query(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expiration"}),
+	}).Create(&record).Error
+	if err != nil {
+		return err
+	}
+
+	if rand.IntN(d.gcProbability) == 0 {
+		go func() {
+			_, _ = d.GC(context.Background())
+		}()
+	}
+	return nil
+}
+
+// Delete removes a value from cache
+func (d *DatabaseStore) Delete(ctx context.Context, key string) error {
+	return d.query(ctx).Where(keyIs(key)).Delete(&cacheRecord{}).Error
+}
+
+// Has checks if a key exists in cache
+func (d *DatabaseStore) Has(ctx context.Context, key string) (bool, error) {
+	var count int64
+	err := d.query(ctx).Scopes(notExpired).Where(keyIs(key)).Count(&count).Error
+	return count > 0, err
+}
+
+// Flush clears all cache
+func (d *DatabaseStore) Flush(ctx context.Context) error {
+	return d.query(ctx).Where("1 = 1").Delete(&cacheRecord{}).Error
+}
+
+// GC deletes expired rows
+func (d *DatabaseStore) GC(ctx context.Context) (int64, error) {
+	tx := d.query(ctx).
+		Where("expiration > 0 AND expiration <= ?", time.Now().UnixMilli()).
+		Delete(&cacheRecord{})
+	return tx.RowsAffected, tx.Error
+}
+
+// locks starts a query on the lock table
+func (d *DatabaseStore) locks(ctx context.Context) *gorm.DB {
+	return d.db.WithContext(ctx).Table(d.lockTable)
+}
+
+// AcquireLock inserts the lock row, or takes over the row of an expired lock
+func (d *DatabaseStore) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	record := lockRecord{Key: name, Owner: owner}
+	if ttl > 0 {
+		record.Expiration = now.Add(ttl).UnixMilli()
+	}
+
+	tx := d.locks(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		return true, nil
+	}
+
+	tx = d.locks(ctx).
+		Where(keyIs(name)).
+		Where("expiration > 0 AND expiration <= ?", now.UnixMilli()).
+		Updates(map[string]interface{}{"owner": owner, "expiration": record.Expiration})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// ReleaseLock releases a lock held by owner
+func (d *DatabaseStore) ReleaseLock(ctx context.Context, name, owner string) (bool, error) {
+	tx := d.locks(ctx).Scopes(notExpired).Where(keyIs(name)).Where("owner = ?", owner).Delete(&lockRecord{})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// ExtendLock resets the ttl of a lock held by owner
+func (d *DatabaseStore) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	tx := d.locks(ctx).
+		Scopes(notExpired).
+		Where(keyIs(name)).
+		Where("owner = ?", owner).
+		Update("expiration", time.Now().Add(ttl).UnixMilli())
+	if tx.Error != nil || tx.RowsAffected > 0 {
+		return tx.RowsAffected > 0, tx.Error
+	}
+
+	// MySQL reports changed rows only, an extension within the same millisecond changes nothing
+	var count int64
+	err := d.locks(ctx).Scopes(notExpired).Where(keyIs(name)).Where("owner = ?", owner).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openDatabaseStore opens a migrated database store on a temporary SQLite database
+func openDatabaseStore(t *testing.T) *DatabaseStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cache.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewDatabaseStore(db, DatabaseConfig{AutoMigrate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestDatabaseStore(t *testing.T) {
+	ctx := context.Background()
+	store := openDatabaseStore(t)
+
+	if err := store.Set(ctx, "foo", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, "foo", 43, 0); err != nil {
+		t.Fatalf("expected an existing key to be overwritten, got %v", err)
+	}
+	if value, _ := store.Get(ctx, "foo"); value != "43" {
+		t.Fatalf("expected 43, got %q", value)
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	_ = store.Set(ctx, "short", "lived", 10*time.Millisecond)
+	if ok, _ := store.Has(ctx, "short"); !ok {
+		t.Fatal("expected the entry to exist before it expires")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := store.Has(ctx, "short"); ok {
+		t.Error("expected the entry to expire")
+	}
+	if _, err := store.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an expired entry to be missing, got %v", err)
+	}
+
+	if n, err := store.GC(ctx); err != nil || n != 1 {
+		t.Errorf("expected GC to delete the expired row, got %d, %v", n, err)
+	}
+
+	_ = store.Delete(ctx, "foo")
+	if ok, _ := store.Has(ctx, "foo"); ok {
+		t.Error("expected the entry to be deleted")
+	}
+
+	_ = store.Set(ctx, "bar", "baz", 0)
+	if err := store.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Has(ctx, "bar"); ok {
+		t.Error("expected flush to remove every entry")
+	}
+}
@@ -57,3 +57,83 @@ func Store(name string) (Cache, error) {
 	}
 	return manager.Store(name)
 }
+
+// Increment atomically adds delta to a counter (Facade pattern)
+func Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	store, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return increment(ctx, store, key, delta)
+}
+
+// Decrement atomically subtracts delta from a counter (Facade pattern)
+func Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	store, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return increment(ctx, store, key, -delta)
+}
+
+// Add stores a value only when the key is absent, reporting whether it was stored (Facade pattern)
+func Add(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	store, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return add(ctx, store, key, value, expiration)
+}
+
+// GetMany retrieves several values, missing keys are omitted (Facade pattern)
+func GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	store, err := defaultStore()
+	if err != nil {
+		return nil, err
+	}
+	return getMany(ctx, store, keys)
+}
+
+// SetMany stores several values with the same expiration (Facade pattern)
+func SetMany(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	store, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return setMany(ctx, store, values, expiration)
+}
+
+// DeleteMany removes several values (Facade pattern)
+func DeleteMany(ctx context.Context, keys ...string) error {
+	store, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return deleteMany(ctx, store, keys)
+}
+
+// TTL returns the remaining time to live of a key, 0 when it never expires (Facade pattern)
+func TTL(ctx context.Context, key string) (time.Duration, error) {
+	store, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return ttl(ctx, store, key)
+}
+
+// Touch resets the expiration of a key, reporting whether it exists (Facade pattern)
+func Touch(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	store, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return touch(ctx, store, key, expiration)
+}
+
+// defaultStore returns the default store of the global manager
+func defaultStore() (Cache, error) {
+	if manager == nil {
+		return nil, fmt.Errorf("cache manager not initialized")
+	}
+	return manager.Cache()
+}
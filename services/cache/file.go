@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileConfig file store configuration
+type FileConfig struct {
+	Root          string `validate:"required"`       // dedicated directory, Flush empties it
+	GCProbability int    `validate:"omitempty,gt=0"` // a write sweeps expired entries with probability 1/GCProbability, defaults to 1000
+}
+
+// fileHeader metadata line written before the value
+type fileHeader struct {
+	Key       string `json:"key"`
+	ExpiresAt int64  `json:"expires_at"` // unix milliseconds, 0 never expires
+}
+
+// expired checks if the entry is expired at now
+func (h fileHeader) expired(now time.Time) bool {
+	return h.ExpiresAt > 0 && now.UnixMilli() > h.ExpiresAt
+}
+
+// FileStore filesystem cache store. Entries are sharded in two directory
+// levels by the hash of their key and written atomically through a rename.
+type FileStore struct {
+	root          string
+	gcProbability int
+	collecting    atomic.Bool
+	// locks serialize renames and expiry removals of the same entry, striped by hash
+	locks [256]sync.Mutex
+}
+
+// NewFileStore creates a file store
+func NewFileStore(cfg FileConfig) (*FileStore, error) {
+	if err := os.MkdirAll(cfg.Root, 0755); err != nil {
+		return nil, err
+	}
+
+	gcProbability := cfg.GCProbability
+	if gcProbability <= 0 {
+		gcProbability = 1000
+	}
+
+	return &FileStore{
+		root:          cfg.Root,
+		gcProbability: gcProbability,
+	}, nil
+}
+
+// path returns the file of a key
+func (f *FileStore) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	return filepath.Join(f.root, hash[0:2], hash[2:4], hash)
+}
+
+// lock returns the mutex of an entry file
+func (f *FileStore) lock(path string) *sync.Mutex {
+	stripe, err := hex.DecodeString(filepath.Base(path)[:2])
+	if err != nil {
+		return &f.locks[0]
+	}
+	return &f.locks[stripe[0]]
+}
+
+// removeExpired removes an entry file if it is still expired. A concurrent Set
+// may have replaced it since it was read, so it is checked again under the lock.
+func (f *FileStore) removeExpired(path string, now time.Time) {
+	mu := f.lock(path)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if header, _, err := f.read(path); err == nil && header.expired(now) {
+		_ = os.Remove(path)
+	}
+}
+
+// read reads the header and value of a file
+func (f *FileStore) read(path string) (fileHeader, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileHeader{}, nil, err
+	}
+
+	line, value, _ := bytes.Cut(data, []byte("\n"))
+	var header fileHeader
+	if err = json.Unmarshal(line, &header); err != nil {
+		return fileHeader{}, nil, err
+	}
+	return header, value, nil
+}
+
+// load reads a live entry, removing it when expired
+func (f *FileStore) load(key string) ([]byte, bool, error) {
+	path := f.path(key)
+
+	header, value, err := f.read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if now := time.Now(); header.expired(now) {
+		f.removeExpired(path, now)
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// Get retrieves a value from cache, a missing key returns ErrNotFound
+func (f *FileStore) Get(ctx context.Context, key string) (string, error) {
+	value, ok, err := f.load(key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrNotFound
+	}
+	return string(value), nil
+}
+
+// Set stores a value in cache, a zero expiration never expires
+func (f *FileStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	str, err := toString(value)
+	if err != nil {
+		return err
+	}
+
+	header := fileHeader{Key: key}
+	if expiration > 0 {
+		header.ExpiresAt = time.Now().Add(expiration).UnixMilli()
+	}
+
+	if err = f.write(f.path(key), header, []byte(str)); err != nil {
+		return err
+	}
+
+	if rand.IntN(f.gcProbability) == 0 {
+		go f.GC()
+	}
+	return nil
+}
+
+// write writes an entry to a temporary file and renames it into place
+func (f *FileStore) write(path string, header fileHeader, value []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	_, _ = w.Write(line)
+	_ = w.WriteByte('\n')
+	_, _ = w.Write(value)
+	if err = w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	mu := f.lock(path)
+	mu.Lock()
+	defer mu.Unlock()
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes a value from cache
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Has checks if a key exists in cache
+func (f *FileStore) Has(ctx context.Context, key string) (bool, error) {
+	_, ok, err := f.load(key)
+	return ok, err
+}
+
+// Flush clears all cache
+func (f *FileStore) Flush(ctx context.Context) error {
+	entries, err := os.ReadDir(f.root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err = os.RemoveAll(filepath.Join(f.root, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GC removes expired entries and stale temporary files, concurrent calls are skipped
+func (f *FileStore) GC() {
+	if !f.collecting.CompareAndSwap(false, true) {
+		return
+	}
+	defer f.collecting.Store(false)
+
+	now := time.Now()
+	_ = filepath.WalkDir(f.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		// Leftovers of interrupted writes
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			if info, err := d.Info(); err == nil && now.Sub(info.ModTime()) > time.Hour {
+				_ = os.Remove(path)
+			}
+			return nil
+		}
+
+		if header, _, err := f.read(path); err == nil && header.expired(now) {
+			f.removeExpired(path, now)
+		}
+		return nil
+	})
+}
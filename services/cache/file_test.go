@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(FileConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Set(ctx, "foo", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := store.Get(ctx, "foo"); value != "42" {
+		t.Fatalf("expected 42, got %q", value)
+	}
+	if _, err = os.Stat(store.path("foo")); err != nil {
+		t.Fatalf("expected the entry in its shard directory, got %v", err)
+	}
+	if _, err = store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// Values may contain the header separator
+	_ = store.Set(ctx, "lines", "a\nb", 0)
+	if value, _ := store.Get(ctx, "lines"); value != "a\nb" {
+		t.Errorf("expected multi-line value, got %q", value)
+	}
+
+	_ = store.Set(ctx, "short", "lived", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := store.Has(ctx, "short"); ok {
+		t.Error("expected the entry to expire")
+	}
+	if _, err = os.Stat(store.path("short")); !os.IsNotExist(err) {
+		t.Error("expected the expired entry to be removed on read")
+	}
+
+	_ = store.Delete(ctx, "foo")
+	if ok, _ := store.Has(ctx, "foo"); ok {
+		t.Error("expected the entry to be deleted")
+	}
+	if err = store.Delete(ctx, "foo"); err != nil {
+		t.Errorf("expected deleting a missing key to succeed, got %v", err)
+	}
+
+	if err = store.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Has(ctx, "lines"); ok {
+		t.Error("expected flush to remove every entry")
+	}
+}
+
+func TestFileStoreGC(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileStore(FileConfig{Root: t.TempDir()})
+
+	_ = store.Set(ctx, "expired", "x", time.Millisecond)
+	_ = store.Set(ctx, "live", "x", time.Hour)
+
+	stale := filepath.Join(filepath.Dir(store.path("live")), ".tmp-stale")
+	_ = os.WriteFile(stale, nil, 0644)
+	_ = os.Chtimes(stale, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+
+	time.Sleep(5 * time.Millisecond)
+	store.GC()
+
+	if _, err := os.Stat(store.path("expired")); !os.IsNotExist(err) {
+		t.Error("expected the expired entry to be collected")
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("expected the stale temporary file to be collected")
+	}
+	if ok, _ := store.Has(ctx, "live"); !ok {
+		t.Error("expected the live entry to be kept")
+	}
+}
+
+func TestFileStoreExpiryRace(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileStore(FileConfig{Root: t.TempDir()})
+
+	// A reader saw the entry expired, then a writer replaced it before the removal
+	_ = store.Set(ctx, "key", "old", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	header, _, err := store.read(store.path("key"))
+	if err != nil || !header.expired(time.Now()) {
+		t.Fatalf("expected an expired entry, got %+v, %v", header, err)
+	}
+	_ = store.Set(ctx, "key", "new", 0)
+
+	store.removeExpired(store.path("key"), time.Now())
+	if value, err := store.Get(ctx, "key"); value != "new" {
+		t.Fatalf("expected the fresh value to survive, got %q, %v", value, err)
+	}
+}
@@ -0,0 +1,377 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operation results recorded as the cache.result attribute
+const (
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+// instruments OpenTelemetry instruments shared by every instrumented store
+var instruments struct {
+	once       sync.Once
+	operations metric.Int64Counter
+	duration   metric.Float64Histogram
+}
+
+// initInstruments creates the instruments on the global meter provider
+func initInstruments() {
+	instruments.once.Do(func() {
+		meter := otel.Meter("github.com/gin-generator/sugar/services/cache")
+		instruments.operations, _ = meter.Int64Counter("cache.operations",
+			metric.WithDescription("Cache operations by store, operation and result"))
+		instruments.duration, _ = meter.Float64Histogram("cache.operation.duration",
+			metric.WithDescription("Cache operation latency"), metric.WithUnit("s"))
+	})
+}
+
+// InstrumentedStats instrumented store counters
+type InstrumentedStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Sets    uint64 `json:"sets"`
+	Deletes uint64 `json:"deletes"`
+	Errors  uint64 `json:"errors"`
+}
+
+// Instrumented decorates a store with counters, OpenTelemetry metrics and hooks.
+// Optional capabilities are forwarded and fail when the store lacks them, probe
+// them with Supports rather than a type assertion.
+type Instrumented struct {
+	store Cache
+	name  string
+
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	sets    atomic.Uint64
+	deletes atomic.Uint64
+	errors  atomic.Uint64
+
+	mu     sync.RWMutex
+	onHit  []func(ctx context.Context, key string)
+	onMiss []func(ctx context.Context, key string)
+}
+
+// NewInstrumented decorates a store registered under name
+func NewInstrumented(name string, store Cache) *Instrumented {
+	initInstruments()
+	return &Instrumented{
+		store: store,
+		name:  name,
+	}
+}
+
+// Unwrap returns the decorated store
+func (i *Instrumented) Unwrap() Cache {
+	return i.store
+}
+
+// Name returns the store name
+func (i *Instrumented) Name() string {
+	return i.name
+}
+
+// Stats returns the store counters
+func (i *Instrumented) Stats() InstrumentedStats {
+	return InstrumentedStats{
+		Hits:    i.hits.Load(),
+		Misses:  i.misses.Load(),
+		Sets:    i.sets.Load(),
+		Deletes: i.deletes.Load(),
+		Errors:  i.errors.Load(),
+	}
+}
+
+// OnHit registers a handler called after a read finds a key
+func (i *Instrumented) OnHit(handler func(ctx context.Context, key string)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.onHit = append(i.onHit, handler)
+}
+
+// OnMiss registers a handler called after a read misses a key
+func (i *Instrumented) OnMiss(handler func(ctx context.Context, key string)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.onMiss = append(i.onMiss, handler)
+}
+
+// OnEvict registers a handler called when the store drops an entry on its own,
+// only stores implementing EvictNotifier report evictions
+func (i *Instrumented) OnEvict(handler func(key string)) {
+	if notifier, ok := i.store.(EvictNotifier); ok {
+		notifier.OnEvict(handler)
+	}
+}
+
+// record counts an operation and its latency
+func (i *Instrumented) record(ctx context.Context, operation, result string, start time.Time) {
+	if result == resultError {
+		i.errors.Add(1)
+	}
+
+	attrs := metric.WithAttributes(
+		attribute.String("cache.store", i.name),
+		attribute.String("cache.operation", operation),
+		attribute.String("cache.result", result),
+	)
+	instruments.operations.Add(ctx, 1, attrs)
+	instruments.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+}
+
+// result maps an error to a result
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
+
+// read counts a read of a key and runs the hit or miss hooks
+func (i *Instrumented) read(ctx context.Context, key string, hit bool) {
+	i.mu.RLock()
+	handlers := i.onMiss
+	if hit {
+		handlers = i.onHit
+	}
+	i.mu.RUnlock()
+
+	if hit {
+		i.hits.Add(1)
+	} else {
+		i.misses.Add(1)
+	}
+	for _, handler := range handlers {
+		handler(ctx, key)
+	}
+}
+
+// Get retrieves a value from cache, a missing key returns ErrNotFound
+func (i *Instrumented) Get(ctx context.Context, key string) (string, error) {
+	start := time.Now()
+	value, err := i.store.Get(ctx, key)
+
+	switch {
+	case err == nil:
+		i.record(ctx, "get", resultHit, start)
+		i.read(ctx, key, true)
+	case errors.Is(err, ErrNotFound):
+		i.record(ctx, "get", resultMiss, start)
+		i.read(ctx, key, false)
+	default:
+		i.record(ctx, "get", resultError, start)
+	}
+	return value, err
+}
+
+// Set stores a value in cache
+func (i *Instrumented) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	start := time.Now()
+	err := i.store.Set(ctx, key, value, expiration)
+	if err == nil {
+		i.sets.Add(1)
+	}
+	i.record(ctx, "set", result(err), start)
+	return err
+}
+
+// Delete removes a value from cache
+func (i *Instrumented) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := i.store.Delete(ctx, key)
+	if err == nil {
+		i.deletes.Add(1)
+	}
+	i.record(ctx, "delete", result(err), start)
+	return err
+}
+
+// Has checks if a key exists in cache
+func (i *Instrumented) Has(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	ok, err := i.store.Has(ctx, key)
+	i.record(ctx, "has", result(err), start)
+	return ok, err
+}
+
+// Flush clears all cache
+func (i *Instrumented) Flush(ctx context.Context) error {
+	start := time.Now()
+	err := i.store.Flush(ctx)
+	i.record(ctx, "flush", result(err), start)
+	return err
+}
+
+// Increment atomically adds delta to an integer value
+func (i *Instrumented) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	start := time.Now()
+	n, err := increment(ctx, i.store, key, delta)
+	i.record(ctx, "increment", result(err), start)
+	return n, err
+}
+
+// Decrement atomically subtracts delta from an integer value
+func (i *Instrumented) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	start := time.Now()
+	n, err := increment(ctx, i.store, key, -delta)
+	i.record(ctx, "decrement", result(err), start)
+	return n, err
+}
+
+// Add stores a value only when the key is absent
+func (i *Instrumented) Add(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := add(ctx, i.store, key, value, expiration)
+	if ok {
+		i.sets.Add(1)
+	}
+	i.record(ctx, "add", result(err), start)
+	return ok, err
+}
+
+// GetMany retrieves several values, counting a hit or miss per key
+func (i *Instrumented) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	start := time.Now()
+	values, err := getMany(ctx, i.store, keys)
+	i.record(ctx, "get_many", result(err), start)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		_, hit := values[key]
+		i.read(ctx, key, hit)
+	}
+	return values, nil
+}
+
+// SetMany stores several values with the same expiration
+func (i *Instrumented) SetMany(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	start := time.Now()
+	err := setMany(ctx, i.store, values, expiration)
+	if err == nil {
+		i.sets.Add(uint64(len(values)))
+	}
+	i.record(ctx, "set_many", result(err), start)
+	return err
+}
+
+// DeleteMany removes several values
+func (i *Instrumented) DeleteMany(ctx context.Context, keys ...string) error {
+	start := time.Now()
+	err := deleteMany(ctx, i.store, keys)
+	if err == nil {
+		i.deletes.Add(uint64(len(keys)))
+	}
+	i.record(ctx, "delete_many", result(err), start)
+	return err
+}
+
+// TTL returns the remaining time to live of a key, 0 when it never expires
+func (i *Instrumented) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	remaining, err := ttl(ctx, i.store, key)
+	if errors.Is(err, ErrNotFound) {
+		i.record(ctx, "ttl", resultMiss, start)
+	} else {
+		i.record(ctx, "ttl", result(err), start)
+	}
+	return remaining, err
+}
+
+// Touch resets the expiration of a key
+func (i *Instrumented) Touch(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := touch(ctx, i.store, key, expiration)
+	i.record(ctx, "touch", result(err), start)
+	return ok, err
+}
+
+// SetTagged stores a value in cache under tags
+func (i *Instrumented) SetTagged(ctx context.Context, tags []string, key string, value interface{}, expiration time.Duration) error {
+	taggable, ok := i.store.(Taggable)
+	if !ok {
+		return fmt.Errorf("cache store %T does not support tags", i.store)
+	}
+
+	start := time.Now()
+	err := taggable.SetTagged(ctx, tags, key, value, expiration)
+	if err == nil {
+		i.sets.Add(1)
+	}
+	i.record(ctx, "set", result(err), start)
+	return err
+}
+
+// FlushTags removes every entry indexed under any of the tags
+func (i *Instrumented) FlushTags(ctx context.Context, tags ...string) error {
+	taggable, ok := i.store.(Taggable)
+	if !ok {
+		return fmt.Errorf("cache store %T does not support tags", i.store)
+	}
+
+	start := time.Now()
+	err := taggable.FlushTags(ctx, tags...)
+	i.record(ctx, "flush_tags", result(err), start)
+	return err
+}
+
+// AcquireLock takes a lock when it is free
+func (i *Instrumented) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	locker, ok := i.store.(Locker)
+	if !ok {
+		return false, fmt.Errorf("cache store %T does not support locks", i.store)
+	}
+	return locker.AcquireLock(ctx, name, owner, ttl)
+}
+
+// ReleaseLock releases a lock held by owner
+func (i *Instrumented) ReleaseLock(ctx context.Context, name, owner string) (bool, error) {
+	locker, ok := i.store.(Locker)
+	if !ok {
+		return false, fmt.Errorf("cache store %T does not support locks", i.store)
+	}
+	return locker.ReleaseLock(ctx, name, owner)
+}
+
+// ExtendLock resets the ttl of a lock held by owner
+func (i *Instrumented) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	locker, ok := i.store.(Locker)
+	if !ok {
+		return false, fmt.Errorf("cache store %T does not support locks", i.store)
+	}
+	return locker.ExtendLock(ctx, name, owner, ttl)
+}
+
+// Keys lists keys starting with prefix
+func (i *Instrumented) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	scanner, ok := i.store.(Scanner)
+	if !ok {
+		return nil, fmt.Errorf("cache store %T does not support listing keys", i.store)
+	}
+	return scanner.Keys(ctx, prefix, limit)
+}
+
+// Close closes the decorated store
+func (i *Instrumented) Close() error {
+	if closer, ok := i.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
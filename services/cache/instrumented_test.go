@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInstrumented(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStore(MemoryConfig{MaxEntries: 1})
+	defer memory.Close()
+
+	store := NewInstrumented("memory", memory)
+	var misses, evictions []string
+	store.OnMiss(func(ctx context.Context, key string) { misses = append(misses, key) })
+	store.OnEvict(func(key string) { evictions = append(evictions, key) })
+
+	_ = store.Set(ctx, "a", 1, 0)
+	_ = store.Set(ctx, "b", 2, 0)
+	_, _ = store.Get(ctx, "a")
+	_, _ = store.Get(ctx, "b")
+
+	stats := store.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Sets != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if len(misses) != 1 || misses[0] != "a" || len(evictions) != 1 || evictions[0] != "a" {
+		t.Fatalf("unexpected hooks, misses %v evictions %v", misses, evictions)
+	}
+
+	// Capabilities of the decorated store stay reachable
+	if _, err := NewTaggedCache(store, "tag"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Increment(ctx, "n", 2); err != nil || n != 2 {
+		t.Fatalf("expected 2, got %d (%v)", n, err)
+	}
+
+	// Missing keys are not errors
+	if _, err := store.TTL(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if ok, err := store.Touch(ctx, "missing", time.Minute); err != nil || ok {
+		t.Fatalf("expected the missing key to be reported, got %v, %v", ok, err)
+	}
+	if stats = store.Stats(); stats.Errors != 0 {
+		t.Fatalf("expected no errors, got %d", stats.Errors)
+	}
+}
+
+func TestInstrumentedCapabilities(t *testing.T) {
+	file, err := NewFileStore(FileConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewInstrumented("file", file)
+
+	if Supports[Taggable](store) || Supports[Locker](store) {
+		t.Fatal("expected the capabilities of the file store only")
+	}
+	if _, err := NewTaggedCache(store, "tag"); err == nil {
+		t.Fatal("expected tags to be rejected")
+	}
+	if _, err := NewLock(store, "lock", time.Second); err == nil {
+		t.Fatal("expected locks to be rejected")
+	}
+
+	// Unsupported expirations are counted as errors
+	_, ttlErr := store.TTL(context.Background(), "a")
+	_, touchErr := store.Touch(context.Background(), "a", time.Second)
+	if ttlErr == nil || touchErr == nil || store.Stats().Errors != 2 {
+		t.Fatalf("expected two counted errors, got %v, %v and %+v", ttlErr, touchErr, store.Stats())
+	}
+
+	memory := NewMemoryStore(MemoryConfig{})
+	defer memory.Close()
+	if !Supports[Taggable](NewInstrumented("memory", NewInstrumented("inner", memory))) {
+		t.Fatal("expected the tags of the memory store through nested decorators")
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStore(MemoryConfig{})
+	defer memory.Close()
+
+	m := NewManager()
+	m.AddStore("memory", NewInstrumented("memory", memory))
+	_ = memory.Set(ctx, "user:1", "a", 0)
+	_ = memory.Set(ctx, "user:2", "b", 0)
+	_ = memory.Set(ctx, "order:1", "c", 0)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterAdminRoutes(router.Group("/admin/cache"), m)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/admin/cache"+target, nil))
+		return w
+	}
+
+	var keys []string
+	_ = json.Unmarshal(serve(http.MethodGet, "/stores/memory/keys?prefix=user:").Body.Bytes(), &keys)
+	if len(keys) != 2 || keys[0] != "user:1" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	if w := serve(http.MethodGet, "/stores/memory/keys/user:1"); w.Code != http.StatusOK {
+		t.Fatalf("expected the key to be found, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/stores/memory/keys/user:1"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the key to be deleted, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/stores/memory/keys/user:1"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected the deleted key to be missing, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/stores/missing/keys"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown store to be missing, got %d", w.Code)
+	}
+
+	_ = memory.Set(ctx, "files/a.txt", "d", 0)
+	if w := serve(http.MethodGet, "/stores/memory/keys/files/a.txt"); w.Code != http.StatusOK {
+		t.Fatalf("expected a key with slashes to be found, got %d", w.Code)
+	}
+
+	var stores []adminStore
+	_ = json.Unmarshal(serve(http.MethodGet, "/stores").Body.Bytes(), &stores)
+	if len(stores) != 1 || stores[0].Name != "memory" || !stores[0].Default || stores[0].Stats == nil {
+		t.Fatalf("unexpected stores %+v", stores)
+	}
+}
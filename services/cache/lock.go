@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/logger"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// lockRetryInterval delay between attempts of a blocking acquire
+const lockRetryInterval = 100 * time.Millisecond
+
+var (
+	// ErrLockTimeout is returned by Block when the lock was not acquired in time
+	ErrLockTimeout = errors.New("cache: lock timeout")
+	// ErrLockNotOwned is returned by Release when the lock expired or is held by another owner
+	ErrLockNotOwned = errors.New("cache: lock not owned")
+	// ErrLockHeld is returned when acquiring a lock this instance already holds
+	ErrLockHeld = errors.New("cache: lock already held")
+)
+
+// Locker stores providing atomic locks checked against an owner token
+type Locker interface {
+	AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, name, owner string) (bool, error)
+	ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+}
+
+// DistributedLock mutual exclusion across processes sharing a store. While
+// held the lock is extended every third of its ttl until it is released.
+type DistributedLock struct {
+	locker Locker
+	name   string
+	owner  string
+	ttl    time.Duration
+	err    error
+
+	mu   sync.Mutex
+	stop chan struct{}
+	lost chan struct{}
+}
+
+// NewLock creates a lock on a store, the store must implement Locker
+func NewLock(store Cache, name string, ttl time.Duration) (*DistributedLock, error) {
+	if !Supports[Locker](store) {
+		return nil, fmt.Errorf("cache store %T does not support locks", store)
+	}
+	return &DistributedLock{
+		locker: store.(Locker),
+		name:   name,
+		owner:  uuid.NewString(),
+		ttl:    ttl,
+	}, nil
+}
+
+// Lock returns a lock on the default store (Facade pattern)
+func Lock(name string, ttl time.Duration) *DistributedLock {
+	if manager == nil {
+		return &DistributedLock{err: fmt.Errorf("cache manager not initialized")}
+	}
+	return manager.Lock(manager.DefaultName(), name, ttl)
+}
+
+// Lock returns a lock on a store, errors are reported by its methods
+func (m *Manager) Lock(store, name string, ttl time.Duration) *DistributedLock {
+	s, err := m.Store(store)
+	if err != nil {
+		return &DistributedLock{err: err}
+	}
+
+	lock, err := NewLock(s, name, ttl)
+	if err != nil {
+		return &DistributedLock{err: err}
+	}
+	return lock
+}
+
+// Name returns the lock name
+func (l *DistributedLock) Name() string {
+	return l.name
+}
+
+// Owner returns the owner token of the lock
+func (l *DistributedLock) Owner() string {
+	return l.owner
+}
+
+// TryAcquire attempts to acquire the lock once, ErrLockHeld when it is held
+// already. A lost lock may be acquired again without releasing it.
+func (l *DistributedLock) TryAcquire(ctx context.Context) (bool, error) {
+	if l.err != nil {
+		return false, l.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stop != nil {
+		select {
+		case <-l.lost:
+			// The keepAlive of the lost lock has returned
+			close(l.stop)
+			l.stop = nil
+		default:
+			return false, ErrLockHeld
+		}
+	}
+
+	start := time.Now()
+	ok, err := l.locker.AcquireLock(ctx, l.name, l.owner, l.ttl)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	l.keepAlive(start)
+	return true, nil
+}
+
+// Acquire blocks until the lock is acquired or ctx is done
+func (l *DistributedLock) Acquire(ctx context.Context) error {
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := l.TryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Block waits up to timeout for the lock, returning ErrLockTimeout when it stays taken
+func (l *DistributedLock) Block(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := l.Acquire(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrLockTimeout
+	}
+	return err
+}
+
+// Release releases the lock if it is still owned
+func (l *DistributedLock) Release(ctx context.Context) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	l.mu.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	l.mu.Unlock()
+
+	ok, err := l.locker.ReleaseLock(ctx, l.name, l.owner)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotOwned
+	}
+	return nil
+}
+
+// Lost returns a channel closed when the held lock is taken over or could not be
+// extended for its whole ttl, nil before it is acquired
+func (l *DistributedLock) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lost
+}
+
+// keepAlive extends the lock acquired at start in the background until it is
+// released or lost, l.mu must be held
+func (l *DistributedLock) keepAlive(start time.Time) {
+	if l.ttl <= 0 {
+		return
+	}
+
+	stop, lost := make(chan struct{}), make(chan struct{})
+	l.stop, l.lost = stop, lost
+
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		extended := start
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				ok, err := l.locker.ExtendLock(context.Background(), l.name, l.owner, l.ttl)
+				if err != nil {
+					logger.ReportError("cache lock extend failed", err)
+					// The lock is still valid until its ttl runs out, try again on the next tick
+					if time.Since(extended) < l.ttl {
+						continue
+					}
+					close(lost)
+					return
+				}
+				if !ok {
+					close(lost)
+					return
+				}
+				extended = now
+			}
+		}
+	}()
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testLocker checks the owner-token contract every Locker shares, advance lets the store's clock move on
+func testLocker(t *testing.T, locker Locker, advance func(d time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+
+	if ok, err := locker.AcquireLock(ctx, "a", "one", time.Hour); err != nil || !ok {
+		t.Fatalf("expected a free lock to be acquired, got %v, %v", ok, err)
+	}
+	if ok, _ := locker.AcquireLock(ctx, "a", "two", time.Hour); ok {
+		t.Fatal("expected a held lock to be refused")
+	}
+
+	if ok, _ := locker.ExtendLock(ctx, "a", "two", time.Hour); ok {
+		t.Error("expected another owner to be unable to extend the lock")
+	}
+	// Back to back extensions may write the same expiration
+	for i := 0; i < 2; i++ {
+		if ok, err := locker.ExtendLock(ctx, "a", "one", time.Hour); err != nil || !ok {
+			t.Fatalf("expected the owner to extend the lock, got %v, %v", ok, err)
+		}
+	}
+
+	if ok, _ := locker.ReleaseLock(ctx, "a", "two"); ok {
+		t.Error("expected another owner to be unable to release the lock")
+	}
+	if ok, err := locker.ReleaseLock(ctx, "a", "one"); err != nil || !ok {
+		t.Fatalf("expected the owner to release the lock, got %v, %v", ok, err)
+	}
+	if ok, _ := locker.ReleaseLock(ctx, "a", "one"); ok {
+		t.Error("expected releasing a released lock to report it was not held")
+	}
+
+	// An expired lock is taken over and is no longer the previous owner's
+	_, _ = locker.AcquireLock(ctx, "b", "one", 5*time.Millisecond)
+	advance(10 * time.Millisecond)
+	if ok, err := locker.AcquireLock(ctx, "b", "two", time.Hour); err != nil || !ok {
+		t.Fatalf("expected an expired lock to be taken over, got %v, %v", ok, err)
+	}
+	if ok, _ := locker.ExtendLock(ctx, "b", "one", time.Hour); ok {
+		t.Error("expected the previous owner to be unable to extend the lock")
+	}
+	if ok, _ := locker.ReleaseLock(ctx, "b", "one"); ok {
+		t.Error("expected the previous owner to be unable to release the lock")
+	}
+}
+
+func TestLockers(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		store := NewMemoryStore(MemoryConfig{})
+		defer store.Close()
+		testLocker(t, store, time.Sleep)
+	})
+
+	t.Run("database", func(t *testing.T) {
+		testLocker(t, openDatabaseStore(t), time.Sleep)
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		store, mr := newTestRedisStore(t, "app:")
+		testLocker(t, store, mr.FastForward)
+	})
+}
+
+func TestDistributedLock(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(MemoryConfig{})
+	defer store.Close()
+
+	if _, err := NewLock(&FileStore{}, "job", time.Second); err == nil {
+		t.Fatal("expected a store without locks to be rejected")
+	}
+
+	first, _ := NewLock(store, "job", 30*time.Millisecond)
+	second, _ := NewLock(store, "job", 30*time.Millisecond)
+	if first.Lost() != nil {
+		t.Error("expected no lost channel before the lock is acquired")
+	}
+
+	if err := first.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := first.TryAcquire(ctx); ok || !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected ErrLockHeld, got %v, %v", ok, err)
+	}
+	if err := second.Block(ctx, 100*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+
+	// The lock outlived several ttls thanks to the keep-alive
+	if ok, _ := second.TryAcquire(ctx); ok {
+		t.Fatal("expected the held lock to be kept alive")
+	}
+
+	// Another process stole the lock, the next extension notices
+	store.mu.Lock()
+	store.locks["job"] = memoryLock{owner: second.Owner(), expiresAt: time.Now().Add(time.Hour)}
+	store.mu.Unlock()
+	select {
+	case <-first.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("expected the lost channel to be closed")
+	}
+
+	// A lost lock is acquired again once it is free
+	if err := second.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := first.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("expected the lost lock to be acquired again, got %v, %v", ok, err)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Release(ctx); !errors.Is(err, ErrLockNotOwned) {
+		t.Fatalf("expected ErrLockNotOwned, got %v", err)
+	}
+}
+
+// failingLocker a memory store whose extensions fail
+type failingLocker struct {
+	*MemoryStore
+}
+
+// ExtendLock always fails
+func (f failingLocker) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	return false, errors.New("store unreachable")
+}
+
+func TestDistributedLockLostAfterFailedExtensions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(MemoryConfig{})
+	defer store.Close()
+
+	lock, _ := NewLock(failingLocker{store}, "job", 30*time.Millisecond)
+	start := time.Now()
+	if ok, err := lock.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("expected the lock to be acquired, got %v, %v", ok, err)
+	}
+
+	select {
+	case <-lock.Lost():
+		if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+			t.Errorf("expected the lock to be kept until its ttl ran out, lost after %s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the lost channel to be closed once the ttl ran out")
+	}
+}
@@ -2,11 +2,16 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+// ErrNotFound is returned by Get when a key is missing or expired
+var ErrNotFound = errors.New("cache: key not found")
+
 // Cache interface
 type Cache interface {
 	Get(ctx context.Context, key string) (string, error)
@@ -16,9 +21,46 @@ type Cache interface {
 	Flush(ctx context.Context) error
 }
 
+// Counter stores with atomic integer counters. A missing key counts from zero
+// and never expires, the ttl of an existing key is kept.
+type Counter interface {
+	Increment(ctx context.Context, key string, delta int64) (int64, error)
+	Decrement(ctx context.Context, key string, delta int64) (int64, error)
+}
+
+// Adder stores that can set a value only when the key is absent
+type Adder interface {
+	Add(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+}
+
+// Batcher stores reading and writing several keys in one call. GetMany omits missing keys.
+type Batcher interface {
+	GetMany(ctx context.Context, keys []string) (map[string]string, error)
+	SetMany(ctx context.Context, values map[string]interface{}, expiration time.Duration) error
+	DeleteMany(ctx context.Context, keys ...string) error
+}
+
+// Expirer stores exposing key expiration. TTL returns 0 for a key that never
+// expires and ErrNotFound for a missing key, Touch with 0 removes the expiration.
+type Expirer interface {
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Touch(ctx context.Context, key string, expiration time.Duration) (bool, error)
+}
+
+// Scanner stores listing their keys
+type Scanner interface {
+	Keys(ctx context.Context, prefix string, limit int) ([]string, error)
+}
+
+// EvictNotifier stores reporting entries they drop on their own, on expiry or over capacity
+type EvictNotifier interface {
+	OnEvict(handler func(key string))
+}
+
 // Manager cache manager
 type Manager struct {
 	stores       map[string]Cache
+	serializers  map[string]Serializer
 	mu           sync.RWMutex
 	defaultStore string
 }
@@ -26,7 +68,8 @@ type Manager struct {
 // NewManager creates a new cache manager
 func NewManager() *Manager {
 	return &Manager{
-		stores: make(map[string]Cache),
+		stores:      make(map[string]Cache),
+		serializers: make(map[string]Serializer),
 	}
 }
 
@@ -82,3 +125,53 @@ func (m *Manager) SetDefault(name string) error {
 	m.defaultStore = name
 	return nil
 }
+
+// DefaultName returns the name of the default cache store
+func (m *Manager) DefaultName() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.defaultStore
+}
+
+// SetSerializer sets the serializer typed values of a store are encoded with
+func (m *Manager) SetSerializer(name string, serializer Serializer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.serializers[name] = serializer
+}
+
+// Serializer gets the serializer of a store, JSON when none is set
+func (m *Manager) Serializer(name string) Serializer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if serializer, ok := m.serializers[name]; ok {
+		return serializer
+	}
+	return JSONSerializer{}
+}
+
+// Names returns the sorted names of the stores
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.stores))
+	for name := range m.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Wrap replaces every store with the result of wrap, e.g. to decorate them with NewInstrumented
+func (m *Manager) Wrap(wrap func(name string, store Cache) Cache) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, store := range m.stores {
+		m.stores[name] = wrap(name, store)
+	}
+}
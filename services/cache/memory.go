@@ -0,0 +1,572 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryConfig memory store configuration
+type MemoryConfig struct {
+	MaxEntries      int   `validate:"omitempty,gte=0"` // 0 is unbounded
+	MaxBytes        int64 `validate:"omitempty,gte=0"` // 0 is unbounded, keys and values are counted
+	CleanupInterval int   `validate:"omitempty,gt=0"`  // seconds between expired entry sweeps, defaults to 60
+}
+
+// MemoryStats memory store counters
+type MemoryStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// memoryEntry cached value
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+// expired checks if the entry is expired at now
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// size returns the accounted size of the entry
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// memoryTag keys written under a tag
+type memoryTag struct {
+	keys      map[string]struct{}
+	expiresAt time.Time // outlives every member, zero never expires
+}
+
+// memoryLock held lock
+type memoryLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// expired checks if the lock is expired at now
+func (l memoryLock) expired(now time.Time) bool {
+	return !l.expiresAt.IsZero() && !now.Before(l.expiresAt)
+}
+
+// MemoryStore concurrent in-process cache store with TTL and LRU eviction
+type MemoryStore struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	tags       map[string]*memoryTag
+	locks      map[string]memoryLock
+	lru        *list.List
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+
+	onEvict []func(key string)
+	evicted []string
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryStore creates a memory store and starts its janitor
+func NewMemoryStore(cfg MemoryConfig) *MemoryStore {
+	m := &MemoryStore{
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]*memoryTag),
+		locks:      make(map[string]memoryLock),
+		lru:        list.New(),
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxBytes,
+		stop:       make(chan struct{}),
+	}
+
+	interval := time.Duration(cfg.CleanupInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go m.janitor(interval)
+
+	return m
+}
+
+// janitor removes expired entries and locks periodically
+func (m *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.DeleteExpired()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Get retrieves a value from cache, a missing key returns ErrNotFound
+func (m *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		m.misses.Add(1)
+		return "", ErrNotFound
+	}
+
+	m.hits.Add(1)
+	return entry.value, nil
+}
+
+// lookup finds a live entry and marks it as recently used, callers hold the lock
+func (m *MemoryStore) lookup(key string) (*memoryEntry, bool) {
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		m.drop(elem)
+		return nil, false
+	}
+
+	m.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// Set stores a value in cache, a zero expiration never expires
+func (m *MemoryStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	str, err := toString(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.set(key, str, expiration, nil)
+	return nil
+}
+
+// SetTagged stores a value in cache and indexes it under tags
+func (m *MemoryStore) SetTagged(ctx context.Context, tags []string, key string, value interface{}, expiration time.Duration) error {
+	str, err := toString(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.set(key, str, expiration, tags)
+	return nil
+}
+
+// FlushTags removes every entry indexed under any of the tags
+func (m *MemoryStore) FlushTags(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	for _, tag := range tags {
+		if t, ok := m.tags[tag]; ok {
+			for key := range t.keys {
+				if elem, ok := m.items[key]; ok {
+					m.remove(elem)
+				}
+			}
+		}
+		delete(m.tags, tag)
+	}
+	return nil
+}
+
+// set stores an entry and evicts over the bounds, callers hold the lock
+func (m *MemoryStore) set(key, value string, expiration time.Duration, tags []string) {
+	entry := &memoryEntry{key: key, value: value}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+
+	if elem, ok := m.items[key]; ok {
+		m.bytes -= elem.Value.(*memoryEntry).size()
+		elem.Value = entry
+		m.lru.MoveToFront(elem)
+	} else {
+		m.items[key] = m.lru.PushFront(entry)
+	}
+	m.bytes += entry.size()
+
+	for _, tag := range tags {
+		m.tag(tag, key, entry.expiresAt)
+	}
+
+	m.evict()
+}
+
+// evict removes least recently used entries until the store is within its bounds
+func (m *MemoryStore) evict() {
+	for m.lru.Len() > 0 &&
+		((m.maxEntries > 0 && m.lru.Len() > m.maxEntries) || (m.maxBytes > 0 && m.bytes > m.maxBytes)) {
+		m.drop(m.lru.Back())
+		m.evictions.Add(1)
+	}
+}
+
+// remove removes an element, callers hold the lock
+func (m *MemoryStore) remove(elem *list.Element) {
+	entry := elem.Value.(*memoryEntry)
+	m.lru.Remove(elem)
+	delete(m.items, entry.key)
+	m.bytes -= entry.size()
+}
+
+// drop removes an element the store evicts on its own and queues its eviction
+// handlers, which run once the lock is released. Callers hold the lock.
+func (m *MemoryStore) drop(elem *list.Element) {
+	if len(m.onEvict) > 0 {
+		m.evicted = append(m.evicted, elem.Value.(*memoryEntry).key)
+	}
+	m.remove(elem)
+}
+
+// unlock releases the lock and runs the eviction handlers of dropped entries
+func (m *MemoryStore) unlock() {
+	evicted, handlers := m.evicted, m.onEvict
+	m.evicted = nil
+	m.mu.Unlock()
+
+	for _, key := range evicted {
+		for _, handler := range handlers {
+			handler(key)
+		}
+	}
+}
+
+// OnEvict registers a handler called with the key of every entry dropped on expiry or over capacity
+func (m *MemoryStore) OnEvict(handler func(key string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onEvict = append(m.onEvict, handler)
+}
+
+// Keys lists live keys starting with prefix in sorted order, at most limit when positive
+func (m *MemoryStore) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := time.Now()
+	keys := make([]string, 0)
+	for key, elem := range m.items {
+		if strings.HasPrefix(key, prefix) && !elem.Value.(*memoryEntry).expired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+// tag indexes a key under a tag until the tag is flushed, like the tag sets of
+// the Redis store the index outlives rewrites and deletes of the key and
+// expires with its longest lived member. Callers hold the lock.
+func (m *MemoryStore) tag(tag, key string, expiresAt time.Time) {
+	t, ok := m.tags[tag]
+	if !ok {
+		t = &memoryTag{keys: make(map[string]struct{}), expiresAt: expiresAt}
+		m.tags[tag] = t
+	}
+	t.keys[key] = struct{}{}
+
+	if expiresAt.IsZero() || (!t.expiresAt.IsZero() && expiresAt.After(t.expiresAt)) {
+		t.expiresAt = expiresAt
+	}
+}
+
+// Delete removes a value from cache
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	return nil
+}
+
+// Has checks if a key exists in cache
+func (m *MemoryStore) Has(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return false, nil
+	}
+	if elem.Value.(*memoryEntry).expired(time.Now()) {
+		m.drop(elem)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Flush clears all cache
+func (m *MemoryStore) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.items = make(map[string]*list.Element)
+	m.tags = make(map[string]*memoryTag)
+	m.lru.Init()
+	m.bytes = 0
+	return nil
+}
+
+// Increment atomically adds delta to an integer value
+func (m *MemoryStore) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		m.set(key, strconv.FormatInt(delta, 10), 0, nil)
+		return delta, nil
+	}
+
+	current, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cache key %s is not an integer", key)
+	}
+
+	value := current + delta
+	m.bytes -= entry.size()
+	entry.value = strconv.FormatInt(value, 10)
+	m.bytes += entry.size()
+
+	// A longer number may push the store over its byte bound
+	m.evict()
+	return value, nil
+}
+
+// Decrement atomically subtracts delta from an integer value
+func (m *MemoryStore) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return m.Increment(ctx, key, -delta)
+}
+
+// Add stores a value only when the key is absent
+func (m *MemoryStore) Add(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	str, err := toString(value)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	m.set(key, str, expiration, nil)
+	return true, nil
+}
+
+// GetMany retrieves several values, missing keys are omitted
+func (m *MemoryStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		entry, ok := m.lookup(key)
+		if !ok {
+			m.misses.Add(1)
+			continue
+		}
+		m.hits.Add(1)
+		values[key] = entry.value
+	}
+	return values, nil
+}
+
+// SetMany stores several values with the same expiration
+func (m *MemoryStore) SetMany(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	converted := make(map[string]string, len(values))
+	for key, value := range values {
+		str, err := toString(value)
+		if err != nil {
+			return err
+		}
+		converted[key] = str
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	for key, value := range converted {
+		m.set(key, value, expiration, nil)
+	}
+	return nil
+}
+
+// DeleteMany removes several values
+func (m *MemoryStore) DeleteMany(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.items[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+// TTL returns the remaining time to live of a key, 0 when it never expires
+func (m *MemoryStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return time.Until(entry.expiresAt), nil
+}
+
+// Touch resets the expiration of a key, 0 never expires
+func (m *MemoryStore) Touch(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		return false, nil
+	}
+
+	entry.expiresAt = time.Time{}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+	return true, nil
+}
+
+// DeleteExpired removes all expired entries, tags and locks
+func (m *MemoryStore) DeleteExpired() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := time.Now()
+	for elem := m.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*memoryEntry).expired(now) {
+			m.drop(elem)
+		}
+		elem = prev
+	}
+
+	for name, t := range m.tags {
+		if !t.expiresAt.IsZero() && now.After(t.expiresAt) {
+			delete(m.tags, name)
+		}
+	}
+
+	for name, lock := range m.locks {
+		if lock.expired(now) {
+			delete(m.locks, name)
+		}
+	}
+}
+
+// AcquireLock takes a lock when it is free or expired
+func (m *MemoryStore) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := time.Now()
+	if lock, ok := m.locks[name]; ok && !lock.expired(now) {
+		return false, nil
+	}
+
+	lock := memoryLock{owner: owner}
+	if ttl > 0 {
+		lock.expiresAt = now.Add(ttl)
+	}
+	m.locks[name] = lock
+	return true, nil
+}
+
+// ReleaseLock releases a lock held by owner
+func (m *MemoryStore) ReleaseLock(ctx context.Context, name, owner string) (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	lock, ok := m.locks[name]
+	if !ok || lock.owner != owner {
+		return false, nil
+	}
+	delete(m.locks, name)
+	return !lock.expired(time.Now()), nil
+}
+
+// ExtendLock resets the ttl of a lock held by owner
+func (m *MemoryStore) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	lock, ok := m.locks[name]
+	now := time.Now()
+	if !ok || lock.owner != owner || lock.expired(now) {
+		return false, nil
+	}
+
+	lock.expiresAt = now.Add(ttl)
+	m.locks[name] = lock
+	return true, nil
+}
+
+// Stats returns the store counters
+func (m *MemoryStore) Stats() MemoryStats {
+	m.mu.Lock()
+	entries, bytes := m.lru.Len(), m.bytes
+	m.mu.Unlock()
+
+	return MemoryStats{
+		Hits:      m.hits.Load(),
+		Misses:    m.misses.Load(),
+		Evictions: m.evictions.Load(),
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+// Close stops the janitor
+func (m *MemoryStore) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	return nil
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(MemoryConfig{})
+	defer store.Close()
+
+	if err := store.Set(ctx, "foo", 42, 0); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := store.Get(ctx, "foo"); value != "42" {
+		t.Fatalf("expected 42, got %q", value)
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.Set(ctx, "struct", struct{}{}, 0); err == nil {
+		t.Error("expected storing a struct to fail")
+	}
+
+	_ = store.Set(ctx, "short", "lived", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := store.Has(ctx, "short"); ok {
+		t.Error("expected the entry to expire")
+	}
+
+	stats := store.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+
+	store := NewMemoryStore(MemoryConfig{MaxEntries: 3})
+	defer store.Close()
+	for i := 0; i < 3; i++ {
+		_ = store.Set(ctx, fmt.Sprint(i), i, 0)
+	}
+	_, _ = store.Get(ctx, "0") // 1 becomes the least recently used
+	_ = store.Set(ctx, "3", 3, 0)
+
+	if ok, _ := store.Has(ctx, "1"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if ok, _ := store.Has(ctx, "0"); !ok {
+		t.Error("expected the recently used entry to be kept")
+	}
+
+	bounded := NewMemoryStore(MemoryConfig{MaxBytes: 10})
+	defer bounded.Close()
+	_ = bounded.Set(ctx, "a", "1234", 0)
+	_ = bounded.Set(ctx, "b", "1234", 0)
+	_ = bounded.Set(ctx, "c", "1234", 0)
+
+	stats := bounded.Stats()
+	if stats.Bytes > 10 || stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestMemoryStoreCapabilities(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(MemoryConfig{})
+	defer store.Close()
+
+	if n, _ := store.Increment(ctx, "hits", 5); n != 5 {
+		t.Fatalf("expected a missing counter to start at the delta, got %d", n)
+	}
+	if n, _ := store.Increment(ctx, "hits", 2); n != 7 {
+		t.Fatalf("expected 7, got %d", n)
+	}
+	if n, _ := store.Decrement(ctx, "hits", 10); n != -3 {
+		t.Fatalf("expected -3, got %d", n)
+	}
+	_ = store.Set(ctx, "name", "foo", 0)
+	if _, err := store.Increment(ctx, "name", 1); err == nil {
+		t.Error("expected incrementing a non-integer to fail")
+	}
+
+	if ok, _ := store.Add(ctx, "name", "bar", 0); ok {
+		t.Error("expected Add to keep an existing key")
+	}
+	if ok, _ := store.Add(ctx, "fresh", "bar", 0); !ok {
+		t.Error("expected Add to store an absent key")
+	}
+
+	_ = store.SetMany(ctx, map[string]interface{}{"a": 1, "b": "two"}, time.Hour)
+	values, _ := store.GetMany(ctx, []string{"a", "b", "missing"})
+	if len(values) != 2 || values["a"] != "1" || values["b"] != "two" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	_ = store.DeleteMany(ctx, "a", "b")
+	if values, _ = store.GetMany(ctx, []string{"a", "b"}); len(values) != 0 {
+		t.Fatalf("expected the keys to be deleted, got %v", values)
+	}
+
+	_ = store.Set(ctx, "session", "x", time.Minute)
+	if ttl, _ := store.TTL(ctx, "session"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("unexpected ttl %s", ttl)
+	}
+	if ok, _ := store.Touch(ctx, "session", 0); !ok {
+		t.Fatal("expected an existing key to be touched")
+	}
+	if ttl, _ := store.TTL(ctx, "session"); ttl != 0 {
+		t.Errorf("expected a touched key without expiration, got %s", ttl)
+	}
+	if ok, _ := store.Touch(ctx, "missing", time.Minute); ok {
+		t.Error("expected touching a missing key to report it")
+	}
+	if _, err := store.TTL(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreIncrementEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(MemoryConfig{MaxBytes: 8})
+	defer store.Close()
+
+	_ = store.Set(ctx, "a", "1", 0)
+	_, _ = store.Increment(ctx, "n", 1)
+	_, _ = store.Increment(ctx, "n", 99999)
+
+	// The counter grew past the bound, the least recently used entry goes
+	if stats := store.Stats(); stats.Bytes > 8 {
+		t.Fatalf("expected the store to stay within 8 bytes, got %d", stats.Bytes)
+	}
+	if ok, _ := store.Has(ctx, "a"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if n, _ := store.Increment(ctx, "n", 0); n != 100000 {
+		t.Errorf("expected the counter to survive, got %d", n)
+	}
+}
+
+func TestMemoryStoreDeleteExpiredLocks(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(MemoryConfig{})
+	defer store.Close()
+
+	_, _ = store.AcquireLock(ctx, "short", "a", time.Millisecond)
+	_, _ = store.AcquireLock(ctx, "long", "b", time.Minute)
+	_, _ = store.AcquireLock(ctx, "forever", "c", 0)
+	time.Sleep(5 * time.Millisecond)
+
+	store.DeleteExpired()
+
+	store.mu.Lock()
+	_, short := store.locks["short"]
+	held := len(store.locks)
+	store.mu.Unlock()
+	if short || held != 2 {
+		t.Errorf("expected only the expired lock to be purged, %d locks left", held)
+	}
+}
@@ -2,55 +2,524 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Redis deployment modes
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// flushBatch keys scanned and deleted per round trip when flushing
+const flushBatch = 1000
+
+// tagScript adds a member to a tag set and keeps the set alive at least as long
+// as its longest lived member. ARGV[2] is the member ttl in milliseconds, 0 never expires.
+var tagScript = redis.NewScript(`
+local existed = redis.call('EXISTS', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl == 0 then
+	redis.call('PERSIST', KEYS[1])
+	return 1
+end
+local current = redis.call('PTTL', KEYS[1])
+if existed == 0 or (current >= 0 and current < ttl) then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// releaseScript deletes a lock when it is held by ARGV[1]
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendScript resets the ttl of a lock held by ARGV[1] to ARGV[2] milliseconds
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
 // RedisConfig Redis configuration
 type RedisConfig struct {
-	Host     string
-	Port     int
-	Password string
-	DB       int
+	Mode             string          `validate:"omitempty,oneof=standalone sentinel cluster"`
+	Host             string          `validate:"required_without=Addrs"`
+	Port             int             `validate:"omitempty,gt=0,lte=65535"`
+	Addrs            []string        `validate:"omitempty"` // sentinel or cluster nodes, host:port
+	MasterName       string          `validate:"required_if=Mode sentinel"`
+	Username         string          `validate:"omitempty"`
+	Password         string          `validate:"omitempty"`
+	SentinelPassword string          `validate:"omitempty"`
+	DB               int             `validate:"omitempty,gte=0"`
+	PoolSize         int             `validate:"omitempty,gt=0"`
+	MinIdleConns     int             `validate:"omitempty,gte=0"`
+	DialTimeout      int             `validate:"omitempty,gt=0"` // milliseconds
+	ReadTimeout      int             `validate:"omitempty,gt=0"` // milliseconds
+	WriteTimeout     int             `validate:"omitempty,gt=0"` // milliseconds
+	Prefix           string          `validate:"omitempty"`      // prepended to every key
+	TLS              *RedisTLSConfig `validate:"omitempty"`
 }
 
-// RedisStore Redis cache store (example implementation)
+// RedisTLSConfig Redis TLS configuration
+type RedisTLSConfig struct {
+	CertFile           string `validate:"required_with=KeyFile"`
+	KeyFile            string `validate:"required_with=CertFile"`
+	CAFile             string `validate:"omitempty"`
+	ServerName         string `validate:"omitempty"`
+	InsecureSkipVerify bool   `validate:"omitempty"`
+}
+
+// reservedNamespace holds the internal keys of the store, tag sets and locks,
+// under the store prefix. Keys starting with it are reserved.
+const reservedNamespace = "_sugar:"
+
+// RedisStore Redis cache store
 type RedisStore struct {
-	// You can use go-redis or other libraries here
-	// client *redis.Client
+	client redis.UniversalClient
+	prefix string
 }
 
 // NewRedisStore creates a Redis store
-func NewRedisStore(cfg RedisConfig) *RedisStore {
-	// Implement Redis connection
-	return &RedisStore{}
+func NewRedisStore(cfg RedisConfig) (*RedisStore, error) {
+	client, err := NewRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisStoreFromClient(client, cfg.Prefix), nil
+}
+
+// NewRedisStoreFromClient creates a Redis store on an existing client
+func NewRedisStoreFromClient(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// NewRedisClient creates a pooled Redis client for the configured mode
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := cfg.Addrs
+	if len(addrs) == 0 {
+		port := cfg.Port
+		if port == 0 {
+			port = 6379
+		}
+		addrs = []string{fmt.Sprintf("%s:%d", cfg.Host, port)}
+	}
+
+	switch cfg.Mode {
+	case RedisModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+			DialTimeout:      milliseconds(cfg.DialTimeout),
+			ReadTimeout:      milliseconds(cfg.ReadTimeout),
+			WriteTimeout:     milliseconds(cfg.WriteTimeout),
+			TLSConfig:        tlsConfig,
+		}), nil
+	case RedisModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  milliseconds(cfg.DialTimeout),
+			ReadTimeout:  milliseconds(cfg.ReadTimeout),
+			WriteTimeout: milliseconds(cfg.WriteTimeout),
+			TLSConfig:    tlsConfig,
+		}), nil
+	case "", RedisModeStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:         addrs[0],
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  milliseconds(cfg.DialTimeout),
+			ReadTimeout:  milliseconds(cfg.ReadTimeout),
+			WriteTimeout: milliseconds(cfg.WriteTimeout),
+			TLSConfig:    tlsConfig,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported redis mode %s", cfg.Mode)
+	}
+}
+
+// newTLSConfig builds the TLS configuration, nil when TLS is disabled
+func newTLSConfig(cfg *RedisTLSConfig) (*tls.Config, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in redis CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
 }
 
-// Get retrieves a value from cache
+// milliseconds converts a configured millisecond value, zero keeps the client default
+func milliseconds(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Client returns the underlying Redis client
+func (r *RedisStore) Client() redis.UniversalClient {
+	return r.client
+}
+
+// Prefix returns the key prefix
+func (r *RedisStore) Prefix() string {
+	return r.prefix
+}
+
+// key prepends the prefix to a key
+func (r *RedisStore) key(key string) string {
+	return r.prefix + key
+}
+
+// Get retrieves a value from cache, a missing key returns ErrNotFound
 func (r *RedisStore) Get(ctx context.Context, key string) (string, error) {
-	// Implement get logic
-	return "", nil
+	value, err := r.client.Get(ctx, r.key(key)).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
 }
 
-// Set stores a value in cache
+// Set stores a value in cache, a zero expiration never expires
 func (r *RedisStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	// Implement set logic
-	return nil
+	return r.client.Set(ctx, r.key(key), value, expiration).Err()
 }
 
 // Delete removes a value from cache
 func (r *RedisStore) Delete(ctx context.Context, key string) error {
-	// Implement delete logic
-	return nil
+	return r.client.Del(ctx, r.key(key)).Err()
 }
 
 // Has checks if a key exists in cache
 func (r *RedisStore) Has(ctx context.Context, key string) (bool, error) {
-	// Implement has logic
-	return false, nil
+	n, err := r.client.Exists(ctx, r.key(key)).Result()
+	return n > 0, err
+}
+
+// Increment atomically adds delta to an integer value
+func (r *RedisStore) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	return r.client.IncrBy(ctx, r.key(key), delta).Result()
+}
+
+// Decrement atomically subtracts delta from an integer value
+func (r *RedisStore) Decrement(ctx context.Context, key string, delta int64) (int64, error) {
+	return r.client.DecrBy(ctx, r.key(key), delta).Result()
+}
+
+// Add stores a value only when the key is absent
+func (r *RedisStore) Add(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, r.key(key), value, expiration).Result()
+}
+
+// GetMany retrieves several values, missing keys are omitted. Keys are read in
+// a pipeline instead of MGET so they may live in different cluster slots.
+func (r *RedisStore) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, r.key(key))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for i, cmd := range cmds {
+		value, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[keys[i]] = value
+	}
+	return values, nil
+}
+
+// SetMany stores several values with the same expiration
+func (r *RedisStore) SetMany(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(ctx, r.key(key), value, expiration)
+		}
+		return nil
+	})
+	return err
 }
 
-// Flush clears all cache
+// DeleteMany removes several values
+func (r *RedisStore) DeleteMany(ctx context.Context, keys ...string) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, r.key(key))
+		}
+		return nil
+	})
+	return err
+}
+
+// TTL returns the remaining time to live of a key, 0 when it never expires
+func (r *RedisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, r.key(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Redis replies -2 for a missing key and -1 for a key without expiration, unscaled
+	switch ttl {
+	case -2:
+		return 0, ErrNotFound
+	case -1:
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Touch resets the expiration of a key, 0 never expires
+func (r *RedisStore) Touch(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if expiration > 0 {
+		return r.client.PExpire(ctx, r.key(key), expiration).Result()
+	}
+
+	exists, err := r.client.Exists(ctx, r.key(key)).Result()
+	if err != nil || exists == 0 {
+		return false, err
+	}
+	return true, r.client.Persist(ctx, r.key(key)).Err()
+}
+
+// Flush removes every key under the prefix. Keys are found with SCAN so the
+// server is never blocked and other applications sharing the database are left
+// alone, which is why flushing without a prefix is refused.
 func (r *RedisStore) Flush(ctx context.Context) error {
-	// Implement flush logic
+	if r.prefix == "" {
+		return fmt.Errorf("refusing to flush redis store without a key prefix")
+	}
+
+	pattern := escapePattern(r.prefix) + "*"
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return flushPattern(ctx, node, pattern)
+		})
+	}
+	return flushPattern(ctx, r.client, pattern)
+}
+
+// tagKey returns the set holding the keys of a tag
+func (r *RedisStore) tagKey(tag string) string {
+	return r.prefix + reservedNamespace + "tag:" + tag + ":keys"
+}
+
+// SetTagged stores a value in cache and indexes it under tags
+func (r *RedisStore) SetTagged(ctx context.Context, tags []string, key string, value interface{}, expiration time.Duration) error {
+	ttl := expiration.Milliseconds()
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	// The key and the tag sets may hash to different slots, so they are written in a pipeline rather than a script
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(key), value, expiration)
+		for _, tag := range tags {
+			tagScript.Eval(ctx, pipe, []string{r.tagKey(tag)}, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// FlushTags removes every entry indexed under any of the tags
+func (r *RedisStore) FlushTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := r.tagKey(tag)
+
+		var cursor uint64
+		for {
+			keys, next, err := r.client.SScan(ctx, tagKey, cursor, "", flushBatch).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+					for _, key := range keys {
+						pipe.Unlink(ctx, r.key(key))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+
+		if err := r.client.Unlink(ctx, tagKey).Err(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// lockKey returns the key of a lock
+func (r *RedisStore) lockKey(name string) string {
+	return r.prefix + reservedNamespace + "lock:" + name
+}
+
+// AcquireLock takes a lock when it is free
+func (r *RedisStore) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, r.lockKey(name), owner, ttl).Result()
+}
+
+// ReleaseLock releases a lock held by owner
+func (r *RedisStore) ReleaseLock(ctx context.Context, name, owner string) (bool, error) {
+	n, err := releaseScript.Run(ctx, r.client, []string{r.lockKey(name)}, owner).Int()
+	return n == 1, err
+}
+
+// ExtendLock resets the ttl of a lock held by owner
+func (r *RedisStore) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	n, err := extendScript.Run(ctx, r.client, []string{r.lockKey(name)}, owner, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Keys lists keys starting with prefix, without the store prefix, at most limit
+// when positive. Keys are found with SCAN, the order is unspecified. Internal
+// tag sets and locks are left out.
+func (r *RedisStore) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	pattern := escapePattern(r.prefix+prefix) + "*"
+
+	var mu sync.Mutex
+	keys := make([]string, 0)
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, pattern, flushBatch).Iterator()
+		for iter.Next(ctx) {
+			key := strings.TrimPrefix(iter.Val(), r.prefix)
+			if strings.HasPrefix(key, reservedNamespace) {
+				continue
+			}
+
+			mu.Lock()
+			if limit > 0 && len(keys) >= limit {
+				mu.Unlock()
+				return nil
+			}
+			keys = append(keys, key)
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, r.client)
+	}
+	return keys, err
+}
+
+// Close closes the client
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
+
+// flushPattern deletes every key matching pattern on a single node
+func flushPattern(ctx context.Context, client redis.Cmdable, pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, flushBatch).Result()
+		if err != nil {
+			return err
+		}
+
+		// Keys of a batch may hash to different slots, delete them one by one in a pipeline
+		if len(keys) > 0 {
+			_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range keys {
+					pipe.Unlink(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// escapePattern escapes glob characters of a SCAN pattern
+func escapePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
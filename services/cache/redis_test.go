@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestRedisStore(t *testing.T, prefix string) (*RedisStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	store, err := NewRedisStore(RedisConfig{Host: mr.Host(), Port: mr.Server().Addr().Port, Prefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store, mr
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisStore(t, "app:")
+
+	if err := store.Set(ctx, "foo", "bar", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("app:foo") {
+		t.Fatal("expected the key to be prefixed")
+	}
+
+	value, err := store.Get(ctx, "foo")
+	if err != nil || value != "bar" {
+		t.Fatalf("expected bar, got %q (%v)", value, err)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if ok, _ := store.Has(ctx, "foo"); ok {
+		t.Error("expected the key to expire")
+	}
+
+	_ = store.Set(ctx, "foo", "bar", 0)
+	if err = store.Delete(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Has(ctx, "foo"); ok {
+		t.Error("expected the key to be deleted")
+	}
+}
+
+func TestRedisStoreFlushOnlyPrefix(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisStore(t, "app:")
+
+	for i := 0; i < 2500; i++ {
+		_ = store.Set(ctx, "key:"+time.Duration(i).String(), i, 0)
+	}
+	_ = mr.Set("other:key", "kept")
+
+	if err := store.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := mr.Keys()
+	if len(keys) != 1 || keys[0] != "other:key" {
+		t.Fatalf("expected only other:key to remain, got %d keys", len(keys))
+	}
+
+	unprefixed := NewRedisStoreFromClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "")
+	if err := unprefixed.Flush(ctx); err == nil {
+		t.Error("expected flushing without a prefix to be refused")
+	}
+}
+
+func TestRedisStoreTags(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisStore(t, "app:")
+
+	tagged, err := NewTaggedCache(store, "user:42", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tagged.Set(ctx, "orders:42", "[1,2]", time.Minute)
+	_ = store.Set(ctx, "untagged", "1", 0)
+
+	if ttl := mr.TTL("app:_sugar:tag:orders:keys"); ttl != time.Minute {
+		t.Fatalf("expected the tag set to live as long as its entries, got %s", ttl)
+	}
+
+	if err = tagged.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Has(ctx, "orders:42"); ok {
+		t.Error("expected the tagged entry to be flushed")
+	}
+	if ok, _ := store.Has(ctx, "untagged"); !ok {
+		t.Error("expected untagged entries to survive")
+	}
+	if mr.Exists("app:_sugar:tag:orders:keys") {
+		t.Error("expected the tag set to be removed")
+	}
+}
+
+func TestRedisStoreKeysSkipsInternalKeys(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestRedisStore(t, "app:")
+
+	tagged, err := NewTaggedCache(store, "tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tagged.Set(ctx, "user:1", "1", time.Minute)
+	_ = store.Set(ctx, "lock:report", "mine", 0)
+
+	// A user key named like a lock does not collide with it
+	if ok, _ := store.AcquireLock(ctx, "report", "owner", time.Minute); !ok {
+		t.Error("expected the lock to be free")
+	}
+	if value, _ := store.Get(ctx, "lock:report"); value != "mine" {
+		t.Errorf("expected the user key to survive, got %q", value)
+	}
+
+	keys, err := store.Keys(ctx, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"lock:report", "user:1"}) {
+		t.Errorf("expected only user keys, got %v", keys)
+	}
+}
+
+func TestRedisStoreLock(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newTestRedisStore(t, "app:")
+
+	first, _ := NewLock(store, "report", time.Second)
+	second, _ := NewLock(store, "report", time.Second)
+
+	if ok, err := first.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("expected the first lock to be acquired (%v)", err)
+	}
+	if err := second.Block(ctx, 150*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+	if err := second.Release(ctx); !errors.Is(err, ErrLockNotOwned) {
+		t.Fatalf("expected ErrLockNotOwned, got %v", err)
+	}
+
+	// Auto extension resets the ttl of the held lock
+	mr.SetTTL("app:_sugar:lock:report", time.Millisecond)
+	time.Sleep(400 * time.Millisecond)
+	if ttl := mr.TTL("app:_sugar:lock:report"); ttl != time.Second {
+		t.Fatalf("expected the lock to be extended, ttl %s", ttl)
+	}
+
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := second.TryAcquire(ctx); !ok {
+		t.Fatal("expected the released lock to be acquired")
+	}
+	_ = second.Release(ctx)
+}
+
+func TestRedisStoreCountersAndBatches(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestRedisStore(t, "app:")
+
+	if n, err := store.Increment(ctx, "hits", 5); err != nil || n != 5 {
+		t.Fatalf("expected 5, got %d (%v)", n, err)
+	}
+	if n, _ := store.Decrement(ctx, "hits", 2); n != 3 {
+		t.Fatalf("expected 3, got %d", n)
+	}
+	if ok, _ := store.Add(ctx, "hits", 1, 0); ok {
+		t.Error("expected add on an existing key to be refused")
+	}
+
+	_ = store.SetMany(ctx, map[string]interface{}{"a": 1, "b": 2}, time.Minute)
+	values, err := store.GetMany(ctx, []string{"a", "b", "missing"})
+	if err != nil || len(values) != 2 || values["a"] != "1" || values["b"] != "2" {
+		t.Fatalf("unexpected values %v (%v)", values, err)
+	}
+
+	if ttl, _ := store.TTL(ctx, "a"); ttl != time.Minute {
+		t.Errorf("expected a minute, got %s", ttl)
+	}
+	if ttl, _ := store.TTL(ctx, "hits"); ttl != 0 {
+		t.Errorf("expected no expiration, got %s", ttl)
+	}
+	if _, err = store.TTL(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if ok, _ := store.Touch(ctx, "a", 0); !ok {
+		t.Error("expected touch to find the key")
+	}
+	if ttl, _ := store.TTL(ctx, "a"); ttl != 0 {
+		t.Errorf("expected the expiration to be removed, got %s", ttl)
+	}
+
+	_ = store.DeleteMany(ctx, "a", "b")
+	if values, _ = store.GetMany(ctx, []string{"a", "b"}); len(values) != 0 {
+		t.Errorf("expected the keys to be deleted, got %v", values)
+	}
+}
+
+func TestTieredStoreInvalidation(t *testing.T) {
+	ctx := context.Background()
+	remote, _ := newTestRedisStore(t, "app:")
+
+	newTier := func() *TieredStore {
+		local := NewMemoryStore(MemoryConfig{})
+		store, err := NewTieredStore(local, remote, NewRedisBus(remote.Client(), "app:invalidations"), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = store.Close() })
+		return store
+	}
+	a, b := newTier(), newTier()
+
+	_ = a.Set(ctx, "user:1", "v1", 0)
+	if value, _ := b.Get(ctx, "user:1"); value != "v1" {
+		t.Fatalf("expected v1, got %q", value)
+	}
+
+	_ = a.Set(ctx, "user:1", "v2", 0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := b.Local().Get(ctx, "user:1"); errors.Is(err, ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the local copy of the other instance to be invalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if value, _ := b.Get(ctx, "user:1"); value != "v2" {
+		t.Fatalf("expected v2, got %q", value)
+	}
+}
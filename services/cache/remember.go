@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/logger"
+	"golang.org/x/sync/singleflight"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// flights deduplicates concurrent computations per store and key
+var flights singleflight.Group
+
+// refreshing holds the keys refreshed in the background, one goroutine each
+var refreshing sync.Map
+
+// rememberEnvelope value stored by Remember with the metadata needed to refresh it
+type rememberEnvelope[T any] struct {
+	Value     T     `json:"v" msgpack:"v"`
+	ExpiresAt int64 `json:"e" msgpack:"e"` // unix milliseconds the value turns stale, 0 never
+	Delta     int64 `json:"d" msgpack:"d"` // milliseconds the last computation took
+}
+
+// fresh checks if the value has not expired at now
+func (e rememberEnvelope[T]) fresh(now int64) bool {
+	return e.ExpiresAt == 0 || now < e.ExpiresAt
+}
+
+// rememberOptions Remember options
+type rememberOptions struct {
+	store string
+	stale time.Duration
+	beta  float64
+}
+
+// RememberOption configures Remember
+type RememberOption func(*rememberOptions)
+
+// WithRememberStore uses a named store instead of the default one
+func WithRememberStore(name string) RememberOption {
+	return func(o *rememberOptions) {
+		o.store = name
+	}
+}
+
+// WithStale keeps a value for d after its ttl. Reads in that window return the
+// stale value at once and refresh it in the background.
+func WithStale(d time.Duration) RememberOption {
+	return func(o *rememberOptions) {
+		o.stale = d
+	}
+}
+
+// WithEarlyExpiration refreshes values in the background before they expire,
+// with a probability that grows near expiry and with the computation time
+// (XFetch). beta 1 is a good default, larger values refresh earlier.
+func WithEarlyExpiration(beta float64) RememberOption {
+	return func(o *rememberOptions) {
+		o.beta = beta
+	}
+}
+
+// Remember returns the cached value of key, or computes it with fn and caches it
+// for ttl. Concurrent misses of a key run fn once and share its result.
+func Remember[T any](ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) (T, error), opts ...RememberOption) (T, error) {
+	options := rememberOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var zero T
+	store, serializer, err := typedStore(options.store)
+	if err != nil {
+		return zero, err
+	}
+	if options.store == "" {
+		options.store = manager.DefaultName()
+	}
+
+	r := &rememberer[T]{
+		store:      store,
+		serializer: serializer,
+		flight:     options.store + "\x00" + key,
+		key:        key,
+		ttl:        ttl,
+		options:    options,
+		fn:         fn,
+	}
+	return r.get(ctx)
+}
+
+// RememberForever is Remember with values that never expire
+func RememberForever[T any](ctx context.Context, key string, fn func(ctx context.Context) (T, error), opts ...RememberOption) (T, error) {
+	return Remember(ctx, key, 0, fn, opts...)
+}
+
+// rememberer resolves a single Remember call
+type rememberer[T any] struct {
+	store      Cache
+	serializer Serializer
+	flight     string
+	key        string
+	ttl        time.Duration
+	options    rememberOptions
+	fn         func(ctx context.Context) (T, error)
+}
+
+// get reads the cached envelope and decides whether to serve, refresh or compute
+func (r *rememberer[T]) get(ctx context.Context) (T, error) {
+	envelope, err := r.load(ctx)
+	if err == nil {
+		now := time.Now().UnixMilli()
+		switch {
+		case envelope.ExpiresAt == 0:
+			return envelope.Value, nil
+		case now < envelope.ExpiresAt:
+			if r.options.beta > 0 && r.early(envelope, now) {
+				r.refresh(ctx, envelope.ExpiresAt)
+			}
+			return envelope.Value, nil
+		case now < envelope.ExpiresAt+r.options.stale.Milliseconds():
+			r.refresh(ctx, envelope.ExpiresAt)
+			return envelope.Value, nil
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		// An unreachable or corrupt cache degrades to computing the value
+		logger.ReportError("cache remember read failed", err)
+	}
+
+	// The leader computes for every waiter, one of them giving up must not fail the others
+	flight := flights.DoChan(r.flight, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		// A flight that finished since the read above may have cached the value
+		if envelope, err := r.load(ctx); err == nil && envelope.fresh(time.Now().UnixMilli()) {
+			return envelope.Value, nil
+		}
+		return r.compute(ctx)
+	})
+
+	var zero T
+	var result singleflight.Result
+	select {
+	case result = <-flight:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+	if result.Err != nil {
+		return zero, result.Err
+	}
+
+	// A concurrent caller remembering the same key as another type computes its own value
+	typed, ok := result.Val.(T)
+	if !ok {
+		return r.compute(ctx)
+	}
+	return typed, nil
+}
+
+// load reads and decodes the cached envelope
+func (r *rememberer[T]) load(ctx context.Context) (rememberEnvelope[T], error) {
+	var envelope rememberEnvelope[T]
+
+	raw, err := r.store.Get(ctx, r.key)
+	if err != nil {
+		return envelope, err
+	}
+
+	if err = r.serializer.Unmarshal([]byte(raw), &envelope); err != nil {
+		return envelope, fmt.Errorf("failed to decode cache key %s: %w", r.key, err)
+	}
+	return envelope, nil
+}
+
+// early decides whether to refresh a fresh value ahead of its expiry
+func (r *rememberer[T]) early(envelope rememberEnvelope[T], now int64) bool {
+	gap := float64(envelope.Delta) * r.options.beta * -math.Log(1-rand.Float64())
+	return float64(now)+gap >= float64(envelope.ExpiresAt)
+}
+
+// refresh recomputes the value read with expiresAt in the background, at most
+// once at a time per key. Reads while a refresh runs do not start another one.
+func (r *rememberer[T]) refresh(ctx context.Context, expiresAt int64) {
+	if _, busy := refreshing.LoadOrStore(r.flight, struct{}{}); busy {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer refreshing.Delete(r.flight)
+
+		_, err, _ := flights.Do(r.flight, func() (interface{}, error) {
+			// A flight that finished since the read may have refreshed the value
+			if envelope, err := r.load(ctx); err == nil && envelope.ExpiresAt > expiresAt {
+				return envelope.Value, nil
+			}
+			return r.compute(ctx)
+		})
+		if err != nil {
+			logger.ReportError("cache remember refresh failed", err)
+		}
+	}()
+}
+
+// compute runs fn and caches its result, a failed write still returns the value
+func (r *rememberer[T]) compute(ctx context.Context) (T, error) {
+	start := time.Now()
+	value, err := r.fn(ctx)
+	if err != nil {
+		return value, err
+	}
+
+	envelope := rememberEnvelope[T]{
+		Value: value,
+		Delta: time.Since(start).Milliseconds(),
+	}
+
+	expiration := time.Duration(0)
+	if r.ttl > 0 {
+		envelope.ExpiresAt = time.Now().Add(r.ttl).UnixMilli()
+		expiration = r.ttl + r.options.stale
+	}
+
+	data, err := r.serializer.Marshal(envelope)
+	if err == nil {
+		err = r.store.Set(ctx, r.key, data, expiration)
+	}
+	if err != nil {
+		logger.ReportError("cache remember write failed", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rememberStore installs a manager with a single memory store
+func rememberStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	store := NewMemoryStore(MemoryConfig{})
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	m := NewManager()
+	m.AddStore("memory", store)
+	SetManager(m)
+	t.Cleanup(func() {
+		SetManager(nil)
+	})
+	return store
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRemember(t *testing.T) {
+	ctx := context.Background()
+	store := rememberStore(t)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		<-release
+		return int(calls.Add(1)), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := Remember(ctx, "answer", time.Hour, fn); err != nil || v != 1 {
+				t.Errorf("expected 1, got %d (%v)", v, err)
+			}
+		}()
+	}
+	waitFor(t, "every caller to miss", func() bool {
+		return store.Stats().Misses >= 10
+	})
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected a single computation, got %d", calls.Load())
+	}
+	if v, _ := Remember(ctx, "answer", time.Hour, fn); v != 1 {
+		t.Fatalf("expected the cached value, got %d", v)
+	}
+}
+
+func TestRememberCancelledCaller(t *testing.T) {
+	store := rememberStore(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "computed", nil
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := Remember(leaderCtx, "key", time.Hour, fn)
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan string, 1)
+	go func() {
+		v, err := Remember(context.Background(), "key", time.Hour, fn)
+		if err != nil {
+			t.Errorf("expected the waiter to succeed, got %v", err)
+		}
+		waiter <- v
+	}()
+	waitFor(t, "the waiter to miss", func() bool {
+		return store.Stats().Misses >= 2
+	})
+
+	// The cancelled caller stops waiting, the computation goes on for the others
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to fail with its context error, got %v", err)
+	}
+	close(release)
+	if v := <-waiter; v != "computed" {
+		t.Fatalf("expected the computed value, got %q", v)
+	}
+}
+
+func TestRememberStale(t *testing.T) {
+	ctx := context.Background()
+	store := rememberStore(t)
+
+	// Cache a value that turned stale a second ago
+	_, serializer, _ := typedStore("")
+	data, _ := serializer.Marshal(rememberEnvelope[int]{Value: 1, ExpiresAt: time.Now().Add(-time.Second).UnixMilli()})
+	_ = store.Set(ctx, "answer", data, 0)
+
+	fn := func(ctx context.Context) (int, error) {
+		return 2, nil
+	}
+
+	// Stale values are served while the refresh runs in the background
+	if v, _ := Remember(ctx, "answer", time.Hour, fn, WithStale(time.Hour)); v != 1 {
+		t.Fatalf("expected the stale value, got %d", v)
+	}
+	waitFor(t, "the refreshed value", func() bool {
+		v, _ := Remember(ctx, "answer", time.Hour, fn, WithStale(time.Hour))
+		return v == 2
+	})
+}
+
+func TestRememberStaleRefreshesOnce(t *testing.T) {
+	ctx := context.Background()
+	store := rememberStore(t)
+
+	_, serializer, _ := typedStore("")
+	stale, _ := serializer.Marshal(rememberEnvelope[int]{Value: 1, ExpiresAt: time.Now().Add(-time.Second).UnixMilli()})
+	_ = store.Set(ctx, "answer", stale, 0)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 2, nil
+	}
+
+	// Stale reads while the refresh runs share it
+	for range 10 {
+		if v, _ := Remember(ctx, "answer", time.Hour, fn, WithStale(time.Hour)); v != 1 {
+			t.Fatalf("expected the stale value, got %d", v)
+		}
+	}
+	close(release)
+	waitFor(t, "the refresh to finish", func() bool {
+		_, busy := refreshing.Load("memory\x00answer")
+		return !busy
+	})
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single refresh, got %d", n)
+	}
+
+	// A stale read racing a finished refresh does not compute again
+	r := &rememberer[int]{
+		store:      store,
+		serializer: serializer,
+		flight:     "memory\x00answer",
+		key:        "answer",
+		ttl:        time.Hour,
+		fn:         fn,
+	}
+	r.refresh(ctx, time.Now().Add(-time.Second).UnixMilli())
+	waitFor(t, "the refresh to finish", func() bool {
+		_, busy := refreshing.Load("memory\x00answer")
+		return !busy
+	})
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the refreshed value to be reused, got %d computations", n)
+	}
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Serializer names
+const (
+	SerializerJSON    = "json"
+	SerializerMsgpack = "msgpack"
+	SerializerGob     = "gob"
+)
+
+// Serializer encodes typed values to the bytes kept in a store
+type Serializer interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONSerializer encodes values as JSON
+type JSONSerializer struct{}
+
+// Marshal encodes a value
+func (JSONSerializer) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes a value
+func (JSONSerializer) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackSerializer encodes values as MessagePack
+type MsgpackSerializer struct{}
+
+// Marshal encodes a value
+func (MsgpackSerializer) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal decodes a value
+func (MsgpackSerializer) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// GobSerializer encodes values with encoding/gob, interface fields need gob.Register
+type GobSerializer struct{}
+
+// Marshal encodes a value
+func (GobSerializer) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a value
+func (GobSerializer) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// NewSerializer returns a serializer by name, JSON when empty
+func NewSerializer(name string) (Serializer, error) {
+	switch name {
+	case "", SerializerJSON:
+		return JSONSerializer{}, nil
+	case SerializerMsgpack:
+		return MsgpackSerializer{}, nil
+	case SerializerGob:
+		return GobSerializer{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported cache serializer %s", name)
+	}
+}
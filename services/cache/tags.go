@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Taggable stores that index entries by tag so a tag can be flushed as a group.
+// A key written under a tag stays indexed until the tag is flushed, even when it
+// is rewritten without the tag in between, so flushing a tag removes every key
+// ever written under it.
+type Taggable interface {
+	SetTagged(ctx context.Context, tags []string, key string, value interface{}, expiration time.Duration) error
+	FlushTags(ctx context.Context, tags ...string) error
+}
+
+// TaggedCache writes entries under a set of tags. Reads go to the underlying
+// store, Flush removes every entry written under any of the tags.
+type TaggedCache struct {
+	store Cache
+	tags  []string
+	err   error
+}
+
+// NewTaggedCache creates a tagged view of a store, the store must implement Taggable
+func NewTaggedCache(store Cache, tags ...string) (*TaggedCache, error) {
+	if !Supports[Taggable](store) {
+		return nil, fmt.Errorf("cache store %T does not support tags", store)
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("at least one cache tag is required")
+	}
+	return &TaggedCache{store: store, tags: tags}, nil
+}
+
+// Tags returns a tagged view of the default store (Facade pattern)
+func Tags(tags ...string) *TaggedCache {
+	if manager == nil {
+		return &TaggedCache{err: fmt.Errorf("cache manager not initialized")}
+	}
+	return manager.Tags(manager.DefaultName(), tags...)
+}
+
+// Tags returns a tagged view of a store, errors are reported by its methods
+func (m *Manager) Tags(name string, tags ...string) *TaggedCache {
+	store, err := m.Store(name)
+	if err != nil {
+		return &TaggedCache{err: err}
+	}
+
+	tagged, err := NewTaggedCache(store, tags...)
+	if err != nil {
+		return &TaggedCache{err: err}
+	}
+	return tagged
+}
+
+// Get retrieves a value from cache, a missing key returns ErrNotFound
+func (t *TaggedCache) Get(ctx context.Context, key string) (string, error) {
+	if t.err != nil {
+		return "", t.err
+	}
+	return t.store.Get(ctx, key)
+}
+
+// Set stores a value in cache under the tags
+func (t *TaggedCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.store.(Taggable).SetTagged(ctx, t.tags, key, value, expiration)
+}
+
+// Delete removes a value from cache
+func (t *TaggedCache) Delete(ctx context.Context, key string) error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.store.Delete(ctx, key)
+}
+
+// Has checks if a key exists in cache
+func (t *TaggedCache) Has(ctx context.Context, key string) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+	return t.store.Has(ctx, key)
+}
+
+// Flush removes every entry written under any of the tags
+func (t *TaggedCache) Flush(ctx context.Context) error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.store.(Taggable).FlushTags(ctx, t.tags...)
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testTaggable checks the tag semantics every Taggable store shares
+func testTaggable(t *testing.T, store Cache) {
+	t.Helper()
+	ctx := context.Background()
+
+	users, err := NewTaggedCache(store, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, _ := NewTaggedCache(store, "orders")
+	both, _ := NewTaggedCache(store, "users", "orders")
+
+	_ = users.Set(ctx, "user:1", "a", 0)
+	_ = users.Set(ctx, "user:2", "b", 0)
+	_ = users.Set(ctx, "user:3", "c", 0)
+	_ = orders.Set(ctx, "order:1", "d", 0)
+	_ = both.Set(ctx, "shared", "e", 0)
+	_ = store.Set(ctx, "plain", "f", 0)
+
+	// Tag membership survives rewrites without the tag and deletes
+	_ = store.Set(ctx, "user:2", "untagged now", 0)
+	_ = store.Delete(ctx, "user:3")
+	_ = store.Set(ctx, "user:3", "untagged after delete", 0)
+
+	if err = users.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"user:1", "user:2", "user:3", "shared"} {
+		if ok, _ := store.Has(ctx, key); ok {
+			t.Errorf("expected %s to be flushed with its tag", key)
+		}
+	}
+	for _, key := range []string{"order:1", "plain"} {
+		if ok, _ := store.Has(ctx, key); !ok {
+			t.Errorf("expected %s to survive", key)
+		}
+	}
+
+	// Flushing empties the tag, later writes without it are kept
+	_ = store.Set(ctx, "user:1", "after flush", 0)
+	_ = users.Flush(ctx)
+	if ok, _ := store.Has(ctx, "user:1"); !ok {
+		t.Error("expected a key written after the tag was flushed to survive")
+	}
+
+	if value, _ := orders.Get(ctx, "order:1"); value != "d" {
+		t.Errorf("expected tagged reads to go to the store, got %q", value)
+	}
+	_ = orders.Flush(ctx)
+	if ok, _ := store.Has(ctx, "order:1"); ok {
+		t.Error("expected order:1 to be flushed")
+	}
+}
+
+func TestTaggableStores(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		store := NewMemoryStore(MemoryConfig{})
+		defer store.Close()
+
+		testTaggable(t, store)
+		if len(store.tags) != 0 {
+			t.Errorf("expected the tag index to be empty, got %d tags", len(store.tags))
+		}
+
+		// Tag indexes expire with their longest lived member
+		_ = store.SetTagged(context.Background(), []string{"short"}, "a", "1", time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		store.DeleteExpired()
+		if len(store.tags) != 0 {
+			t.Errorf("expected the expired tag index to be dropped, got %d tags", len(store.tags))
+		}
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		store, _ := newTestRedisStore(t, "app:")
+		testTaggable(t, store)
+	})
+
+	if _, err := NewTaggedCache(&FileStore{}, "users"); err == nil {
+		t.Error("expected a store without tag support to be rejected")
+	}
+}
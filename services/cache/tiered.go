@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-generator/sugar/services/logger"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+// TieredConfig two-tier store configuration
+type TieredConfig struct {
+	Remote   string        `validate:"required"`       // store read through and written to, e.g. redis
+	LocalTTL int           `validate:"omitempty,gt=0"` // seconds a local copy is kept, bounds staleness when invalidations are lost, defaults to 60
+	Channel  string        `validate:"omitempty"`      // pub/sub channel of a Redis remote, defaults to the remote prefix followed by cache:invalidations
+	Memory   *MemoryConfig `validate:"omitempty"`      // bounds of the local tier
+}
+
+// TieredStore layers an in-process store in front of a remote one. Writes go to
+// both tiers and are broadcast on the bus so other instances drop their copies.
+type TieredStore struct {
+	local    Cache
+	remote   Cache
+	bus      InvalidationBus
+	localTTL time.Duration
+	origin   string
+}
+
+// NewTieredStore creates a tiered store and subscribes to the bus, a nil bus keeps invalidations local
+func NewTieredStore(local, remote Cache, bus InvalidationBus, localTTL time.Duration) (*TieredStore, error) {
+	if localTTL <= 0 {
+		localTTL = time.Minute
+	}
+
+	t := &TieredStore{
+		local:    local,
+		remote:   remote,
+		bus:      bus,
+		localTTL: localTTL,
+		origin:   uuid.NewString(),
+	}
+
+	if bus != nil {
+		if err := bus.Subscribe(context.Background(), t.invalidate); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Local returns the in-process tier
+func (t *TieredStore) Local() Cache {
+	return t.local
+}
+
+// Remote returns the remote tier
+func (t *TieredStore) Remote() Cache {
+	return t.remote
+}
+
+// invalidate drops local copies named by another instance
+func (t *TieredStore) invalidate(invalidation Invalidation) {
+	if invalidation.Origin == t.origin {
+		return
+	}
+
+	ctx := context.Background()
+	if invalidation.Flush {
+		_ = t.local.Flush(ctx)
+		return
+	}
+	for _, key := range invalidation.Keys {
+		_ = t.local.Delete(ctx, key)
+	}
+}
+
+// publish broadcasts an invalidation, failures leave other instances stale until their local ttl
+func (t *TieredStore) publish(ctx context.Context, invalidation Invalidation) {
+	if t.bus == nil {
+		return
+	}
+
+	invalidation.Origin = t.origin
+	if err := t.bus.Publish(ctx, invalidation); err != nil {
+		logger.ReportError("cache invalidation publish failed", err)
+	}
+}
+
+// localExpiration caps an expiration to the local ttl
+func (t *TieredStore) localExpiration(expiration time.Duration) time.Duration {
+	if expiration > 0 && expiration < t.localTTL {
+		return expiration
+	}
+	return t.localTTL
+}
+
+// Get retrieves a value from the local tier, falling back to the remote one
+func (t *TieredStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := t.local.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		logger.ReportError("cache local tier read failed", err)
+	}
+
+	value, err = t.remote.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	if expiration, ok := t.readThroughExpiration(ctx, key); ok {
+		_ = t.local.Set(ctx, key, value, expiration)
+	}
+	return value, nil
+}
+
+// readThroughExpiration caps the local copy of a remote value to the time the
+// remote keeps it, false when the value is gone already
+func (t *TieredStore) readThroughExpiration(ctx context.Context, key string) (time.Duration, bool) {
+	if !Supports[Expirer](t.remote) {
+		return t.localTTL, true
+	}
+
+	remaining, err := t.remote.(Expirer).TTL(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			logger.ReportError("cache remote tier ttl read failed", err)
+		}
+		return 0, false
+	}
+	if remaining < 0 {
+		return 0, false
+	}
+	return t.localExpiration(remaining), true
+}
+
+// Set stores a value in both tiers and invalidates other instances
+func (t *TieredStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := t.remote.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+
+	if err := t.local.Set(ctx, key, value, t.localExpiration(expiration)); err != nil {
+		_ = t.local.Delete(ctx, key)
+	}
+	t.publish(ctx, Invalidation{Keys: []string{key}})
+	return nil
+}
+
+// Delete removes a value from both tiers and invalidates other instances
+func (t *TieredStore) Delete(ctx context.Context, key string) error {
+	_ = t.local.Delete(ctx, key)
+	if err := t.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	t.publish(ctx, Invalidation{Keys: []string{key}})
+	return nil
+}
+
+// Has checks if a key exists in either tier
+func (t *TieredStore) Has(ctx context.Context, key string) (bool, error) {
+	if ok, err := t.local.Has(ctx, key); err == nil && ok {
+		return true, nil
+	}
+	return t.remote.Has(ctx, key)
+}
+
+// Flush clears both tiers and the local tier of other instances
+func (t *TieredStore) Flush(ctx context.Context) error {
+	_ = t.local.Flush(ctx)
+	if err := t.remote.Flush(ctx); err != nil {
+		return err
+	}
+
+	t.publish(ctx, Invalidation{Flush: true})
+	return nil
+}
+
+// Close stops the subscription and closes the local tier, the remote store is left open
+func (t *TieredStore) Close() error {
+	if t.bus != nil {
+		if err := t.bus.Close(); err != nil {
+			return err
+		}
+	}
+	if closer, ok := t.local.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestTieredStore creates a bus-less tiered store over two memory stores
+func newTestTieredStore(t *testing.T, localTTL time.Duration) (*TieredStore, *MemoryStore, *MemoryStore) {
+	t.Helper()
+	local, remote := NewMemoryStore(MemoryConfig{}), NewMemoryStore(MemoryConfig{})
+	store, err := NewTieredStore(local, remote, nil, localTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = store.Close()
+		_ = remote.Close()
+	})
+	return store, local, remote
+}
+
+func TestTieredStoreReadThrough(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		remote time.Duration // expiration of the remote value, 0 never expires
+		want   time.Duration // expected local ttl
+	}{
+		{name: "remote without expiration", remote: 0, want: time.Minute},
+		{name: "remote outliving the local ttl", remote: time.Hour, want: time.Minute},
+		{name: "remote expiring first", remote: 10 * time.Second, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, local, remote := newTestTieredStore(t, time.Minute)
+			_ = remote.Set(ctx, "user:1", "v1", tt.remote)
+
+			if value, err := store.Get(ctx, "user:1"); err != nil || value != "v1" {
+				t.Fatalf("expected v1, got %q, %v", value, err)
+			}
+			ttl, err := local.TTL(ctx, "user:1")
+			if err != nil {
+				t.Fatalf("expected a local copy, got %v", err)
+			}
+			if ttl > tt.want || ttl < tt.want-time.Second {
+				t.Errorf("expected a local ttl of %s, got %s", tt.want, ttl)
+			}
+		})
+	}
+
+	store, local, _ := newTestTieredStore(t, time.Minute)
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if ok, _ := local.Has(ctx, "missing"); ok {
+		t.Error("expected a miss not to be cached locally")
+	}
+}
+
+func TestTieredStoreWithoutBus(t *testing.T) {
+	ctx := context.Background()
+	store, local, remote := newTestTieredStore(t, time.Minute)
+
+	if err := store.Set(ctx, "a", "1", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for name, tier := range map[string]*MemoryStore{"local": local, "remote": remote} {
+		if value, _ := tier.Get(ctx, "a"); value != "1" {
+			t.Errorf("expected the %s tier to hold the value, got %q", name, value)
+		}
+	}
+	if ttl, _ := local.TTL(ctx, "a"); ttl > 10*time.Second {
+		t.Errorf("expected the local copy to expire with the entry, got %s", ttl)
+	}
+
+	// A local copy is served without the remote
+	_ = remote.Delete(ctx, "a")
+	if value, _ := store.Get(ctx, "a"); value != "1" {
+		t.Errorf("expected the local copy, got %q", value)
+	}
+	if ok, _ := store.Has(ctx, "a"); !ok {
+		t.Error("expected the local copy to be found")
+	}
+
+	_ = store.Set(ctx, "b", "2", 0)
+	if err := store.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.Has(ctx, "b"); ok {
+		t.Error("expected the key to be deleted from both tiers")
+	}
+
+	_ = store.Set(ctx, "c", "3", 0)
+	if err := store.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if local.Stats().Entries != 0 || remote.Stats().Entries != 0 {
+		t.Errorf("expected both tiers to be flushed, got %d and %d entries", local.Stats().Entries, remote.Stats().Entries)
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GetAs retrieves a value from the default store and decodes it with the store serializer
+func GetAs[T any](ctx context.Context, key string) (T, error) {
+	return GetAsFrom[T](ctx, "", key)
+}
+
+// SetAs encodes a value with the default store serializer and stores it
+func SetAs[T any](ctx context.Context, key string, value T, expiration time.Duration) error {
+	return SetAsIn(ctx, "", key, value, expiration)
+}
+
+// GetAsFrom retrieves and decodes a value from a named store, the default store when empty
+func GetAsFrom[T any](ctx context.Context, store, key string) (T, error) {
+	var value T
+
+	cache, serializer, err := typedStore(store)
+	if err != nil {
+		return value, err
+	}
+
+	raw, err := cache.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+
+	if err = serializer.Unmarshal([]byte(raw), &value); err != nil {
+		return value, fmt.Errorf("failed to decode cache key %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetAsIn encodes and stores a value in a named store, the default store when empty
+func SetAsIn[T any](ctx context.Context, store, key string, value T, expiration time.Duration) error {
+	cache, serializer, err := typedStore(store)
+	if err != nil {
+		return err
+	}
+
+	data, err := serializer.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache key %s: %w", key, err)
+	}
+	return cache.Set(ctx, key, data, expiration)
+}
+
+// typedStore resolves a store and its serializer from the global manager
+func typedStore(name string) (Cache, Serializer, error) {
+	if manager == nil {
+		return nil, nil, fmt.Errorf("cache manager not initialized")
+	}
+
+	if name == "" {
+		name = manager.DefaultName()
+	}
+	store, err := manager.Store(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, manager.Serializer(name), nil
+}
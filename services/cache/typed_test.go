@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type typedUser struct {
+	ID   int
+	Name string
+}
+
+func TestTypedSerializers(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range []string{SerializerJSON, SerializerMsgpack, SerializerGob} {
+		store := NewMemoryStore(MemoryConfig{})
+		serializer, err := NewSerializer(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := NewManager()
+		m.AddStore("memory", store)
+		m.SetSerializer("memory", serializer)
+		SetManager(m)
+
+		if err = SetAs(ctx, "user", typedUser{ID: 1, Name: "sugar"}, 0); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		user, err := GetAs[typedUser](ctx, "user")
+		if err != nil || user.ID != 1 || user.Name != "sugar" {
+			t.Fatalf("%s: unexpected %+v (%v)", name, user, err)
+		}
+
+		if _, err = GetAs[typedUser](ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: expected ErrNotFound, got %v", name, err)
+		}
+		_ = store.Close()
+	}
+	SetManager(nil)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"gorm.io/gorm"
 )
@@ -28,3 +29,19 @@ func Connection(name string) (*gorm.DB, error) {
 	}
 	return manager.Connection(name)
 }
+
+// Global tenant manager instance
+var tenants *TenantManager
+
+// SetTenantManager sets the global tenant manager
+func SetTenantManager(t *TenantManager) {
+	tenants = t
+}
+
+// Tenant gets the connection of the tenant carried by ctx (Facade pattern)
+func Tenant(ctx context.Context) (*gorm.DB, error) {
+	if tenants == nil {
+		return nil, fmt.Errorf("tenant manager not initialized")
+	}
+	return tenants.DB(ctx)
+}
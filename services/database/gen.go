@@ -0,0 +1,178 @@
+package database
+
+import (
+	"fmt"
+	"gorm.io/gen"
+	"gorm.io/gorm"
+	"path"
+	"strings"
+)
+
+// GenConfig gorm/gen code generation configuration with validation tags
+type GenConfig struct {
+	Connection        string            `validate:"required"`
+	OutPath           string            `validate:"required"`  // query package directory, e.g. ./query
+	ModelPath         string            `validate:"omitempty"` // model package directory, defaults to model next to OutPath
+	Include           []string          `validate:"omitempty"` // table name patterns to generate, all tables when empty
+	Exclude           []string          `validate:"omitempty"` // table name patterns to skip
+	TypeMapping       map[string]string `validate:"omitempty"` // database column type to Go type, e.g. tinyint: bool
+	Imports           []string          `validate:"omitempty"` // packages required by mapped types
+	FieldNullable     bool              `validate:"omitempty"`
+	FieldSignable     bool              `validate:"omitempty"`
+	FieldWithIndexTag bool              `validate:"omitempty"`
+	FieldWithTypeTag  bool              `validate:"omitempty"`
+	WithoutContext    bool              `validate:"omitempty"`
+}
+
+// genSource the connection code is generated from
+type genSource struct {
+	name   string
+	driver string
+	mysql  MysqlConfig
+	pgsql  PgsqlConfig
+}
+
+// selectGenSource looks the connection up in the MySQL then the PostgresSQL configurations
+func selectGenSource(name string, mysql map[string]MysqlConfig, pgsql map[string]PgsqlConfig) (genSource, error) {
+	if name == "" {
+		return genSource{}, fmt.Errorf("no connection configured for code generation")
+	}
+
+	if cfg, ok := mysql[name]; ok {
+		// Same as the database provider, MySQL connections are named after their database
+		cfg.Database = name
+		return genSource{name: name, driver: "mysql", mysql: cfg}, nil
+	}
+
+	if cfg, ok := pgsql[name]; ok {
+		return genSource{name: name, driver: "postgres", pgsql: cfg}, nil
+	}
+
+	return genSource{}, fmt.Errorf("database connection %s not found", name)
+}
+
+// dsn returns the DSN the connection is opened with
+func (s genSource) dsn() string {
+	if s.driver == "mysql" {
+		return mysqlDSN(s.name, s.mysql)
+	}
+	return pgsqlDSN(s.pgsql)
+}
+
+// open opens the connection
+func (s genSource) open() (*gorm.DB, error) {
+	if s.driver == "mysql" {
+		return NewMysqlConnection(s.name, s.mysql)
+	}
+	return NewPgsqlConnection(s.name, s.pgsql)
+}
+
+// OpenGenConnection opens the named connection the same way the database provider does
+func OpenGenConnection(name string, mysql map[string]MysqlConfig, pgsql map[string]PgsqlConfig) (*gorm.DB, error) {
+	source, err := selectGenSource(name, mysql, pgsql)
+	if err != nil {
+		return nil, err
+	}
+	return source.open()
+}
+
+// Generate introspects the tables of db and generates models and type-safe query code
+func Generate(db *gorm.DB, cfg GenConfig) (err error) {
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	tables, err = filterTables(tables, cfg.Include, cfg.Exclude)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("no table matches the include/exclude lists")
+	}
+
+	mode := gen.WithDefaultQuery | gen.WithQueryInterface
+	if cfg.WithoutContext {
+		mode |= gen.WithoutContext
+	}
+
+	g := gen.NewGenerator(gen.Config{
+		OutPath:           cfg.OutPath,
+		ModelPkgPath:      cfg.ModelPath,
+		Mode:              mode,
+		FieldNullable:     cfg.FieldNullable,
+		FieldSignable:     cfg.FieldSignable,
+		FieldWithIndexTag: cfg.FieldWithIndexTag,
+		FieldWithTypeTag:  cfg.FieldWithTypeTag,
+	})
+	g.UseDB(db)
+
+	if len(cfg.TypeMapping) > 0 {
+		mapping := make(map[string]func(gorm.ColumnType) string, len(cfg.TypeMapping))
+		for columnType, goType := range cfg.TypeMapping {
+			mapping[strings.ToLower(columnType)] = func(gorm.ColumnType) string {
+				return goType
+			}
+		}
+		g.WithDataTypeMap(mapping)
+	}
+	if len(cfg.Imports) > 0 {
+		g.WithImportPkgPath(cfg.Imports...)
+	}
+
+	// gorm/gen panics on failure
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to generate code: %v", r)
+		}
+	}()
+
+	models := make([]any, 0, len(tables))
+	for _, table := range tables {
+		if model := g.GenerateModel(table); model != nil {
+			models = append(models, model)
+		}
+	}
+	g.ApplyBasic(models...)
+	g.Execute()
+
+	return nil
+}
+
+// filterTables applies include and exclude glob patterns to table names
+func filterTables(tables, include, exclude []string) ([]string, error) {
+	match := func(patterns []string, table string) (bool, error) {
+		for _, pattern := range patterns {
+			ok, err := path.Match(pattern, table)
+			if err != nil {
+				return false, fmt.Errorf("invalid table pattern %s: %w", pattern, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	result := make([]string, 0, len(tables))
+	for _, table := range tables {
+		if len(include) > 0 {
+			ok, err := match(include, table)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		skip, err := match(exclude, table)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			result = append(result, table)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterTables(t *testing.T) {
+	tables := []string{"users", "user_roles", "orders", "order_items", "migrations"}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", want: tables},
+		{name: "include", include: []string{"user*"}, want: []string{"users", "user_roles"}},
+		{name: "include several", include: []string{"users", "order_*"}, want: []string{"users", "order_items"}},
+		{name: "exclude", exclude: []string{"migrations", "*_items"}, want: []string{"users", "user_roles", "orders"}},
+		{name: "exclude wins", include: []string{"order*"}, exclude: []string{"order_items"}, want: []string{"orders"}},
+		{name: "no match", include: []string{"products"}, want: []string{}},
+		{name: "invalid include", include: []string{"[users"}, wantErr: true},
+		{name: "invalid exclude", exclude: []string{"[users"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterTables(tables, tt.include, tt.exclude)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectGenSource(t *testing.T) {
+	mysql := map[string]MysqlConfig{
+		"shop": {Host: "127.0.0.1", Port: 3306, Username: "root", Password: "secret", Charset: "utf8mb4", ParseTime: true, Loc: "Local"},
+		"both": {Host: "127.0.0.2", Port: 3306, Username: "root", Password: "secret", Charset: "utf8mb4", Loc: "UTC"},
+	}
+	pgsql := map[string]PgsqlConfig{
+		"crm":  {Host: "127.0.0.1", Port: 5432, Database: "crm_db", Username: "postgres", Password: "secret", Timezone: "UTC"},
+		"both": {Host: "127.0.0.3", Port: 5432, Database: "both", Username: "postgres", Password: "secret", Timezone: "UTC"},
+		"tenant": {
+			Host: "127.0.0.1", Port: 5432, Database: "app", Username: "postgres", Password: "secret", Timezone: "UTC",
+			SearchPath: "tenant_acme",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		connection string
+		driver     string
+		dsn        string
+		wantErr    bool
+	}{
+		{
+			name:       "mysql named after its database",
+			connection: "shop",
+			driver:     "mysql",
+			dsn:        "root:secret@tcp(127.0.0.1:3306)/shop?charset=utf8mb4&parseTime=true&multiStatements=false&loc=Local",
+		},
+		{
+			name:       "pgsql",
+			connection: "crm",
+			driver:     "postgres",
+			dsn:        "host=127.0.0.1 user=postgres password=secret dbname=crm_db port=5432 sslmode=disable TimeZone=UTC",
+		},
+		{
+			name:       "pgsql search path",
+			connection: "tenant",
+			driver:     "postgres",
+			dsn:        "host=127.0.0.1 user=postgres password=secret dbname=app port=5432 sslmode=disable TimeZone=UTC search_path=tenant_acme",
+		},
+		{
+			name:       "mysql before pgsql",
+			connection: "both",
+			driver:     "mysql",
+			dsn:        "root:secret@tcp(127.0.0.2:3306)/both?charset=utf8mb4&parseTime=false&multiStatements=false&loc=UTC",
+		},
+		{name: "missing", connection: "analytics", wantErr: true},
+		{name: "empty", connection: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := selectGenSource(tt.connection, mysql, pgsql)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", source)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source.driver != tt.driver {
+				t.Errorf("driver: got %s, want %s", source.driver, tt.driver)
+			}
+			if dsn := source.dsn(); dsn != tt.dsn {
+				t.Errorf("dsn: got %s, want %s", dsn, tt.dsn)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	l "github.com/gin-generator/logger"
+	"github.com/gin-generator/sugar/services/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+	"time"
+)
+
+// Log channels of the SQL logger
+const (
+	ChannelApp  = "app"  // the application logger, logger.Log
+	ChannelFile = "file" // a dedicated storage/logs/<connection>.log file
+)
+
+// tracerName instrumentation name of the SQL spans
+const tracerName = "github.com/gin-generator/sugar/services/database"
+
+// GormLogger structured gorm logger shared by all connections
+type GormLogger struct {
+	name          string
+	system        string
+	log           *zap.Logger
+	level         glogger.LogLevel
+	slowThreshold time.Duration
+	stats         *QueryStats
+	tracer        trace.Tracer
+}
+
+// NewGormLogger creates the gorm logger of a connection, system is the database
+// system reported on spans (mysql, postgresql)
+func NewGormLogger(name, system string, cfg LoggerConfig) *GormLogger {
+	g := &GormLogger{
+		name:          name,
+		system:        system,
+		level:         gormLevel(cfg.Level),
+		slowThreshold: time.Duration(cfg.SlowThreshold) * time.Millisecond,
+		stats:         statsFor(name),
+	}
+
+	if cfg.Channel == ChannelApp && logger.Log != nil {
+		g.log = logger.Log.Log
+	} else {
+		compress := false
+		if cfg.Compress != nil {
+			compress = *cfg.Compress
+		}
+
+		localTime := false
+		if cfg.LocalTime != nil {
+			localTime = *cfg.LocalTime
+		}
+
+		g.log = l.NewLogger(
+			l.WithFileName(fmt.Sprintf("storage/logs/%s.log", name)),
+			l.WithLevel(cfg.Level),
+			l.WithTimeZone(localTime),
+			l.WithMaxSize(cfg.MaxSize),
+			l.WithMaxBackup(cfg.MaxBackup),
+			l.WithMaxAge(cfg.MaxAge),
+			l.WithCompress(compress),
+		).Log
+	}
+	g.log = g.log.With(zap.String("connection", name))
+
+	if cfg.Tracing {
+		g.tracer = otel.Tracer(tracerName)
+	}
+
+	return g
+}
+
+// newGormConfig builds the gorm configuration of a connection
+func newGormConfig(name, system string, cfg *LoggerConfig) *gorm.Config {
+	if cfg == nil {
+		return &gorm.Config{}
+	}
+	return &gorm.Config{Logger: NewGormLogger(name, system, *cfg)}
+}
+
+// gormLevel maps a configured level to a gorm log level
+func gormLevel(level string) glogger.LogLevel {
+	switch level {
+	case "error":
+		return glogger.Error
+	case "warn":
+		return glogger.Warn
+	default:
+		return glogger.Info
+	}
+}
+
+// LogMode sets the logging level
+func (g *GormLogger) LogMode(level glogger.LogLevel) glogger.Interface {
+	clone := *g
+	clone.level = level
+	return &clone
+}
+
+// Info logs informational messages
+func (g *GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if g.level >= glogger.Info {
+		g.with(ctx).Sugar().Infof(msg, args...)
+	}
+}
+
+// Warn logs warning messages
+func (g *GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if g.level >= glogger.Warn {
+		g.with(ctx).Sugar().Warnf(msg, args...)
+	}
+}
+
+// Error logs error messages
+func (g *GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if g.level >= glogger.Error {
+		g.with(ctx).Sugar().Errorf(msg, args...)
+	}
+}
+
+// Trace logs a statement, records slow statements and emits a span
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level <= glogger.Silent && g.tracer == nil {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	slow := g.slowThreshold > 0 && elapsed > g.slowThreshold
+
+	var entry *zapcore.CheckedEntry
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && g.level >= glogger.Error:
+		entry = g.log.Check(zapcore.ErrorLevel, "Database Error")
+	case slow && g.level >= glogger.Warn:
+		entry = g.log.Check(zapcore.WarnLevel, "Database Slow Log")
+	case g.level >= glogger.Info:
+		entry = g.log.Check(zapcore.InfoLevel, "Database Query")
+	}
+
+	// Rendering the statement and finding the caller are the costly parts
+	if entry == nil && !slow && g.tracer == nil {
+		return
+	}
+
+	sql, rows := fc()
+	if slow {
+		g.stats.record(sql, elapsed)
+	}
+	if g.tracer != nil {
+		g.span(ctx, begin, sql, rows, err)
+	}
+	if entry == nil {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.String("time", fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6)),
+		zap.Int64("rows", rows),
+		zap.String("source", utils.FileWithLineNum()),
+	}
+	if id, ok := logger.RequestIDFromContext(ctx); ok {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if entry.Level == zapcore.ErrorLevel {
+		fields = append(fields, zap.Error(err))
+	}
+	entry.Write(fields...)
+}
+
+// with tags the logger with the request ID carried by ctx
+func (g *GormLogger) with(ctx context.Context) *zap.Logger {
+	if id, ok := logger.RequestIDFromContext(ctx); ok {
+		return g.log.With(zap.String("request_id", id))
+	}
+	return g.log
+}
+
+// span records the statement as a span that started at begin
+func (g *GormLogger) span(ctx context.Context, begin time.Time, sql string, rows int64, err error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", g.system),
+		attribute.String("db.name", g.name),
+		attribute.String("db.statement", sql),
+		attribute.Int64("db.rows_affected", rows),
+	}
+	if id, ok := logger.RequestIDFromContext(ctx); ok {
+		attrs = append(attrs, attribute.String("request.id", id))
+	}
+
+	_, span := g.tracer.Start(ctx, "db "+statementVerb(sql),
+		trace.WithTimestamp(begin),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
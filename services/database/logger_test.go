@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-generator/sugar/services/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM `users` WHERE id = 42", "SELECT * FROM `users` WHERE id = ?"},
+		{"SELECT * FROM users WHERE name = 'o''brien' AND score > 1.5", "SELECT * FROM users WHERE name = ? AND score > ?"},
+		{`SELECT * FROM users WHERE name = 'a\'b'`, "SELECT * FROM users WHERE name = ?"},
+		{"SELECT * FROM t1 WHERE id IN (1, 2, 3)", "SELECT * FROM t1 WHERE id IN (?)"},
+		{"SELECT * FROM t1 WHERE id in ('a','b')", "SELECT * FROM t1 WHERE id IN (?)"},
+		{"INSERT INTO t (a,b) VALUES (1,'x'),(2,'y')", "INSERT INTO t (a,b) VALUES (?)"},
+		{"SELECT  *\n\tFROM t   LIMIT 10", "SELECT * FROM t LIMIT ?"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSQL(tt.sql); got != tt.want {
+			t.Errorf("NormalizeSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	if verb := statementVerb("  select(1)"); verb != "SELECT" {
+		t.Errorf("expected SELECT, got %s", verb)
+	}
+}
+
+// observedLogger creates a gorm logger writing to an observer
+func observedLogger(name string, level glogger.LogLevel, slow time.Duration) (*GormLogger, *observer.ObservedLogs) {
+	// Fresh statistics keep repeated runs independent
+	statsMu.Lock()
+	delete(statistics, name)
+	statsMu.Unlock()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	return &GormLogger{
+		name:          name,
+		system:        "mysql",
+		log:           zap.New(core).With(zap.String("connection", name)),
+		level:         level,
+		slowThreshold: slow,
+		stats:         statsFor(name),
+	}, logs
+}
+
+func TestGormLoggerTrace(t *testing.T) {
+	g, logs := observedLogger("trace-test", glogger.Info, 50*time.Millisecond)
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	query := func(sql string) func() (string, int64) {
+		return func() (string, int64) {
+			return sql, 1
+		}
+	}
+
+	g.Trace(ctx, time.Now(), query("SELECT 1"), nil)
+	g.Trace(ctx, time.Now().Add(-100*time.Millisecond), query("SELECT * FROM users WHERE id = 1"), nil)
+	g.Trace(ctx, time.Now().Add(-200*time.Millisecond), query("SELECT * FROM users WHERE id = 2"), nil)
+	g.Trace(ctx, time.Now(), query("SELECT * FROM users WHERE id = 3"), gorm.ErrRecordNotFound)
+	g.Trace(ctx, time.Now(), query("INSERT INTO users"), errors.New("duplicate entry"))
+
+	entries := logs.All()
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(entries))
+	}
+	want := []struct {
+		level   zapcore.Level
+		message string
+	}{
+		{zapcore.InfoLevel, "Database Query"},
+		{zapcore.WarnLevel, "Database Slow Log"},
+		{zapcore.WarnLevel, "Database Slow Log"},
+		{zapcore.InfoLevel, "Database Query"},
+		{zapcore.ErrorLevel, "Database Error"},
+	}
+	for i, entry := range entries {
+		if entry.Level != want[i].level || entry.Message != want[i].message {
+			t.Errorf("entry %d: expected %s %q, got %s %q", i, want[i].level, want[i].message, entry.Level, entry.Message)
+		}
+		fields := entry.ContextMap()
+		if fields["request_id"] != "req-1" || fields["connection"] != "trace-test" {
+			t.Errorf("entry %d: expected request and connection tags, got %v", i, fields)
+		}
+	}
+	if entries[4].ContextMap()["error"] != "duplicate entry" {
+		t.Errorf("expected the error to be logged, got %v", entries[4].ContextMap())
+	}
+
+	stats := SlowQueryStats("trace-test")
+	if len(stats) != 1 || stats[0].Statement != "SELECT * FROM users WHERE id = ?" || stats[0].Count != 2 {
+		t.Fatalf("expected both slow executions under one statement, got %+v", stats)
+	}
+	if stats[0].Max < 200*time.Millisecond || stats[0].Average() < 150*time.Millisecond {
+		t.Errorf("unexpected durations %+v", stats[0])
+	}
+
+	// Warn level drops plain queries but keeps slow queries and errors
+	g, logs = observedLogger("trace-warn", glogger.Warn, 50*time.Millisecond)
+	g.Trace(ctx, time.Now(), query("SELECT 1"), nil)
+	g.Trace(ctx, time.Now().Add(-time.Second), query("SELECT 2"), nil)
+	g.Trace(ctx, time.Now(), query("SELECT 3"), errors.New("boom"))
+	if logs.Len() != 2 {
+		t.Errorf("expected 2 entries at warn level, got %d", logs.Len())
+	}
+
+	// An info level app logger still sees queries of an Info mode gorm logger
+	core, infoLogs := observer.New(zapcore.InfoLevel)
+	info, _ := observedLogger("trace-info", glogger.Info, 50*time.Millisecond)
+	info.log = zap.New(core)
+	info.Trace(ctx, time.Now(), query("SELECT 5"), nil)
+	if infoLogs.Len() != 1 || infoLogs.All()[0].Message != "Database Query" {
+		t.Errorf("expected the query at info level, got %v", infoLogs.All())
+	}
+
+	// Nothing renders the statement when no entry is enabled and nothing is slow
+	core, _ = observer.New(zapcore.ErrorLevel)
+	info.log = zap.New(core)
+	info.Trace(ctx, time.Now(), func() (string, int64) {
+		t.Error("expected the statement not to be rendered")
+		return "", 0
+	}, nil)
+
+	silent := g.LogMode(glogger.Silent)
+	silent.Trace(ctx, time.Now(), query("SELECT 4"), errors.New("boom"))
+	if logs.Len() != 2 {
+		t.Errorf("expected a silent logger to log nothing, got %d entries", logs.Len())
+	}
+}
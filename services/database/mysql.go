@@ -2,7 +2,6 @@ package database
 
 import (
 	"fmt"
-	l "github.com/gin-generator/logger"
 	_mysql "gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"time"
@@ -35,17 +34,18 @@ type LoggerConfig struct {
 	Compress      *bool  `validate:"omitempty"`
 	LocalTime     *bool  `validate:"omitempty"`
 	SlowThreshold int    `validate:"required,gt=0"` // milliseconds
+	Channel       string `validate:"omitempty,oneof=app file"`
+	Tracing       bool   `validate:"omitempty"` // emit OpenTelemetry spans
 }
 
-// NewMysqlConnection creates a MySQL connection
-func NewMysqlConnection(name string, cfg MysqlConfig) (*gorm.DB, error) {
-	// Use provided database name or fallback to name parameter
+// mysqlDSN builds the DSN of a MySQL connection, the database defaults to the connection name
+func mysqlDSN(name string, cfg MysqlConfig) string {
 	dbName := cfg.Database
 	if dbName == "" {
 		dbName = name
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%v&multiStatements=%v&loc=%s",
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%v&multiStatements=%v&loc=%s",
 		cfg.Username,
 		cfg.Password,
 		cfg.Host,
@@ -56,40 +56,16 @@ func NewMysqlConnection(name string, cfg MysqlConfig) (*gorm.DB, error) {
 		cfg.MultiStatements,
 		cfg.Loc,
 	)
+}
 
+// NewMysqlConnection creates a MySQL connection
+func NewMysqlConnection(name string, cfg MysqlConfig) (*gorm.DB, error) {
 	dbConfig := _mysql.New(_mysql.Config{
-		DSN:                       dsn,
+		DSN:                       mysqlDSN(name, cfg),
 		SkipInitializeWithVersion: cfg.SkipVersion,
 	})
 
-	var gormConfig *gorm.Config
-	if cfg.Logger != nil {
-		compress := false
-		if cfg.Logger.Compress != nil {
-			compress = *cfg.Logger.Compress
-		}
-
-		localTime := false
-		if cfg.Logger.LocalTime != nil {
-			localTime = *cfg.Logger.LocalTime
-		}
-
-		logger := l.NewLogger(
-			l.WithFileName(fmt.Sprintf("storage/logs/%s.log", name)),
-			l.WithLevel(cfg.Logger.Level),
-			l.WithTimeZone(localTime),
-			l.WithMaxSize(cfg.Logger.MaxSize),
-			l.WithMaxBackup(cfg.Logger.MaxBackup),
-			l.WithMaxAge(cfg.Logger.MaxAge),
-			l.WithCompress(compress),
-		)
-		_logger := l.NewGormLogger(logger, l.WithSlowThreshold(time.Duration(cfg.Logger.SlowThreshold)*time.Millisecond))
-		gormConfig = &gorm.Config{Logger: _logger}
-	} else {
-		gormConfig = &gorm.Config{}
-	}
-
-	db, err := gorm.Open(dbConfig, gormConfig)
+	db, err := gorm.Open(dbConfig, newGormConfig(name, "mysql", cfg.Logger))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Page standard page envelope, serializable with gin's c.JSON
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	PerPage    int    `json:"per_page"`
+	Page       int    `json:"page,omitempty"`
+	Total      int64  `json:"total,omitempty"`
+	LastPage   int    `json:"last_page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// Paginate runs the query with offset pagination
+func (r *Repository[T]) Paginate(ctx context.Context, q Query, scopes ...Scope) (*Page[T], error) {
+	q = q.normalize()
+	// Copy first, appending must not write into the caller's backing array
+	scopes = append(slices.Clone(scopes), q.FilterScope())
+
+	total, err := r.Count(ctx, scopes...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]T, 0, q.PerPage)
+	err = r.query(ctx, scopes...).
+		Scopes(q.SortScope()).
+		Offset((q.Page - 1) * q.PerPage).
+		Limit(q.PerPage).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	lastPage := int((total + int64(q.PerPage) - 1) / int64(q.PerPage))
+	return &Page[T]{
+		Items:    items,
+		PerPage:  q.PerPage,
+		Page:     q.Page,
+		Total:    total,
+		LastPage: lastPage,
+		HasMore:  q.Page < lastPage,
+	}, nil
+}
+
+// CursorPaginate runs the query with keyset pagination. The primary key is
+// appended to the sorts as a tie-breaker so the order is always total.
+func (r *Repository[T]) CursorPaginate(ctx context.Context, q Query, scopes ...Scope) (*Page[T], error) {
+	q = q.normalize()
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	if sch.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("model %s has no primary key", sch.Name)
+	}
+
+	sorts := keysetSorts(q.Sorts, sch.PrioritizedPrimaryField.DBName)
+	fields := make([]func(reflect.Value) any, len(sorts))
+	for i, s := range sorts {
+		field := sch.LookUpField(s.Column)
+		if field == nil {
+			return nil, fmt.Errorf("cursor column %s is not a field of %s", s.Column, sch.Name)
+		}
+		fields[i] = func(v reflect.Value) any {
+			value, _ := field.ValueOf(ctx, v)
+			return value
+		}
+	}
+
+	tx := r.query(ctx, append(slices.Clone(scopes), q.FilterScope())...)
+
+	if q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(sorts) {
+			return nil, fmt.Errorf("cursor does not match the sort order")
+		}
+		tx = tx.Where(keysetCondition(sorts, values))
+	}
+
+	for _, s := range sorts {
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+	}
+
+	// Fetch one more row to know whether there is a next page
+	items := make([]T, 0, q.PerPage+1)
+	if err = tx.Limit(q.PerPage + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{PerPage: q.PerPage}
+	if len(items) > q.PerPage {
+		items = items[:q.PerPage]
+		page.HasMore = true
+
+		last := reflect.ValueOf(&items[len(items)-1]).Elem()
+		values := make([]any, len(fields))
+		for i, value := range fields {
+			values[i] = value(last)
+		}
+		if page.NextCursor, err = encodeCursor(values); err != nil {
+			return nil, err
+		}
+	}
+	page.Items = items
+
+	return page, nil
+}
+
+// keysetSorts appends the primary key to the sorts unless already present
+func keysetSorts(sorts []Sort, primaryKey string) []Sort {
+	result := make([]Sort, 0, len(sorts)+1)
+	for _, s := range sorts {
+		if s.Column == primaryKey {
+			return append(result, s)
+		}
+		result = append(result, s)
+	}
+	return append(result, Sort{Column: primaryKey})
+}
+
+// keysetCondition builds (a > ?) OR (a = ? AND b > ?) OR ... honoring each direction
+func keysetCondition(sorts []Sort, values []any) clause.Expression {
+	or := make([]clause.Expression, 0, len(sorts))
+	for i, s := range sorts {
+		and := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Name: sorts[j].Column}, Value: values[j]})
+		}
+
+		column := clause.Column{Name: s.Column}
+		if s.Desc {
+			and = append(and, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: column, Value: values[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+	return clause.Or(or...)
+}
+
+// cursorTime wraps time values so they decode back to time.Time
+type cursorTime struct {
+	Time time.Time `json:"$t"`
+}
+
+// encodeCursor encodes the sort values of the last row into an opaque cursor
+func encodeCursor(values []any) (string, error) {
+	encoded := make([]any, len(values))
+	for i, v := range values {
+		switch t := v.(type) {
+		case time.Time:
+			encoded[i] = cursorTime{Time: t}
+		case *time.Time:
+			if t != nil {
+				encoded[i] = cursorTime{Time: *t}
+			}
+		default:
+			encoded[i] = v
+		}
+	}
+
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor into sort values
+func decodeCursor(cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var raw []json.RawMessage
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	values := make([]any, len(raw))
+	for i, item := range raw {
+		var t cursorTime
+		if bytes.HasPrefix(item, []byte("{")) {
+			if err = json.Unmarshal(item, &t); err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			values[i] = t.Time
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(item))
+		decoder.UseNumber()
+		var v any
+		if err = decoder.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if n, ok := v.(json.Number); ok {
+			if n64, err := n.Int64(); err == nil {
+				v = n64
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			}
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -2,7 +2,6 @@ package database
 
 import (
 	"fmt"
-	l "github.com/gin-generator/logger"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"time"
@@ -17,14 +16,15 @@ type PgsqlConfig struct {
 	Password             string        `validate:"required"`
 	Timezone             string        `validate:"required"`
 	PreferSimpleProtocol bool          `validate:"required"`
+	SearchPath           string        `validate:"omitempty"`
 	MaxIdleConnections   *int          `validate:"omitempty,gte=0"`
 	MaxOpenConnections   *int          `validate:"omitempty,gt=0"`
 	MaxLifeSeconds       *int          `validate:"omitempty,gt=0"`
 	Logger               *LoggerConfig `validate:"omitempty"`
 }
 
-// NewPgsqlConnection creates a PostgresSQL connection
-func NewPgsqlConnection(name string, cfg PgsqlConfig) (*gorm.DB, error) {
+// pgsqlDSN builds the DSN of a PostgresSQL connection
+func pgsqlDSN(cfg PgsqlConfig) string {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=%s",
 		cfg.Host,
 		cfg.Username,
@@ -33,40 +33,20 @@ func NewPgsqlConnection(name string, cfg PgsqlConfig) (*gorm.DB, error) {
 		cfg.Port,
 		cfg.Timezone,
 	)
+	if cfg.SearchPath != "" {
+		dsn += fmt.Sprintf(" search_path=%s", cfg.SearchPath)
+	}
+	return dsn
+}
 
+// NewPgsqlConnection creates a PostgresSQL connection
+func NewPgsqlConnection(name string, cfg PgsqlConfig) (*gorm.DB, error) {
 	dbConfig := postgres.New(postgres.Config{
-		DSN:                  dsn,
+		DSN:                  pgsqlDSN(cfg),
 		PreferSimpleProtocol: cfg.PreferSimpleProtocol,
 	})
 
-	var gormConfig *gorm.Config
-	if cfg.Logger != nil {
-		compress := false
-		if cfg.Logger.Compress != nil {
-			compress = *cfg.Logger.Compress
-		}
-
-		localTime := false
-		if cfg.Logger.LocalTime != nil {
-			localTime = *cfg.Logger.LocalTime
-		}
-
-		logger := l.NewLogger(
-			l.WithFileName(fmt.Sprintf("storage/logs/%s.log", name)),
-			l.WithLevel(cfg.Logger.Level),
-			l.WithTimeZone(localTime),
-			l.WithMaxSize(cfg.Logger.MaxSize),
-			l.WithMaxBackup(cfg.Logger.MaxBackup),
-			l.WithMaxAge(cfg.Logger.MaxAge),
-			l.WithCompress(compress),
-		)
-		_logger := l.NewGormLogger(logger, l.WithSlowThreshold(time.Duration(cfg.Logger.SlowThreshold)*time.Millisecond))
-		gormConfig = &gorm.Config{Logger: _logger}
-	} else {
-		gormConfig = &gorm.Config{}
-	}
-
-	db, err := gorm.Open(dbConfig, gormConfig)
+	db, err := gorm.Open(dbConfig, newGormConfig(name, "postgresql", cfg.Logger))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Operator filter operator
+type Operator string
+
+const (
+	OpEq   Operator = "eq"
+	OpNe   Operator = "ne"
+	OpGt   Operator = "gt"
+	OpGte  Operator = "gte"
+	OpLt   Operator = "lt"
+	OpLte  Operator = "lte"
+	OpLike Operator = "like"
+	OpIn   Operator = "in"
+	OpNull Operator = "null"
+)
+
+// Reserved query parameters that are never treated as filters
+const (
+	ParamSort    = "sort"
+	ParamPage    = "page"
+	ParamPerPage = "per_page"
+	ParamCursor  = "cursor"
+	ParamLimit   = "limit"
+)
+
+// Page size bounds applied when the query or options have none
+const (
+	DefaultPerPage = 15
+	MaxPerPage     = 100
+)
+
+// likeEscaper escapes LIKE wildcards, backslash is the default escape character of MySQL and PostgreSQL
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// filterParam matches field or field[op]
+var filterParam = regexp.MustCompile(`^([A-Za-z0-9_.]+)(?:\[([a-z]+)\])?$`)
+
+// Filter a single filter condition
+type Filter struct {
+	Column string
+	Op     Operator
+	Values []string
+}
+
+// Sort a single sort column
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// QueryOptions options for parsing list queries
+type QueryOptions struct {
+	// Filters maps query parameter names to filterable columns
+	Filters map[string]string
+	// Sorts maps sort parameter names to sortable columns
+	Sorts map[string]string
+	// DefaultSort is applied when the request has no sort, e.g. "-created_at"
+	DefaultSort string
+	// DefaultPerPage page size when the request has none, defaults to DefaultPerPage
+	DefaultPerPage int
+	// MaxPerPage upper bound for the requested page size, defaults to MaxPerPage
+	MaxPerPage int
+}
+
+// Query parsed list query
+type Query struct {
+	Filters []Filter
+	Sorts   []Sort
+	Page    int
+	PerPage int
+	Cursor  string
+}
+
+// ParseQuery parses filters, sorts and pagination from query parameters.
+//
+//	?name=foo&age[gte]=18&status[in]=active,pending&sort=-created_at,name&page=2&per_page=20
+//
+// Parameters outside the allowlist are ignored, sorting by a column outside the
+// allowlist is an error.
+func ParseQuery(values url.Values, opts QueryOptions) (Query, error) {
+	if opts.DefaultPerPage <= 0 {
+		opts.DefaultPerPage = DefaultPerPage
+	}
+	if opts.MaxPerPage <= 0 {
+		opts.MaxPerPage = MaxPerPage
+	}
+
+	query := Query{
+		Page:    1,
+		PerPage: opts.DefaultPerPage,
+		Cursor:  values.Get(ParamCursor),
+	}
+
+	// Stable filter order keeps the generated SQL stable
+	params := make([]string, 0, len(values))
+	for param := range values {
+		params = append(params, param)
+	}
+	slices.Sort(params)
+
+	for _, param := range params {
+		vals := values[param]
+		switch param {
+		case ParamSort, ParamPage, ParamPerPage, ParamCursor, ParamLimit:
+			continue
+		}
+
+		matches := filterParam.FindStringSubmatch(param)
+		if matches == nil {
+			continue
+		}
+		column, ok := opts.Filters[matches[1]]
+		if !ok {
+			continue
+		}
+
+		op := OpEq
+		if matches[2] != "" {
+			op = Operator(matches[2])
+		}
+
+		filter, err := newFilter(column, op, vals)
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid filter %s: %w", param, err)
+		}
+		query.Filters = append(query.Filters, filter)
+	}
+
+	sort := values.Get(ParamSort)
+	if sort == "" {
+		sort = opts.DefaultSort
+	}
+	sorts, err := parseSort(sort, opts)
+	if err != nil {
+		return Query{}, err
+	}
+	query.Sorts = sorts
+
+	if page := values.Get(ParamPage); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return Query{}, fmt.Errorf("invalid page %s", page)
+		}
+		query.Page = n
+	}
+
+	perPage := values.Get(ParamPerPage)
+	if perPage == "" {
+		perPage = values.Get(ParamLimit)
+	}
+	if perPage != "" {
+		n, err := strconv.Atoi(perPage)
+		if err != nil || n < 1 {
+			return Query{}, fmt.Errorf("invalid page size %s", perPage)
+		}
+		query.PerPage = min(n, opts.MaxPerPage)
+	}
+
+	return query, nil
+}
+
+// newFilter validates the operator and values of a filter
+func newFilter(column string, op Operator, vals []string) (Filter, error) {
+	if len(vals) == 0 {
+		return Filter{}, fmt.Errorf("missing value")
+	}
+	value := vals[0]
+
+	switch op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike:
+		return Filter{Column: column, Op: op, Values: []string{value}}, nil
+	case OpIn:
+		return Filter{Column: column, Op: op, Values: strings.Split(value, ",")}, nil
+	case OpNull:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return Filter{}, fmt.Errorf("null expects true or false")
+		}
+		return Filter{Column: column, Op: op, Values: []string{value}}, nil
+	default:
+		return Filter{}, fmt.Errorf("unsupported operator %s", op)
+	}
+}
+
+// parseSort parses "-created_at,name" into sort columns
+func parseSort(sort string, opts QueryOptions) ([]Sort, error) {
+	if sort == "" {
+		return nil, nil
+	}
+
+	var sorts []Sort
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(field, "-")
+		name := strings.TrimLeft(field, "+-")
+
+		column, ok := opts.Sorts[name]
+		if !ok {
+			return nil, fmt.Errorf("sorting by %s is not allowed", name)
+		}
+		sorts = append(sorts, Sort{Column: column, Desc: desc})
+	}
+	return sorts, nil
+}
+
+// Expression builds the clause expression of the filter
+func (f Filter) Expression() clause.Expression {
+	column := clause.Column{Name: f.Column}
+
+	switch f.Op {
+	case OpNe:
+		return clause.Neq{Column: column, Value: f.Values[0]}
+	case OpGt:
+		return clause.Gt{Column: column, Value: f.Values[0]}
+	case OpGte:
+		return clause.Gte{Column: column, Value: f.Values[0]}
+	case OpLt:
+		return clause.Lt{Column: column, Value: f.Values[0]}
+	case OpLte:
+		return clause.Lte{Column: column, Value: f.Values[0]}
+	case OpLike:
+		return clause.Like{Column: column, Value: "%" + likeEscaper.Replace(f.Values[0]) + "%"}
+	case OpIn:
+		values := make([]any, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = v
+		}
+		return clause.IN{Column: column, Values: values}
+	case OpNull:
+		if isNull, _ := strconv.ParseBool(f.Values[0]); !isNull {
+			return clause.Neq{Column: column, Value: nil}
+		}
+		return clause.Eq{Column: column, Value: nil}
+	default:
+		return clause.Eq{Column: column, Value: f.Values[0]}
+	}
+}
+
+// normalize applies the first page and the default page size to a zero or hand-built query
+func (q Query) normalize() Query {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultPerPage
+	}
+	return q
+}
+
+// FilterScope applies the filters of the query
+func (q Query) FilterScope() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, f := range q.Filters {
+			db = db.Where(f.Expression())
+		}
+		return db
+	}
+}
+
+// SortScope applies the sorts of the query
+func (q Query) SortScope() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, s := range q.Sorts {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc})
+		}
+		return db
+	}
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	opts := QueryOptions{
+		Filters: map[string]string{"name": "name", "age": "age", "status": "status"},
+		Sorts:   map[string]string{"created_at": "created_at", "name": "name"},
+	}
+
+	values, _ := url.ParseQuery("name=foo&age[gte]=18&status[in]=a,b&secret=1&sort=-created_at,name&page=2&per_page=500")
+	q, err := ParseQuery(values, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(q.Filters) != 3 {
+		t.Fatalf("expected 3 filters, got %d", len(q.Filters))
+	}
+	if q.Filters[0].Column != "age" || q.Filters[0].Op != OpGte {
+		t.Errorf("unexpected filter %+v", q.Filters[0])
+	}
+	if q.Filters[2].Op != OpIn || len(q.Filters[2].Values) != 2 {
+		t.Errorf("unexpected filter %+v", q.Filters[2])
+	}
+	if len(q.Sorts) != 2 || !q.Sorts[0].Desc || q.Sorts[1].Desc {
+		t.Errorf("unexpected sorts %+v", q.Sorts)
+	}
+	if q.Page != 2 || q.PerPage != 100 {
+		t.Errorf("unexpected pagination page=%d per_page=%d", q.Page, q.PerPage)
+	}
+
+	values, _ = url.ParseQuery("sort=password")
+	if _, err = ParseQuery(values, opts); err == nil {
+		t.Error("expected sorting outside the allowlist to fail")
+	}
+
+	values, _ = url.ParseQuery("age[drop]=1")
+	if _, err = ParseQuery(values, opts); err == nil {
+		t.Error("expected an unsupported operator to fail")
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	cursor, err := encodeCursor([]any{now, "foo", uint64(9007199254740993)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := values[0].(time.Time); !ok || !got.Equal(now) {
+		t.Errorf("expected %v, got %v", now, values[0])
+	}
+	if values[1] != "foo" {
+		t.Errorf("expected foo, got %v", values[1])
+	}
+	if values[2] != int64(9007199254740993) {
+		t.Errorf("expected exact integer, got %v", values[2])
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Scope gorm query scope
+type Scope = func(db *gorm.DB) *gorm.DB
+
+// Repository generic CRUD repository of model T
+type Repository[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a repository on a connection
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// DB returns the underlying connection
+func (r *Repository[T]) DB() *gorm.DB {
+	return r.db
+}
+
+// WithTx returns a repository bound to a transaction
+func (r *Repository[T]) WithTx(tx *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: tx}
+}
+
+// query starts a query on the model
+func (r *Repository[T]) query(ctx context.Context, scopes ...Scope) *gorm.DB {
+	return r.db.WithContext(ctx).Model(new(T)).Scopes(scopes...)
+}
+
+// schema parses the model schema
+func (r *Repository[T]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// Find finds all records matching the scopes
+func (r *Repository[T]) Find(ctx context.Context, scopes ...Scope) ([]T, error) {
+	var items []T
+	if err := r.query(ctx, scopes...).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// First finds the first record matching the scopes, returns gorm.ErrRecordNotFound when there is none
+func (r *Repository[T]) First(ctx context.Context, scopes ...Scope) (*T, error) {
+	item := new(T)
+	if err := r.query(ctx, scopes...).First(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// FindByID finds a record by primary key
+func (r *Repository[T]) FindByID(ctx context.Context, id any) (*T, error) {
+	sch, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	if sch.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("model %s has no primary key", sch.Name)
+	}
+
+	return r.First(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName},
+			Value:  id,
+		})
+	})
+}
+
+// Create inserts a record
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+// Save updates all fields of a record, inserting it when it has no primary key
+func (r *Repository[T]) Save(ctx context.Context, item *T) error {
+	return r.db.WithContext(ctx).Save(item).Error
+}
+
+// Update updates the given columns of the records matching the scopes.
+// values is a map or a struct, zero struct fields are skipped as in gorm.
+func (r *Repository[T]) Update(ctx context.Context, values any, scopes ...Scope) (int64, error) {
+	tx := r.query(ctx, scopes...).Updates(values)
+	return tx.RowsAffected, tx.Error
+}
+
+// Delete deletes the records matching the scopes, at least one condition is required
+func (r *Repository[T]) Delete(ctx context.Context, scopes ...Scope) (int64, error) {
+	tx := r.db.WithContext(ctx).Scopes(scopes...).Delete(new(T))
+	return tx.RowsAffected, tx.Error
+}
+
+// Exists checks if any record matches the scopes
+func (r *Repository[T]) Exists(ctx context.Context, scopes ...Scope) (bool, error) {
+	var found []int
+	tx := r.query(ctx, scopes...).Select("1").Limit(1).Find(&found)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return len(found) > 0, nil
+}
+
+// Count counts the records matching the scopes
+func (r *Repository[T]) Count(ctx context.Context, scopes ...Scope) (int64, error) {
+	var total int64
+	err := r.query(ctx, scopes...).Count(&total).Error
+	return total, err
+}
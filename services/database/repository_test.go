@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"testing"
+	"time"
+)
+
+type widget struct {
+	ID        uint
+	Name      string
+	CreatedAt time.Time
+}
+
+// sqlRecorder gorm logger collecting the statements of a dry run
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// last returns the last recorded statement
+func (r *sqlRecorder) last() string {
+	if len(r.statements) == 0 {
+		return ""
+	}
+	return r.statements[len(r.statements)-1]
+}
+
+// dryRun opens a MySQL dialect connection that records statements instead of running them
+func dryRun(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	sqlDB, err := sql.Open("stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, recorder
+}
+
+func TestRepositoryStatements(t *testing.T) {
+	ctx := context.Background()
+	db, recorder := dryRun(t)
+	repo := NewRepository[widget](db)
+
+	byName := func(db *gorm.DB) *gorm.DB {
+		return db.Where("name = ?", "foo")
+	}
+
+	tests := []struct {
+		run  func()
+		want string
+	}{
+		{func() { _, _ = repo.Find(ctx, byName) }, "SELECT * FROM `widgets` WHERE name = 'foo'"},
+		{func() { _, _ = repo.FindByID(ctx, 7) }, "SELECT * FROM `widgets` WHERE `widgets`.`id` = 7 ORDER BY `widgets`.`id` LIMIT 1"},
+		{func() { _, _ = repo.Exists(ctx, byName) }, "SELECT 1 FROM `widgets` WHERE name = 'foo' LIMIT 1"},
+		{func() { _, _ = repo.Count(ctx, byName) }, "SELECT count(*) FROM `widgets` WHERE name = 'foo'"},
+		{func() { _, _ = repo.Update(ctx, map[string]any{"name": "bar"}, byName) }, "UPDATE `widgets` SET `name`='bar' WHERE name = 'foo'"},
+		{func() { _, _ = repo.Delete(ctx, byName) }, "DELETE FROM `widgets` WHERE name = 'foo'"},
+	}
+	for _, tt := range tests {
+		tt.run()
+		if got := recorder.last(); got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	ctx := context.Background()
+	db, recorder := dryRun(t)
+	repo := NewRepository[widget](db)
+
+	// A zero query gets the first page of the default size
+	page, err := repo.Paginate(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Page != 1 || page.PerPage != DefaultPerPage {
+		t.Errorf("expected page 1 of %d, got %+v", DefaultPerPage, page)
+	}
+	if got := recorder.last(); got != "SELECT * FROM `widgets` LIMIT 15" {
+		t.Errorf("unexpected statement %s", got)
+	}
+
+	// Appending the filter scope must not write into the caller's array
+	scopes := make([]Scope, 1, 2)
+	scopes[0] = func(db *gorm.DB) *gorm.DB {
+		return db.Where("name = ?", "foo")
+	}
+	q := Query{Page: 3, PerPage: 10, Sorts: []Sort{{Column: "name", Desc: true}}}
+	if _, err = repo.Paginate(ctx, q, scopes...); err != nil {
+		t.Fatal(err)
+	}
+	if scopes[:2][1] != nil {
+		t.Error("expected the caller's scopes to be left untouched")
+	}
+	if got := recorder.last(); got != "SELECT * FROM `widgets` WHERE name = 'foo' ORDER BY `name` DESC LIMIT 10 OFFSET 20" {
+		t.Errorf("unexpected statement %s", got)
+	}
+}
+
+func TestCursorPaginate(t *testing.T) {
+	ctx := context.Background()
+	db, recorder := dryRun(t)
+	repo := NewRepository[widget](db)
+
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cursor, err := encodeCursor([]any{created, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := Query{PerPage: 5, Sorts: []Sort{{Column: "created_at", Desc: true}}, Cursor: cursor}
+	if _, err = repo.CursorPaginate(ctx, q); err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT * FROM `widgets` WHERE (`created_at` < '2025-01-02 03:04:05' OR (`created_at` = '2025-01-02 03:04:05' AND `id` > 9)) " +
+		"ORDER BY `created_at` DESC,`id` LIMIT 6"
+	if got := recorder.last(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	q.Cursor, _ = encodeCursor([]any{created})
+	if _, err = repo.CursorPaginate(ctx, q); err == nil {
+		t.Error("expected a cursor of another sort order to fail")
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	db, _ := dryRun(t)
+
+	sorts := keysetSorts([]Sort{{Column: "score", Desc: true}, {Column: "name"}}, "id")
+	if len(sorts) != 3 || sorts[2].Column != "id" {
+		t.Fatalf("expected the primary key tie-breaker, got %+v", sorts)
+	}
+	if sorts := keysetSorts([]Sort{{Column: "id", Desc: true}, {Column: "name"}}, "id"); len(sorts) != 1 {
+		t.Errorf("expected sorts after the primary key to be dropped, got %+v", sorts)
+	}
+
+	stmt := db.Session(&gorm.Session{}).Table("t").Where(keysetCondition(sorts, []any{10, "b", 3})).Find(&[]map[string]any{}).Statement
+	want := "SELECT * FROM `t` WHERE (`score` < ? OR (`score` = ? AND `name` > ?) OR (`score` = ? AND `name` = ? AND `id` > ?))"
+	if got := stmt.SQL.String(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if len(stmt.Vars) != 6 {
+		t.Errorf("expected 6 bound values, got %v", stmt.Vars)
+	}
+}
+
+func TestLikeEscaping(t *testing.T) {
+	like := Filter{Column: "name", Op: OpLike, Values: []string{`50%_off\`}}.Expression().(clause.Like)
+	if like.Value != `%50\%\_off\\%` {
+		t.Errorf("expected wildcards to be escaped, got %v", like.Value)
+	}
+}
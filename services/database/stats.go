@@ -0,0 +1,144 @@
+package database
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStatements bounds the distinct statements tracked per connection
+const maxStatements = 1000
+
+var (
+	// stringLiteral matches quoted string literals
+	stringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	// numberLiteral matches standalone numeric literals
+	numberLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	// inList matches IN lists of placeholders
+	inList = regexp.MustCompile(`(?i)\bIN\s*\((?:\s*\?\s*,?)+\)`)
+	// valuesList matches repeated VALUES tuples of placeholders
+	valuesList = regexp.MustCompile(`(?i)\bVALUES\s*(\((?:\s*\?\s*,?)+\)\s*,?\s*)+`)
+	// whitespace matches runs of whitespace
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+// StatementStat slow query statistics of a normalized statement
+type StatementStat struct {
+	Statement string        `json:"statement"`
+	Count     int64         `json:"count"`
+	Total     time.Duration `json:"total"`
+	Max       time.Duration `json:"max"`
+	LastSeen  time.Time     `json:"last_seen"`
+}
+
+// Average returns the average duration
+func (s StatementStat) Average() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// QueryStats slow query statistics of a connection
+type QueryStats struct {
+	mu         sync.Mutex
+	statements map[string]*StatementStat
+}
+
+// statistics registry, one entry per connection
+var (
+	statsMu    sync.Mutex
+	statistics = make(map[string]*QueryStats)
+)
+
+// statsFor gets or creates the statistics of a connection
+func statsFor(name string) *QueryStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats, ok := statistics[name]
+	if !ok {
+		stats = &QueryStats{statements: make(map[string]*StatementStat)}
+		statistics[name] = stats
+	}
+	return stats
+}
+
+// SlowQueryStats returns the slow statements of a connection, slowest in total first
+func SlowQueryStats(name string) []StatementStat {
+	statsMu.Lock()
+	stats, ok := statistics[name]
+	statsMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return stats.Snapshot()
+}
+
+// record adds an execution of a statement
+func (q *QueryStats) record(sql string, elapsed time.Duration) {
+	statement := NormalizeSQL(sql)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stat, ok := q.statements[statement]
+	if !ok {
+		if len(q.statements) >= maxStatements {
+			return
+		}
+		stat = &StatementStat{Statement: statement}
+		q.statements[statement] = stat
+	}
+
+	stat.Count++
+	stat.Total += elapsed
+	stat.Max = max(stat.Max, elapsed)
+	stat.LastSeen = time.Now()
+}
+
+// Snapshot returns a copy of the statistics, slowest in total first
+func (q *QueryStats) Snapshot() []StatementStat {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := make([]StatementStat, 0, len(q.statements))
+	for _, stat := range q.statements {
+		result = append(result, *stat)
+	}
+	slices.SortFunc(result, func(a, b StatementStat) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	return result
+}
+
+// Reset clears the statistics
+func (q *QueryStats) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.statements = make(map[string]*StatementStat)
+}
+
+// NormalizeSQL replaces literals with placeholders so executions of the same
+// statement with different arguments are grouped together
+func NormalizeSQL(sql string) string {
+	sql = stringLiteral.ReplaceAllString(sql, "?")
+	sql = numberLiteral.ReplaceAllString(sql, "?")
+	sql = inList.ReplaceAllString(sql, "IN (?)")
+	sql = valuesList.ReplaceAllString(sql, "VALUES (?) ")
+	sql = whitespace.ReplaceAllString(sql, " ")
+	return strings.TrimSpace(sql)
+}
+
+// statementVerb returns the leading keyword of a statement
+func statementVerb(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \t\n("); i > 0 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
@@ -0,0 +1,304 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// TenantMode tenant isolation mode
+type TenantMode string
+
+const (
+	// TenantModeDatabase each tenant has its own database
+	TenantModeDatabase TenantMode = "database"
+	// TenantModeSchema each tenant has its own schema (PostgresSQL search_path)
+	TenantModeSchema TenantMode = "schema"
+	// TenantModeColumn tenants share tables, rows are isolated by a tenant column
+	TenantModeColumn TenantMode = "column"
+)
+
+// TenantKey is the gin context key the tenant middleware stores the tenant under
+const TenantKey = "sugar.tenant"
+
+// TenantConfig multi-tenant configuration with validation tags
+type TenantConfig struct {
+	Mode           TenantMode `validate:"required,oneof=database schema column"`
+	Connection     string     `validate:"required"` // base connection name
+	Resolver       string     `validate:"required,oneof=header subdomain jwt metadata"`
+	Header         string     `validate:"omitempty"` // header or gRPC metadata key, defaults to X-Tenant-ID
+	Domain         string     `validate:"omitempty"` // root domain for the subdomain resolver
+	Claim          string     `validate:"omitempty"` // JWT claim, defaults to tenant_id
+	Pattern        string     `validate:"omitempty"` // database/schema name pattern, defaults to tenant_%s
+	Column         string     `validate:"omitempty"` // tenant column, defaults to tenant_id
+	MaxConnections int        `validate:"omitempty,gt=0"`
+	CloseDelay     int        `validate:"omitempty,gte=0"` // seconds an evicted connection stays open for requests still holding it, defaults to 30
+}
+
+// TenantOpener opens a connection for a tenant, name is its database or schema name
+type TenantOpener func(tenant, name string) (*gorm.DB, error)
+
+// tenantRetirePoll interval at which a retired connection is checked for in-flight queries
+const tenantRetirePoll = 100 * time.Millisecond
+
+// tenantPattern restricts tenant identifiers, they end up in database and schema names
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant identifier
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext gets the tenant identifier from ctx
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if tenant, ok := ctx.Value(tenantContextKey{}).(string); ok && tenant != "" {
+		return tenant, true
+	}
+	// *gin.Context only exposes values stored with c.Set
+	if tenant, ok := ctx.Value(TenantKey).(string); ok && tenant != "" {
+		return tenant, true
+	}
+	return "", false
+}
+
+// ValidateTenant checks that a tenant identifier is safe to use
+func ValidateTenant(tenant string) error {
+	if !tenantPattern.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant identifier %q", tenant)
+	}
+	return nil
+}
+
+// TenantScope gorm scope for row-level tenant isolation
+func TenantScope(column, tenant string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(fmt.Sprintf("%s = ?", column), tenant)
+	}
+}
+
+// tenantEntry cached tenant connection
+type tenantEntry struct {
+	tenant string
+	db     *gorm.DB
+}
+
+// TenantManager resolves and caches per-tenant connections
+type TenantManager struct {
+	manager *Manager
+	cfg     TenantConfig
+	open    TenantOpener
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+
+	closeDelay time.Duration
+	closing    chan struct{}
+	closeOnce  sync.Once
+	retiring   sync.WaitGroup
+}
+
+// NewTenantManager creates a tenant manager, open is unused in column mode
+func NewTenantManager(manager *Manager, cfg TenantConfig, open TenantOpener) *TenantManager {
+	if cfg.Header == "" {
+		cfg.Header = "X-Tenant-ID"
+	}
+	if cfg.Claim == "" {
+		cfg.Claim = "tenant_id"
+	}
+	if cfg.Pattern == "" {
+		cfg.Pattern = "tenant_%s"
+	}
+	if cfg.Column == "" {
+		cfg.Column = "tenant_id"
+	}
+	if cfg.MaxConnections <= 0 {
+		cfg.MaxConnections = 64
+	}
+	if cfg.CloseDelay <= 0 {
+		cfg.CloseDelay = 30
+	}
+
+	return &TenantManager{
+		manager:    manager,
+		cfg:        cfg,
+		open:       open,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		closeDelay: time.Duration(cfg.CloseDelay) * time.Second,
+		closing:    make(chan struct{}),
+	}
+}
+
+// Config returns the tenant configuration with defaults applied
+func (t *TenantManager) Config() TenantConfig {
+	return t.cfg
+}
+
+// Name returns the database or schema name of a tenant
+func (t *TenantManager) Name(tenant string) string {
+	return fmt.Sprintf(t.cfg.Pattern, tenant)
+}
+
+// DB gets the connection of the tenant carried by ctx
+func (t *TenantManager) DB(ctx context.Context) (*gorm.DB, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no tenant in context")
+	}
+
+	db, err := t.Connection(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return db.WithContext(ctx), nil
+}
+
+// Connection gets the connection of a tenant, opening it on first use
+func (t *TenantManager) Connection(tenant string) (*gorm.DB, error) {
+	if err := ValidateTenant(tenant); err != nil {
+		return nil, err
+	}
+
+	if t.cfg.Mode == TenantModeColumn {
+		db, err := t.manager.Connection(t.cfg.Connection)
+		if err != nil {
+			return nil, err
+		}
+		return db.Scopes(TenantScope(t.cfg.Column, tenant)).Session(&gorm.Session{}), nil
+	}
+
+	if db, ok := t.get(tenant); ok {
+		return db, nil
+	}
+
+	if t.open == nil {
+		return nil, fmt.Errorf("no tenant opener configured for mode %s", t.cfg.Mode)
+	}
+
+	// Open outside the lock, a slow handshake must not block other tenants
+	db, err := t.open(tenant, t.Name(tenant))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tenant %s: %w", tenant, err)
+	}
+
+	return t.put(tenant, db), nil
+}
+
+// get looks up a cached connection and marks it as recently used
+func (t *TenantManager) get(tenant string) (*gorm.DB, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem, ok := t.items[tenant]
+	if !ok {
+		return nil, false
+	}
+	t.lru.MoveToFront(elem)
+	return elem.Value.(*tenantEntry).db, true
+}
+
+// put caches a connection, evicting the least recently used one when full
+func (t *TenantManager) put(tenant string, db *gorm.DB) *gorm.DB {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Another request opened the same tenant concurrently, db was never handed out
+	if elem, ok := t.items[tenant]; ok {
+		closeConnection(db)
+		t.lru.MoveToFront(elem)
+		return elem.Value.(*tenantEntry).db
+	}
+
+	t.items[tenant] = t.lru.PushFront(&tenantEntry{tenant: tenant, db: db})
+
+	for t.lru.Len() > t.cfg.MaxConnections {
+		oldest := t.lru.Back()
+		entry := oldest.Value.(*tenantEntry)
+		t.lru.Remove(oldest)
+		delete(t.items, entry.tenant)
+		t.retire(entry.db)
+	}
+	return db
+}
+
+// Forget removes a cached tenant connection, it is closed once idle
+func (t *TenantManager) Forget(tenant string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.items[tenant]; ok {
+		t.lru.Remove(elem)
+		delete(t.items, tenant)
+		t.retire(elem.Value.(*tenantEntry).db)
+	}
+}
+
+// Len returns the number of cached tenant connections
+func (t *TenantManager) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lru.Len()
+}
+
+// Close closes all tenant connections, waiting for their in-flight queries
+func (t *TenantManager) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closing)
+	})
+
+	t.mu.Lock()
+	for _, elem := range t.items {
+		t.retire(elem.Value.(*tenantEntry).db)
+	}
+	t.lru.Init()
+	t.items = make(map[string]*list.Element)
+	t.mu.Unlock()
+
+	t.retiring.Wait()
+}
+
+// retire closes an evicted connection in the background. Requests that got it
+// before the eviction may still be using it, so it stays open for the close
+// delay and until no query is in flight.
+func (t *TenantManager) retire(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	// Pooled connections are released as they come back, new queries still work
+	sqlDB.SetMaxIdleConns(0)
+
+	t.retiring.Add(1)
+	go func() {
+		defer t.retiring.Done()
+
+		timer := time.NewTimer(t.closeDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-t.closing:
+		}
+
+		for sqlDB.Stats().InUse > 0 {
+			time.Sleep(tenantRetirePoll)
+		}
+		_ = sqlDB.Close()
+	}()
+}
+
+// closeConnection closes the underlying sql.DB of a gorm connection
+func closeConnection(db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TenantResolver resolves the tenant of an HTTP request
+type TenantResolver interface {
+	Resolve(r *http.Request) (string, error)
+}
+
+// TenantResolverFunc adapts a function to TenantResolver
+type TenantResolverFunc func(r *http.Request) (string, error)
+
+// Resolve calls f(r)
+func (f TenantResolverFunc) Resolve(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// HeaderTenantResolver resolves the tenant from a request header
+type HeaderTenantResolver struct {
+	Header string
+}
+
+// Resolve resolves the tenant
+func (h HeaderTenantResolver) Resolve(r *http.Request) (string, error) {
+	tenant := strings.TrimSpace(r.Header.Get(h.Header))
+	if tenant == "" {
+		return "", fmt.Errorf("missing tenant header %s", h.Header)
+	}
+	return tenant, nil
+}
+
+// SubdomainTenantResolver resolves the tenant from the first label of the host,
+// e.g. acme.example.com with Domain example.com resolves to acme
+type SubdomainTenantResolver struct {
+	Domain string
+}
+
+// Resolve resolves the tenant
+func (s SubdomainTenantResolver) Resolve(r *http.Request) (string, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	suffix := "." + strings.ToLower(strings.TrimPrefix(s.Domain, "."))
+	if s.Domain == "" || !strings.HasSuffix(host, suffix) {
+		return "", fmt.Errorf("host %s is not a subdomain of %s", host, s.Domain)
+	}
+
+	tenant := strings.TrimSuffix(host, suffix)
+	if tenant == "" || strings.Contains(tenant, ".") {
+		return "", fmt.Errorf("host %s has no single tenant label", host)
+	}
+	return tenant, nil
+}
+
+// JWTClaimTenantResolver resolves the tenant from a claim of the bearer token.
+// The signature is not verified here, the token must be authenticated by an
+// earlier middleware.
+type JWTClaimTenantResolver struct {
+	Claim string
+}
+
+// Resolve resolves the tenant
+func (j JWTClaimTenantResolver) Resolve(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", fmt.Errorf("missing bearer token")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed bearer token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed bearer token payload: %w", err)
+	}
+
+	// Keep numeric claims as written, float64 loses precision above 2^53
+	claims := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err = decoder.Decode(&claims); err != nil {
+		return "", fmt.Errorf("malformed bearer token claims: %w", err)
+	}
+
+	switch v := claims[j.Claim].(type) {
+	case string:
+		if v != "" {
+			return v, nil
+		}
+	case json.Number:
+		if _, err = v.Int64(); err != nil {
+			return "", fmt.Errorf("tenant claim %s is not an integer: %s", j.Claim, v)
+		}
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("missing tenant claim %s", j.Claim)
+}
+
+// NewTenantResolver creates the HTTP resolver described by the configuration
+func NewTenantResolver(cfg TenantConfig) (TenantResolver, error) {
+	switch cfg.Resolver {
+	case "header":
+		return HeaderTenantResolver{Header: cfg.Header}, nil
+	case "subdomain":
+		return SubdomainTenantResolver{Domain: cfg.Domain}, nil
+	case "jwt":
+		return JWTClaimTenantResolver{Claim: cfg.Claim}, nil
+	case "metadata":
+		// gRPC metadata travels as HTTP/2 headers
+		return HeaderTenantResolver{Header: cfg.Header}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tenant resolver %s", cfg.Resolver)
+	}
+}
+
+// TenantFromMetadata resolves the tenant from incoming gRPC metadata
+func TenantFromMetadata(ctx context.Context, key string) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("missing gRPC metadata")
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+		return "", fmt.Errorf("missing tenant metadata %s", key)
+	}
+	return strings.TrimSpace(values[0]), nil
+}
+
+// TenantUnaryInterceptor stores the tenant from gRPC metadata in the request context
+func TenantUnaryInterceptor(key string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		tenant, err := TenantFromMetadata(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if err = ValidateTenant(tenant); err != nil {
+			return nil, err
+		}
+		return handler(WithTenant(ctx, tenant), req)
+	}
+}
+
+// tenantServerStream overrides the context of a server stream
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream context carrying the tenant
+func (s *tenantServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// TenantStreamInterceptor stores the tenant from gRPC metadata in the stream context
+func TenantStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		tenant, err := TenantFromMetadata(ss.Context(), key)
+		if err != nil {
+			return err
+		}
+		if err = ValidateTenant(tenant); err != nil {
+			return err
+		}
+		return handler(srv, &tenantServerStream{ServerStream: ss, ctx: WithTenant(ss.Context(), tenant)})
+	}
+}
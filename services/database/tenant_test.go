@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"google.golang.org/grpc/metadata"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubDriver sql driver whose connections only open and close
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) {
+	return stubConn{}, nil
+}
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("stub connection")
+}
+
+func (stubConn) Close() error {
+	return nil
+}
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("stub connection")
+}
+
+func init() {
+	sql.Register("stub", stubDriver{})
+}
+
+// openStub opens a gorm connection on the stub driver
+func openStub() (*gorm.DB, *sql.DB, error) {
+	sqlDB, err := sql.Open("stub", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		DisableAutomaticPing: true,
+	})
+	return db, sqlDB, err
+}
+
+// closed checks if a sql.DB was closed
+func closed(sqlDB *sql.DB) bool {
+	err := sqlDB.Ping()
+	return err != nil && strings.Contains(err.Error(), "database is closed")
+}
+
+func TestValidateTenant(t *testing.T) {
+	for _, tenant := range []string{"acme", "acme_1", "ACME-2"} {
+		if err := ValidateTenant(tenant); err != nil {
+			t.Errorf("expected %q to be valid, got %v", tenant, err)
+		}
+	}
+	for _, tenant := range []string{"", "acme.prod", "a;drop", "../x", strings.Repeat("a", 65)} {
+		if err := ValidateTenant(tenant); err == nil {
+			t.Errorf("expected %q to be invalid", tenant)
+		}
+	}
+}
+
+func TestTenantResolvers(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://acme.example.com:8080/", nil)
+	r.Header.Set("X-Tenant-ID", " acme ")
+
+	if tenant, err := (HeaderTenantResolver{Header: "X-Tenant-ID"}).Resolve(r); err != nil || tenant != "acme" {
+		t.Errorf("header resolver: got %q, %v", tenant, err)
+	}
+	if _, err := (HeaderTenantResolver{Header: "X-Other"}).Resolve(r); err == nil {
+		t.Error("header resolver: expected a missing header to fail")
+	}
+
+	if tenant, err := (SubdomainTenantResolver{Domain: "example.com"}).Resolve(r); err != nil || tenant != "acme" {
+		t.Errorf("subdomain resolver: got %q, %v", tenant, err)
+	}
+	for _, host := range []string{"example.com", "a.b.example.com", "acme.other.com"} {
+		r := httptest.NewRequest("GET", "http://"+host+"/", nil)
+		if tenant, err := (SubdomainTenantResolver{Domain: "example.com"}).Resolve(r); err == nil {
+			t.Errorf("subdomain resolver: expected %s to fail, got %q", host, tenant)
+		}
+	}
+
+	token := func(claims string) string {
+		return "Bearer e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+	}
+	jwt := JWTClaimTenantResolver{Claim: "tenant_id"}
+	for claims, want := range map[string]string{
+		`{"tenant_id":"acme"}`:           "acme",
+		`{"tenant_id":42}`:               "42",
+		`{"tenant_id":9007199254740993}`: "9007199254740993",
+	} {
+		r.Header.Set("Authorization", token(claims))
+		if tenant, err := jwt.Resolve(r); err != nil || tenant != want {
+			t.Errorf("jwt resolver %s: got %q, %v", claims, tenant, err)
+		}
+	}
+	for _, auth := range []string{"", "Basic abc", "Bearer abc", token(`{"sub":"1"}`), token(`{"tenant_id":4.2}`), token(`{"tenant_id":true}`)} {
+		r.Header.Set("Authorization", auth)
+		if _, err := jwt.Resolve(r); err == nil {
+			t.Errorf("jwt resolver: expected %q to fail", auth)
+		}
+	}
+
+	if _, err := NewTenantResolver(TenantConfig{Resolver: "cookie"}); err == nil {
+		t.Error("expected an unsupported resolver to fail")
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+	if tenant, err := TenantFromMetadata(ctx, "X-Tenant-ID"); err != nil || tenant != "acme" {
+		t.Errorf("metadata: got %q, %v", tenant, err)
+	}
+	if _, err := TenantFromMetadata(context.Background(), "x-tenant-id"); err == nil {
+		t.Error("metadata: expected missing metadata to fail")
+	}
+}
+
+func TestTenantManagerLRU(t *testing.T) {
+	pools := make(map[string][]*sql.DB)
+	tenants := NewTenantManager(nil, TenantConfig{Mode: TenantModeDatabase, MaxConnections: 2}, func(tenant, name string) (*gorm.DB, error) {
+		if name != "tenant_"+tenant {
+			t.Errorf("opener: got name %s for tenant %s", name, tenant)
+		}
+		db, sqlDB, err := openStub()
+		pools[tenant] = append(pools[tenant], sqlDB)
+		return db, err
+	})
+	tenants.closeDelay = 10 * time.Millisecond
+
+	if _, err := tenants.Connection("../etc"); err == nil {
+		t.Fatal("expected an invalid tenant to fail")
+	}
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		if _, err := tenants.Connection(tenant); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pools["a"]) != 1 {
+		t.Fatalf("expected a cached connection to be reused, opened %d", len(pools["a"]))
+	}
+
+	// A request still runs a query on b when c evicts it
+	conn, err := pools["b"][0].Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tenants.Connection("c"); err != nil {
+		t.Fatal(err)
+	}
+	if tenants.Len() != 2 {
+		t.Fatalf("expected 2 cached connections, got %d", tenants.Len())
+	}
+
+	time.Sleep(5 * tenants.closeDelay)
+	if closed(pools["b"][0]) {
+		t.Fatal("expected an evicted connection in use to stay open")
+	}
+	_ = conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for !closed(pools["b"][0]) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected an evicted connection to be closed once idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err = tenants.Connection("b"); err != nil || len(pools["b"]) != 2 {
+		t.Fatalf("expected an evicted tenant to reopen, got %v", err)
+	}
+
+	tenants.Close()
+	for _, tenant := range []string{"a", "c"} {
+		if !closed(pools[tenant][0]) {
+			t.Errorf("expected %s to be closed", tenant)
+		}
+	}
+	if tenants.Len() != 0 {
+		t.Errorf("expected no cached connections after close, got %d", tenants.Len())
+	}
+}
@@ -0,0 +1,28 @@
+package logger
+
+import "context"
+
+// RequestIDKey is the gin context key the request ID middleware stores the ID under
+const RequestIDKey = "sugar.request_id"
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext gets the request ID from ctx
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if id, ok := ctx.Value(requestIDContextKey{}).(string); ok && id != "" {
+		return id, true
+	}
+	// *gin.Context only exposes values stored with c.Set
+	if id, ok := ctx.Value(RequestIDKey).(string); ok && id != "" {
+		return id, true
+	}
+	return "", false
+}
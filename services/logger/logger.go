@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	_logger "github.com/gin-generator/logger"
+	"go.uber.org/zap"
 )
 
 // Config logger configuration with validation tags
@@ -82,3 +83,13 @@ func Error(msg string, fields ...interface{}) error {
 	Log.Error(msg)
 	return nil
 }
+
+// ReportError logs err at Error level, on stdout before the logger is initialized.
+// Background work such as workers and relays uses it where no caller can handle err.
+func ReportError(msg string, err error) {
+	if Log != nil {
+		Log.Error(msg, zap.Error(err))
+		return
+	}
+	fmt.Printf("%s: %v\n", msg, err)
+}
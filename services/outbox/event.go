@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-generator/sugar/services/queue"
+	"sync"
+	"time"
+)
+
+// EventJobName queue job name of events
+const EventJobName = "sugar.outbox.event"
+
+func init() {
+	queue.RegisterJob[Event](EventJobName)
+}
+
+// Handler handles a relayed event
+type Handler func(ctx context.Context, event *Event) error
+
+var (
+	mu       sync.RWMutex
+	handlers = make(map[string][]Handler)
+)
+
+// Subscribe registers a handler for an event type
+func Subscribe(eventType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[eventType] = append(handlers[eventType], handler)
+}
+
+// Event queue job published by the relay for each outbox message
+type Event struct {
+	ID           uint64          `json:"id"`
+	AggregateKey string          `json:"aggregate_key"`
+	Type         string          `json:"type"`
+	Payload      json.RawMessage `json:"payload"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// NewEvent creates the event of an outbox message
+func NewEvent(msg Message) *Event {
+	return &Event{
+		ID:           msg.ID,
+		AggregateKey: msg.AggregateKey,
+		Type:         msg.Type,
+		Payload:      msg.Payload,
+		CreatedAt:    msg.CreatedAt,
+	}
+}
+
+// Decode decodes the payload into v
+func (e *Event) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Handle runs the handlers subscribed to the event type. Delivery is at least
+// once, handlers should be idempotent on the event ID. Ordering per aggregate
+// only holds up to the publication, see Write.
+func (e *Event) Handle(ctx context.Context) error {
+	mu.RLock()
+	subscribed := handlers[e.Type]
+	mu.RUnlock()
+
+	if len(subscribed) == 0 {
+		return fmt.Errorf("no handler subscribed to outbox event %s", e.Type)
+	}
+
+	for _, handler := range subscribed {
+		if err := handler(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
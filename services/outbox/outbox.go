@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Message status
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Config outbox configuration with validation tags
+type Config struct {
+	Connection  string `validate:"required"`        // database connection holding the outbox table
+	Queue       string `validate:"omitempty"`       // queue connection events are published to, default when empty
+	BatchSize   int    `validate:"omitempty,gt=0"`  // messages relayed per tick
+	Interval    int    `validate:"omitempty,gt=0"`  // milliseconds between ticks
+	MaxAttempts int    `validate:"omitempty,gte=0"` // publish attempts before a message is marked failed, 0 retries forever
+	Retention   int    `validate:"omitempty,gt=0"`  // hours delivered messages are kept
+	Lease       int    `validate:"omitempty,gt=0"`  // seconds a relay holds the messages it claimed, defaults to 60
+	AutoMigrate bool   `validate:"omitempty"`       // create the outbox table on boot
+	Relay       bool   `validate:"omitempty"`       // run the relay in this process
+}
+
+// Message outbox table row
+type Message struct {
+	ID           uint64     `gorm:"primaryKey;autoIncrement"`
+	AggregateKey string     `gorm:"size:191;not null;index"`
+	Type         string     `gorm:"size:191;not null"`
+	Payload      []byte     `gorm:"not null"`
+	Status       string     `gorm:"size:16;not null;default:pending;index:idx_outbox_status,priority:1"`
+	Attempts     int        `gorm:"not null;default:0"`
+	LastError    string     `gorm:"type:text"`
+	ClaimedUntil *time.Time `gorm:"index"` // end of the lease of the relay publishing the message
+	CreatedAt    time.Time
+	DeliveredAt  *time.Time `gorm:"index:idx_outbox_status,priority:2"`
+}
+
+// TableName returns the outbox table name
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Migrate creates or updates the outbox table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Write records an event in the outbox. Pass the transaction the business
+// rows are written in so the event is committed or rolled back with them.
+// Events sharing an aggregate key are published in the order they were written,
+// the queue workers do not serialize them on the key: concurrent workers and
+// retries may handle them out of order. Consume ordered aggregates from a
+// queue served by a single worker with a concurrency of one, or make handlers
+// tolerate reordering, e.g. by comparing the event ID with the last one applied.
+func Write(tx *gorm.DB, aggregateKey, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	return tx.Create(&Message{
+		AggregateKey: aggregateKey,
+		Type:         eventType,
+		Payload:      data,
+		Status:       StatusPending,
+	}).Error
+}
@@ -0,0 +1,311 @@
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"github.com/gin-generator/sugar/services/logger"
+	"github.com/gin-generator/sugar/services/queue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"time"
+)
+
+// Publisher publishes outbox messages
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// JobFactory builds the queue job of a message
+type JobFactory func(msg Message) (queue.Job, error)
+
+// QueuePublisher publishes messages as jobs on a queue connection
+type QueuePublisher struct {
+	manager    *queue.Manager
+	connection string
+	factory    JobFactory
+}
+
+// NewQueuePublisher creates a publisher on a queue connection, the default
+// connection when empty. Messages are published as *Event jobs.
+func NewQueuePublisher(manager *queue.Manager, connection string) *QueuePublisher {
+	return &QueuePublisher{
+		manager:    manager,
+		connection: connection,
+		factory: func(msg Message) (queue.Job, error) {
+			return NewEvent(msg), nil
+		},
+	}
+}
+
+// WithJobFactory replaces the job built for each message. Registered jobs are
+// dispatched in an envelope carrying the aggregate key, unregistered ones are
+// pushed as they are, which only drivers keeping jobs in memory accept.
+func (p *QueuePublisher) WithJobFactory(factory JobFactory) *QueuePublisher {
+	p.factory = factory
+	return p
+}
+
+// Publish dispatches the job of a message
+func (p *QueuePublisher) Publish(ctx context.Context, msg Message) error {
+	job, err := p.factory(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err = queue.JobName(job); err != nil {
+		return p.push(ctx, job)
+	}
+
+	opts := []queue.DispatchOption{queue.WithMetadata("aggregate_key", msg.AggregateKey)}
+	if p.connection != "" {
+		opts = append(opts, queue.OnConnection(p.connection))
+	}
+	return p.manager.Dispatch(ctx, job, opts...)
+}
+
+// push pushes an unregistered job onto the connection as it is
+func (p *QueuePublisher) push(ctx context.Context, job queue.Job) error {
+	var (
+		q   queue.Queue
+		err error
+	)
+	if p.connection == "" {
+		q, err = p.manager.Queue()
+	} else {
+		q, err = p.manager.Connection(p.connection)
+	}
+	if err != nil {
+		return err
+	}
+	return q.Push(ctx, "", job)
+}
+
+// Relay publishes pending outbox messages with at-least-once delivery
+type Relay struct {
+	db          *gorm.DB
+	publisher   Publisher
+	batchSize   int
+	interval    time.Duration
+	maxAttempts int
+	retention   time.Duration
+	lease       time.Duration
+}
+
+// NewRelay creates a relay reading the outbox table of db
+func NewRelay(db *gorm.DB, publisher Publisher, cfg Config) *Relay {
+	r := &Relay{
+		db:          db,
+		publisher:   publisher,
+		batchSize:   cfg.BatchSize,
+		interval:    time.Duration(cfg.Interval) * time.Millisecond,
+		maxAttempts: cfg.MaxAttempts,
+		retention:   time.Duration(cfg.Retention) * time.Hour,
+		lease:       time.Duration(cfg.Lease) * time.Second,
+	}
+
+	if r.batchSize <= 0 {
+		r.batchSize = 100
+	}
+	if r.interval <= 0 {
+		r.interval = time.Second
+	}
+	if r.retention <= 0 {
+		r.retention = 7 * 24 * time.Hour
+	}
+	if r.lease <= 0 {
+		r.lease = time.Minute
+	}
+	return r
+}
+
+// Run relays messages until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		// Drain full batches without waiting for the next tick
+		for {
+			n, err := r.Process(ctx)
+			if err != nil {
+				logger.ReportError("outbox relay failed", err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			if _, err := r.Cleanup(ctx); err != nil {
+				logger.ReportError("outbox cleanup failed", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process relays one batch of pending messages and returns how many were delivered.
+//
+// Messages are claimed for the lease in a short transaction and published
+// outside of it, so no row lock is held across broker I/O. A relay only claims
+// an aggregate through its head, the oldest message not delivered yet, which
+// concurrent relays lock with SKIP LOCKED. A message that fails to publish
+// blocks the later messages of its aggregate until it succeeds, once it exceeds
+// the maximum attempts it is marked failed and the aggregate stays parked until
+// Retry. Blocked aggregates take a single slot of the batch, so they never
+// starve the others.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	messages, until, err := r.claim(ctx)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	var (
+		count    int
+		released []uint64
+		blocked  = make(map[string]bool)
+	)
+	for _, msg := range messages {
+		// Past the lease another relay may claim the aggregate again
+		if blocked[msg.AggregateKey] || ctx.Err() != nil || time.Now().After(until) {
+			released = append(released, msg.ID)
+			continue
+		}
+
+		if err = r.publisher.Publish(ctx, msg); err != nil {
+			blocked[msg.AggregateKey] = true
+			if err = r.failed(ctx, msg, err); err != nil {
+				return count, err
+			}
+			continue
+		}
+
+		err = r.db.WithContext(ctx).Model(&Message{}).Where("id = ?", msg.ID).Updates(map[string]any{
+			"status":        StatusDelivered,
+			"attempts":      gorm.Expr("attempts + 1"),
+			"delivered_at":  time.Now(),
+			"claimed_until": nil,
+		}).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if len(released) > 0 {
+		err = r.db.WithContext(context.WithoutCancel(ctx)).Model(&Message{}).
+			Where("id IN ? AND status = ?", released, StatusPending).
+			Update("claimed_until", nil).Error
+	}
+	return count, err
+}
+
+// claim claims the next batch of messages in publishing order. The heads of up
+// to batchSize aggregates are claimed first, the remaining slots go to the
+// messages following them.
+func (r *Relay) claim(ctx context.Context) ([]Message, time.Time, error) {
+	var messages []Message
+	now := time.Now()
+	until := now.Add(r.lease)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var heads []Message
+		err := r.heads(tx, now).Find(&heads).Error
+		if err != nil || len(heads) == 0 {
+			return err
+		}
+
+		keys := make([]string, len(heads))
+		ids := make([]uint64, len(heads))
+		for i, head := range heads {
+			keys[i] = head.AggregateKey
+			ids[i] = head.ID
+		}
+
+		// Later messages of a claimed aggregate are never heads, no other relay can take them
+		var following []Message
+		if budget := r.batchSize - len(heads); budget > 0 {
+			err = tx.Where("aggregate_key IN ? AND status = ? AND id NOT IN ?", keys, StatusPending, ids).
+				Order("id").
+				Limit(budget).
+				Find(&following).Error
+			if err != nil {
+				return err
+			}
+			for _, msg := range following {
+				ids = append(ids, msg.ID)
+			}
+		}
+
+		err = tx.Model(&Message{}).Where("id IN ?", ids).Update("claimed_until", until).Error
+		if err != nil {
+			return err
+		}
+
+		messages = append(heads, following...)
+		slices.SortFunc(messages, func(a, b Message) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		return nil
+	})
+
+	return messages, until, err
+}
+
+// heads selects the claimable heads of aggregates, locking them with SKIP LOCKED
+func (r *Relay) heads(tx *gorm.DB, now time.Time) *gorm.DB {
+	table := Message{}.TableName()
+	earlier := tx.Session(&gorm.Session{NewDB: true}).
+		Table(table+" AS p").
+		Select("1").
+		Where("p.aggregate_key = m.aggregate_key AND p.id < m.id AND p.status <> ?", StatusDelivered)
+
+	return tx.Table(table+" AS m").
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "m"}, Options: "SKIP LOCKED"}).
+		Where("m.status = ?", StatusPending).
+		Where("m.claimed_until IS NULL OR m.claimed_until < ?", now).
+		Where("NOT EXISTS (?)", earlier).
+		Order("m.id").
+		Limit(r.batchSize)
+}
+
+// failed records a failed publish and releases the claim, marking the message
+// failed once it exceeds the maximum attempts
+func (r *Relay) failed(ctx context.Context, msg Message, cause error) error {
+	updates := map[string]any{
+		"attempts":      gorm.Expr("attempts + 1"),
+		"last_error":    cause.Error(),
+		"claimed_until": nil,
+	}
+	if r.maxAttempts > 0 && msg.Attempts+1 >= r.maxAttempts {
+		updates["status"] = StatusFailed
+	}
+	return r.db.WithContext(context.WithoutCancel(ctx)).Model(&Message{}).Where("id = ?", msg.ID).Updates(updates).Error
+}
+
+// Cleanup deletes delivered messages older than the retention period
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	tx := r.db.WithContext(ctx).
+		Where("status = ? AND delivered_at < ?", StatusDelivered, time.Now().Add(-r.retention)).
+		Delete(&Message{})
+	return tx.RowsAffected, tx.Error
+}
+
+// Retry resets failed messages to pending, resuming their parked aggregates
+func (r *Relay) Retry(ctx context.Context, ids ...uint64) (int64, error) {
+	tx := r.db.WithContext(ctx).Model(&Message{}).Where("status = ?", StatusFailed)
+	if len(ids) > 0 {
+		tx = tx.Where("id IN ?", ids)
+	}
+	tx = tx.Updates(map[string]any{"status": StatusPending, "attempts": 0, "claimed_until": nil})
+	return tx.RowsAffected, tx.Error
+}
@@ -0,0 +1,211 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-generator/sugar/services/queue"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPublisher publishes to a slice, failing the aggregates listed in fail
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []string
+	fail      map[string]bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail[msg.AggregateKey] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, msg.Type)
+	return nil
+}
+
+// take returns and clears the published message types
+func (p *recordingPublisher) take() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	published := strings.Join(p.published, ",")
+	p.published = nil
+	return published
+}
+
+// openOutbox opens a migrated outbox on a temporary SQLite database
+func openOutbox(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// write writes messages of an aggregate, the type names the message
+func write(t *testing.T, db *gorm.DB, aggregate string, types ...string) {
+	t.Helper()
+	for _, typ := range types {
+		if err := Write(db, aggregate, typ, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelayOrderingAndParking(t *testing.T) {
+	ctx := context.Background()
+	db := openOutbox(t)
+	publisher := &recordingPublisher{fail: map[string]bool{"a": true}}
+	relay := NewRelay(db, publisher, Config{BatchSize: 3, MaxAttempts: 2})
+
+	write(t, db, "a", "a1", "a2", "a3", "a4", "a5")
+	write(t, db, "b", "b1")
+
+	// The failing head of a takes one slot, b is still published
+	n, err := relay.Process(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published := publisher.take(); n != 1 || published != "b1" {
+		t.Fatalf("expected b1 to be published past the blocked aggregate, got %d %q", n, published)
+	}
+
+	// a1 exceeds the maximum attempts, the rest of a is parked behind it
+	if _, err = relay.Process(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var head Message
+	db.Where("type = ?", "a1").Take(&head)
+	if head.Status != StatusFailed || head.Attempts != 2 || head.LastError != "broker unavailable" || head.ClaimedUntil != nil {
+		t.Fatalf("expected a1 to be failed and released, got %+v", head)
+	}
+
+	delete(publisher.fail, "a")
+	if n, _ = relay.Process(ctx); n != 0 || publisher.take() != "" {
+		t.Fatal("expected the aggregate of a failed message to stay parked")
+	}
+
+	if n, _ := relay.Retry(ctx); n != 1 {
+		t.Fatalf("expected 1 message to be retried, got %d", n)
+	}
+	for range 2 {
+		if _, err = relay.Process(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if published := publisher.take(); published != "a1,a2,a3,a4,a5" {
+		t.Fatalf("expected a to be published in order, got %q", published)
+	}
+
+	var pending int64
+	db.Model(&Message{}).Where("status <> ?", StatusDelivered).Count(&pending)
+	if pending != 0 {
+		t.Errorf("expected every message to be delivered, %d left", pending)
+	}
+}
+
+func TestRelayClaims(t *testing.T) {
+	ctx := context.Background()
+	db := openOutbox(t)
+	relay := NewRelay(db, &recordingPublisher{}, Config{BatchSize: 10})
+
+	write(t, db, "a", "a1", "a2")
+	write(t, db, "b", "b1")
+
+	claimed, until, err := relay.claim(ctx)
+	if err != nil || len(claimed) != 3 {
+		t.Fatalf("expected 3 claimed messages, got %d, %v", len(claimed), err)
+	}
+	if until.Before(time.Now().Add(50 * time.Second)) {
+		t.Errorf("expected the default lease of a minute, got %v", time.Until(until))
+	}
+
+	// Claimed aggregates are skipped by other relays until the lease ends
+	write(t, db, "b", "b2")
+	write(t, db, "c", "c1")
+	if claimed, _, _ = relay.claim(ctx); len(claimed) != 1 || claimed[0].Type != "c1" {
+		t.Fatalf("expected only c1 to be claimable, got %+v", claimed)
+	}
+
+	db.Model(&Message{}).Where("type = ?", "a1").Update("claimed_until", time.Now().Add(-time.Second))
+	if claimed, _, _ = relay.claim(ctx); len(claimed) != 2 || claimed[0].Type != "a1" || claimed[1].Type != "a2" {
+		t.Fatalf("expected the expired lease of a to be claimable, got %+v", claimed)
+	}
+}
+
+func TestRelayHeadsStatement(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/app", SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relay := NewRelay(db, nil, Config{BatchSize: 10})
+	stmt := relay.heads(db, time.Now()).Find(&[]Message{}).Statement
+	want := "SELECT * FROM outbox_messages AS m WHERE m.status = ? AND (m.claimed_until IS NULL OR m.claimed_until < ?) AND " +
+		"NOT EXISTS (SELECT 1 FROM outbox_messages AS p WHERE p.aggregate_key = m.aggregate_key AND p.id < m.id AND p.status <> ?) " +
+		"ORDER BY m.id LIMIT ? FOR UPDATE OF `m` SKIP LOCKED"
+	if got := stmt.SQL.String(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+// rawJob a job left unregistered with the queue
+type rawJob struct {
+	key string
+}
+
+func (j *rawJob) Handle(ctx context.Context) error {
+	return nil
+}
+
+func TestQueuePublisher(t *testing.T) {
+	ctx := context.Background()
+	manager := queue.NewManager()
+	manager.AddConnection("memory", queue.NewMemoryQueue(0, ""))
+	q, _ := manager.Queue()
+	msg := Message{ID: 1, AggregateKey: "order:1", Type: "order.created"}
+
+	publisher := NewQueuePublisher(manager, "")
+	if err := publisher.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	delivery, err := q.Pop(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, ok := delivery.Job.(*queue.Envelope)
+	if !ok || envelope.Type != EventJobName || envelope.Metadata["aggregate_key"] != "order:1" {
+		t.Fatalf("expected an event envelope, got %+v", delivery.Job)
+	}
+
+	// Unregistered jobs are pushed as they are
+	publisher.WithJobFactory(func(msg Message) (queue.Job, error) {
+		return &rawJob{key: msg.AggregateKey}, nil
+	})
+	if err = publisher.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if delivery, err = q.Pop(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if job, ok := delivery.Job.(*rawJob); !ok || job.key != "order:1" {
+		t.Fatalf("expected the raw job, got %+v", delivery.Job)
+	}
+}
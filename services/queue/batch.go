@@ -0,0 +1,334 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/logger"
+	"github.com/google/uuid"
+	"time"
+)
+
+// MetadataBatchID metadata key naming the batch of a Then, Catch or Finally job
+const MetadataBatchID = "batch_id"
+
+// ErrBatchNotFound is returned when a batch does not exist
+var ErrBatchNotFound = errors.New("queue: batch not found")
+
+// BatchState persisted state of a batch
+type BatchState struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name,omitempty"`
+	Total         int        `json:"total"`
+	Pending       int        `json:"pending"` // jobs that did not succeed or fail for good yet
+	Failed        int        `json:"failed"`
+	FailedJobIDs  []string   `json:"failed_job_ids,omitempty"`
+	AllowFailures bool       `json:"allow_failures"`
+	Then          *Envelope  `json:"then,omitempty"`
+	Catch         *Envelope  `json:"catch,omitempty"`
+	Finally       *Envelope  `json:"finally,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// Processed returns the number of jobs that succeeded or failed for good
+func (s *BatchState) Processed() int {
+	return s.Total - s.Pending
+}
+
+// Progress returns the percentage of processed jobs
+func (s *BatchState) Progress() int {
+	if s.Total == 0 {
+		return 100
+	}
+	return s.Processed() * 100 / s.Total
+}
+
+// Cancelled checks if the batch was cancelled, explicitly or by a failure
+func (s *BatchState) Cancelled() bool {
+	return s.CancelledAt != nil
+}
+
+// Finished checks if every job of the batch was processed
+func (s *BatchState) Finished() bool {
+	return s.FinishedAt != nil
+}
+
+// BatchRepository stores batch states
+type BatchRepository interface {
+	Store(ctx context.Context, state *BatchState) error
+	Find(ctx context.Context, id string) (*BatchState, error)
+	// Update applies fn to a batch atomically across processes, returning the updated state
+	Update(ctx context.Context, id string, fn func(state *BatchState)) (*BatchState, error)
+	// MarkProcessed records a job of a batch as processed and applies fn in the same
+	// atomic update, the first time only. It reports whether fn ran, so a job
+	// delivered again is not counted twice.
+	MarkProcessed(ctx context.Context, id, jobID string, fn func(state *BatchState)) (*BatchState, bool, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// PendingBatch jobs dispatched together, tracked as a batch
+type PendingBatch struct {
+	manager       *Manager
+	jobs          []Job
+	name          string
+	then          Job
+	catch         Job
+	finally       Job
+	allowFailures bool
+}
+
+// Batch creates a batch of registered jobs on the global manager (Facade pattern)
+func Batch(jobs ...Job) *PendingBatch {
+	return manager.Batch(jobs...)
+}
+
+// Batch creates a batch of registered jobs
+func (m *Manager) Batch(jobs ...Job) *PendingBatch {
+	return &PendingBatch{manager: m, jobs: jobs}
+}
+
+// Name names the batch
+func (b *PendingBatch) Name(name string) *PendingBatch {
+	b.name = name
+	return b
+}
+
+// Then dispatches job once every job of the batch succeeded
+func (b *PendingBatch) Then(job Job) *PendingBatch {
+	b.then = job
+	return b
+}
+
+// Catch dispatches job when the first job of the batch fails for good
+func (b *PendingBatch) Catch(job Job) *PendingBatch {
+	b.catch = job
+	return b
+}
+
+// Finally dispatches job once every job of the batch was processed, whatever the outcome
+func (b *PendingBatch) Finally(job Job) *PendingBatch {
+	b.finally = job
+	return b
+}
+
+// AllowFailures keeps the batch running when a job fails, by default the first failure cancels it
+func (b *PendingBatch) AllowFailures() *PendingBatch {
+	b.allowFailures = true
+	return b
+}
+
+// Dispatch stores the batch and dispatches its jobs. Then, Catch and Finally jobs
+// are pushed on the connection and queue of the worker handling the batch and find
+// the batch ID through BatchIDFromContext.
+func (b *PendingBatch) Dispatch(ctx context.Context, opts ...DispatchOption) (*BatchState, error) {
+	if b.manager == nil {
+		return nil, fmt.Errorf("queue manager not initialized")
+	}
+	repository := b.manager.BatchRepository()
+	if repository == nil {
+		return nil, fmt.Errorf("queue batch repository not configured")
+	}
+
+	state := &BatchState{
+		ID:            uuid.NewString(),
+		Name:          b.name,
+		Total:         len(b.jobs),
+		Pending:       len(b.jobs),
+		AllowFailures: b.allowFailures,
+		CreatedAt:     time.Now(),
+	}
+	if state.Total == 0 {
+		state.FinishedAt = &state.CreatedAt
+	}
+
+	var err error
+	if state.Then, err = batchCallback(b.then, state.ID); err != nil {
+		return nil, err
+	}
+	if state.Catch, err = batchCallback(b.catch, state.ID); err != nil {
+		return nil, err
+	}
+	if state.Finally, err = batchCallback(b.finally, state.ID); err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]*Envelope, len(b.jobs))
+	for i, job := range b.jobs {
+		if envelopes[i], err = wrap(job); err != nil {
+			return nil, err
+		}
+		envelopes[i].BatchID = state.ID
+	}
+
+	if err = repository.Store(ctx, state); err != nil {
+		return nil, err
+	}
+	for i, envelope := range envelopes {
+		if err = b.manager.Dispatch(ctx, envelope, opts...); err != nil {
+			return b.abort(ctx, repository, state, len(envelopes)-i, opts), fmt.Errorf("failed to dispatch batch %s: %w", state.ID, err)
+		}
+	}
+	return state, nil
+}
+
+// abort cancels a batch whose last jobs could not be dispatched, dropping them
+// from its counts so the jobs already pushed can still finish it. The Finally
+// job is dispatched when the dropped jobs were the last ones pending.
+func (b *PendingBatch) abort(ctx context.Context, repository BatchRepository, state *BatchState, undispatched int, opts []DispatchOption) *BatchState {
+	ctx = context.WithoutCancel(ctx)
+	finished := false
+	updated, err := repository.Update(ctx, state.ID, func(state *BatchState) {
+		now := time.Now()
+		state.Total -= undispatched
+		state.Pending -= undispatched
+		if state.CancelledAt == nil {
+			state.CancelledAt = &now
+		}
+		if state.Pending == 0 && state.FinishedAt == nil {
+			state.FinishedAt = &now
+			finished = true
+		}
+	})
+	if err != nil {
+		logger.ReportError(fmt.Sprintf("queue batch %s failed to cancel after a dispatch failure", state.ID), err)
+		return state
+	}
+
+	if finished && updated.Finally != nil {
+		if err = b.manager.Dispatch(ctx, updated.Finally, opts...); err != nil {
+			logger.ReportError(fmt.Sprintf("queue batch %s failed to dispatch its finally job", state.ID), err)
+		}
+	}
+	return updated
+}
+
+// batchCallback wraps a Then, Catch or Finally job
+func batchCallback(job Job, id string) (*Envelope, error) {
+	if job == nil {
+		return nil, nil
+	}
+
+	envelope, err := wrap(job)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.Metadata == nil {
+		envelope.Metadata = make(map[string]string)
+	}
+	envelope.Metadata[MetadataBatchID] = id
+	return envelope, nil
+}
+
+// BatchIDFromContext returns the batch of the job being handled, for batch jobs and their callbacks
+func BatchIDFromContext(ctx context.Context) (string, bool) {
+	envelope, ok := EnvelopeFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	if envelope.BatchID != "" {
+		return envelope.BatchID, true
+	}
+	id, ok := envelope.Metadata[MetadataBatchID]
+	return id, ok
+}
+
+// SetBatchRepository sets the repository storing batch states
+func (m *Manager) SetBatchRepository(repository BatchRepository) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.batches = repository
+}
+
+// BatchRepository returns the repository storing batch states, nil when batches are not configured
+func (m *Manager) BatchRepository() BatchRepository {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.batches
+}
+
+// FindBatch returns the state of a batch
+func (m *Manager) FindBatch(ctx context.Context, id string) (*BatchState, error) {
+	repository := m.BatchRepository()
+	if repository == nil {
+		return nil, fmt.Errorf("queue batch repository not configured")
+	}
+	return repository.Find(ctx, id)
+}
+
+// CancelBatch cancels a batch, its jobs not handled yet are skipped and its Then job never runs
+func (m *Manager) CancelBatch(ctx context.Context, id string) error {
+	repository := m.BatchRepository()
+	if repository == nil {
+		return fmt.Errorf("queue batch repository not configured")
+	}
+
+	_, err := repository.Update(ctx, id, func(state *BatchState) {
+		if state.CancelledAt == nil {
+			now := time.Now()
+			state.CancelledAt = &now
+		}
+	})
+	return err
+}
+
+// FindBatch returns the state of a batch (Facade pattern)
+func FindBatch(ctx context.Context, id string) (*BatchState, error) {
+	if manager == nil {
+		return nil, fmt.Errorf("queue manager not initialized")
+	}
+	return manager.FindBatch(ctx, id)
+}
+
+// CancelBatch cancels a batch (Facade pattern)
+func CancelBatch(ctx context.Context, id string) error {
+	if manager == nil {
+		return fmt.Errorf("queue manager not initialized")
+	}
+	return manager.CancelBatch(ctx, id)
+}
+
+// recordBatchJob counts a processed job of a batch, returning the batch callbacks now due
+func recordBatchJob(ctx context.Context, repository BatchRepository, envelope *Envelope, failed bool) ([]*Envelope, error) {
+	counted := false
+	state, marked, err := repository.MarkProcessed(ctx, envelope.BatchID, envelope.ID, func(state *BatchState) {
+		if state.Pending == 0 {
+			return
+		}
+		counted = true
+
+		now := time.Now()
+		state.Pending--
+		if failed {
+			state.Failed++
+			state.FailedJobIDs = append(state.FailedJobIDs, envelope.ID)
+			if !state.AllowFailures && state.CancelledAt == nil {
+				state.CancelledAt = &now
+			}
+		}
+		if state.Pending == 0 && state.FinishedAt == nil {
+			state.FinishedAt = &now
+		}
+	})
+	if err != nil || !marked || !counted {
+		return nil, err
+	}
+
+	// The update is atomic, so a single job sees each transition
+	var callbacks []*Envelope
+	if failed && state.Failed == 1 && state.Catch != nil {
+		callbacks = append(callbacks, state.Catch)
+	}
+	if state.Pending == 0 {
+		if state.Failed == 0 && !state.Cancelled() && state.Then != nil {
+			callbacks = append(callbacks, state.Then)
+		}
+		if state.Finally != nil {
+			callbacks = append(callbacks, state.Finally)
+		}
+	}
+	return callbacks, nil
+}
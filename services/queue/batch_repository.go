@@ -0,0 +1,312 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/cache"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// BatchConfig batch repository configuration
+type BatchConfig struct {
+	Driver      string `validate:"required,oneof=cache database"`
+	Store       string `validate:"omitempty"`                   // cache store, the default one when empty
+	Connection  string `validate:"required_if=Driver database"` // database connection name
+	Table       string `validate:"omitempty"`                   // defaults to job_batches, processed jobs go to <table>_jobs
+	TTL         int    `validate:"omitempty,gt=0"`              // hours batches are kept in the cache, defaults to 24
+	AutoMigrate bool   `validate:"omitempty"`                   // create the table on boot
+}
+
+// CacheBatchRepository batch repository on a cache store. Updates hold a lock
+// of the store, so the store must implement cache.Locker.
+type CacheBatchRepository struct {
+	store cache.Cache
+	ttl   time.Duration
+}
+
+// NewCacheBatchRepository creates a cache batch repository keeping batches for ttl, 24 hours when 0
+func NewCacheBatchRepository(store cache.Cache, ttl time.Duration) *CacheBatchRepository {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &CacheBatchRepository{store: store, ttl: ttl}
+}
+
+// key returns the cache key of a batch
+func (r *CacheBatchRepository) key(id string) string {
+	return "queue:batch:" + id
+}
+
+// Store stores a batch
+func (r *CacheBatchRepository) Store(ctx context.Context, state *BatchState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.store.Set(ctx, r.key(state.ID), string(data), r.ttl)
+}
+
+// Find returns a batch
+func (r *CacheBatchRepository) Find(ctx context.Context, id string) (*BatchState, error) {
+	data, err := r.store.Get(ctx, r.key(id))
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(BatchState)
+	if err = json.Unmarshal([]byte(data), state); err != nil {
+		return nil, fmt.Errorf("failed to decode batch %s: %w", id, err)
+	}
+	return state, nil
+}
+
+// processedKey returns the cache key marking a job of a batch as processed
+func (r *CacheBatchRepository) processedKey(id, jobID string) string {
+	return r.key(id) + ":processed:" + jobID
+}
+
+// lock holds the lock of a batch, returning the function releasing it
+func (r *CacheBatchRepository) lock(ctx context.Context, id string) (func(), error) {
+	lock, err := cache.NewLock(r.store, r.key(id), 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update batch %s: %w", id, err)
+	}
+	if err = lock.Block(ctx, 10*time.Second); err != nil {
+		return nil, err
+	}
+	return func() { _ = lock.Release(context.WithoutCancel(ctx)) }, nil
+}
+
+// Update applies fn to a batch under a lock, returning the updated state
+func (r *CacheBatchRepository) Update(ctx context.Context, id string, fn func(state *BatchState)) (*BatchState, error) {
+	release, err := r.lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	state, err := r.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	fn(state)
+	return state, r.Store(ctx, state)
+}
+
+// MarkProcessed applies fn to a batch under a lock the first time jobID is
+// marked. Each job has its own key, expiring with the batch.
+func (r *CacheBatchRepository) MarkProcessed(ctx context.Context, id, jobID string, fn func(state *BatchState)) (*BatchState, bool, error) {
+	release, err := r.lock(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+
+	state, err := r.Find(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	processed, err := r.store.Has(ctx, r.processedKey(id, jobID))
+	if err != nil || processed {
+		return state, false, err
+	}
+
+	fn(state)
+	if err = r.Store(ctx, state); err != nil {
+		return nil, false, err
+	}
+	return state, true, r.store.Set(ctx, r.processedKey(id, jobID), "1", r.ttl)
+}
+
+// Delete removes a batch
+func (r *CacheBatchRepository) Delete(ctx context.Context, id string) error {
+	return r.store.Delete(ctx, r.key(id))
+}
+
+// batchRecord row of the batches table, the state is kept as JSON
+type batchRecord struct {
+	ID         string     `gorm:"primaryKey;size:64"`
+	Name       string     `gorm:"size:191"`
+	State      []byte     `gorm:"not null"`
+	CreatedAt  time.Time  `gorm:"not null"`
+	FinishedAt *time.Time `gorm:"index"`
+}
+
+// batchJobRecord row of the processed jobs table, one per job counted by a batch
+type batchJobRecord struct {
+	BatchID   string    `gorm:"primaryKey;size:64"`
+	JobID     string    `gorm:"primaryKey;size:64"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// DatabaseBatchRepository batch repository on a database table, updates lock the
+// row of the batch. Processed jobs are kept in a second table suffixed _jobs.
+type DatabaseBatchRepository struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewDatabaseBatchRepository creates a database batch repository
+func NewDatabaseBatchRepository(db *gorm.DB, table string, autoMigrate bool) (*DatabaseBatchRepository, error) {
+	if table == "" {
+		table = "job_batches"
+	}
+
+	r := &DatabaseBatchRepository{db: db, table: table}
+	if autoMigrate {
+		if err := r.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// jobs returns the name of the processed jobs table
+func (r *DatabaseBatchRepository) jobs() string {
+	return r.table + "_jobs"
+}
+
+// Migrate creates or updates the batches and processed jobs tables
+func (r *DatabaseBatchRepository) Migrate() error {
+	if err := r.db.Table(r.table).AutoMigrate(&batchRecord{}); err != nil {
+		return err
+	}
+	return r.db.Table(r.jobs()).AutoMigrate(&batchJobRecord{})
+}
+
+// record encodes a batch into a row
+func (r *DatabaseBatchRepository) record(state *BatchState) (*batchRecord, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return &batchRecord{
+		ID:         state.ID,
+		Name:       state.Name,
+		State:      data,
+		CreatedAt:  state.CreatedAt,
+		FinishedAt: state.FinishedAt,
+	}, nil
+}
+
+// Store stores a batch
+func (r *DatabaseBatchRepository) Store(ctx context.Context, state *BatchState) error {
+	record, err := r.record(state)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Table(r.table).Save(record).Error
+}
+
+// find reads a batch, locking its row for the rest of the transaction when lock is set
+func (r *DatabaseBatchRepository) find(tx *gorm.DB, id string, lock bool) (*BatchState, error) {
+	query := tx.Table(r.table).Where("id = ?", id)
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var record batchRecord
+	err := query.Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(BatchState)
+	if err = json.Unmarshal(record.State, state); err != nil {
+		return nil, fmt.Errorf("failed to decode batch %s: %w", id, err)
+	}
+	return state, nil
+}
+
+// Find returns a batch
+func (r *DatabaseBatchRepository) Find(ctx context.Context, id string) (*BatchState, error) {
+	return r.find(r.db.WithContext(ctx), id, false)
+}
+
+// Update applies fn to a batch while its row is locked, returning the updated state
+func (r *DatabaseBatchRepository) Update(ctx context.Context, id string, fn func(state *BatchState)) (*BatchState, error) {
+	var state *BatchState
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if state, err = r.find(tx, id, true); err != nil {
+			return err
+		}
+		fn(state)
+
+		record, err := r.record(state)
+		if err != nil {
+			return err
+		}
+		return tx.Table(r.table).Save(record).Error
+	})
+	return state, err
+}
+
+// MarkProcessed applies fn to a batch while its row is locked, the first time
+// jobID is inserted in the processed jobs table
+func (r *DatabaseBatchRepository) MarkProcessed(ctx context.Context, id, jobID string, fn func(state *BatchState)) (*BatchState, bool, error) {
+	var state *BatchState
+	marked := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if state, err = r.find(tx, id, true); err != nil {
+			return err
+		}
+
+		// The locked batch row serializes the marks of its jobs
+		var count int64
+		if err = tx.Table(r.jobs()).Where("batch_id = ? AND job_id = ?", id, jobID).Count(&count).Error; err != nil || count > 0 {
+			return err
+		}
+		if err = tx.Table(r.jobs()).Create(&batchJobRecord{BatchID: id, JobID: jobID, CreatedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		fn(state)
+		marked = true
+
+		record, err := r.record(state)
+		if err != nil {
+			return err
+		}
+		return tx.Table(r.table).Save(record).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return state, marked, nil
+}
+
+// Delete removes a batch and its processed jobs
+func (r *DatabaseBatchRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(r.jobs()).Where("batch_id = ?", id).Delete(&batchJobRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Table(r.table).Where("id = ?", id).Delete(&batchRecord{}).Error
+	})
+}
+
+// Prune removes the batches finished before a time and their processed jobs
+func (r *DatabaseBatchRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	var pruned int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		finished := tx.Table(r.table).Select("id").Where("finished_at < ?", before)
+		if err := tx.Table(r.jobs()).Where("batch_id IN (?)", finished).Delete(&batchJobRecord{}).Error; err != nil {
+			return err
+		}
+		result := tx.Table(r.table).Where("finished_at < ?", before).Delete(&batchRecord{})
+		pruned = result.RowsAffected
+		return result.Error
+	})
+	return pruned, err
+}
@@ -0,0 +1,352 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-generator/sugar/services/cache"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stepLog records the steps run by stepJob
+var stepLog = struct {
+	mu    sync.Mutex
+	steps []string
+}{}
+
+type stepJob struct {
+	Step string `json:"step"`
+	Fail bool   `json:"fail"`
+}
+
+func (j *stepJob) Handle(ctx context.Context) error {
+	stepLog.mu.Lock()
+	stepLog.steps = append(stepLog.steps, j.Step)
+	stepLog.mu.Unlock()
+
+	if j.Fail {
+		return Permanent(errors.New(j.Step + " failed"))
+	}
+	return nil
+}
+
+func init() {
+	RegisterJob[stepJob]("test.step")
+}
+
+// runSteps runs a worker on the memory connection of m until the queue drained, returning the steps run
+func runSteps(t *testing.T, m *Manager) []string {
+	t.Helper()
+	stepLog.mu.Lock()
+	stepLog.steps = nil
+	stepLog.mu.Unlock()
+
+	q, _ := m.Queue()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	NewWorker("memory", q, WorkerConfig{Block: 1}).WithBatchRepository(m.BatchRepository()).Run(ctx)
+
+	stepLog.mu.Lock()
+	defer stepLog.mu.Unlock()
+	return stepLog.steps
+}
+
+func newStepManager() *Manager {
+	m := NewManager()
+	m.AddConnection("memory", NewMemoryQueue(0, ""))
+	m.SetBatchRepository(NewCacheBatchRepository(cache.NewMemoryStore(cache.MemoryConfig{}), 0))
+	return m
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	m := newStepManager()
+
+	err := m.Chain(&stepJob{Step: "a"}, &stepJob{Step: "b"}, &stepJob{Step: "c"}).Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps := runSteps(t, m); len(steps) != 3 || steps[0] != "a" || steps[1] != "b" || steps[2] != "c" {
+		t.Fatalf("expected a, b, c in order, got %v", steps)
+	}
+
+	err = m.Chain(&stepJob{Step: "a"}, &stepJob{Step: "b", Fail: true}, &stepJob{Step: "c"}).
+		Catch(&stepJob{Step: "catch"}).
+		Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps := runSteps(t, m); len(steps) != 3 || steps[2] != "catch" {
+		t.Fatalf("expected the chain to stop at b and run catch, got %v", steps)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	m := newStepManager()
+
+	state, err := m.Batch(&stepJob{Step: "1"}, &stepJob{Step: "2"}).
+		Then(&stepJob{Step: "then"}).
+		Catch(&stepJob{Step: "catch"}).
+		Finally(&stepJob{Step: "finally"}).
+		Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps := runSteps(t, m); len(steps) != 4 || steps[2] != "then" || steps[3] != "finally" {
+		t.Fatalf("expected both jobs then the then and finally callbacks, got %v", steps)
+	}
+	if state, _ = m.FindBatch(ctx, state.ID); !state.Finished() || state.Progress() != 100 {
+		t.Fatalf("expected the batch to be finished, got %+v", state)
+	}
+
+	state, _ = m.Batch(&stepJob{Step: "1", Fail: true}, &stepJob{Step: "2"}, &stepJob{Step: "3"}).
+		Then(&stepJob{Step: "then"}).
+		Catch(&stepJob{Step: "catch"}).
+		Finally(&stepJob{Step: "finally"}).
+		AllowFailures().
+		Dispatch(ctx)
+	steps := runSteps(t, m)
+	if len(steps) != 5 || steps[3] != "catch" || steps[4] != "finally" {
+		t.Fatalf("expected catch and finally without then, got %v", steps)
+	}
+	if state, _ = m.FindBatch(ctx, state.ID); state.Failed != 1 || state.Cancelled() {
+		t.Fatalf("expected one failure without cancellation, got %+v", state)
+	}
+
+	// Cancelled batches skip their remaining jobs
+	state, _ = m.Batch(&stepJob{Step: "1"}, &stepJob{Step: "2"}).Finally(&stepJob{Step: "finally"}).Dispatch(ctx)
+	if err = m.CancelBatch(ctx, state.ID); err != nil {
+		t.Fatal(err)
+	}
+	if steps = runSteps(t, m); len(steps) != 1 || steps[0] != "finally" {
+		t.Fatalf("expected only the finally callback, got %v", steps)
+	}
+}
+
+// testBatchRepository checks the behavior shared by batch repositories
+func testBatchRepository(t *testing.T, repository BatchRepository) {
+	ctx := context.Background()
+	if _, err := repository.Find(ctx, "missing"); !errors.Is(err, ErrBatchNotFound) {
+		t.Fatalf("expected ErrBatchNotFound, got %v", err)
+	}
+	if _, err := repository.Update(ctx, "missing", func(state *BatchState) {}); !errors.Is(err, ErrBatchNotFound) {
+		t.Fatalf("expected ErrBatchNotFound on update, got %v", err)
+	}
+
+	created := time.Now().Truncate(time.Second)
+	if err := repository.Store(ctx, &BatchState{ID: "b1", Name: "import", Total: 2, Pending: 2, CreatedAt: created}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent updates are serialized
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repository.Update(ctx, "b1", func(state *BatchState) { state.Pending-- }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	state, err := repository.Find(ctx, "b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Name != "import" || state.Pending != 0 || !state.CreatedAt.Equal(created) {
+		t.Fatalf("unexpected batch %+v", state)
+	}
+
+	// A job is marked once, concurrent marks included
+	var marks atomic.Int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, marked, err := repository.MarkProcessed(ctx, "b1", "job", func(state *BatchState) { state.Failed++ })
+			if err != nil {
+				t.Error(err)
+			}
+			if marked {
+				marks.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if state, _, err = repository.MarkProcessed(ctx, "b1", "other", func(state *BatchState) { state.Failed++ }); err != nil {
+		t.Fatal(err)
+	}
+	if marks.Load() != 1 || state.Failed != 2 {
+		t.Fatalf("expected each job to be marked once, got %d marks and %+v", marks.Load(), state)
+	}
+	if _, _, err = repository.MarkProcessed(ctx, "missing", "job", func(state *BatchState) {}); !errors.Is(err, ErrBatchNotFound) {
+		t.Fatalf("expected ErrBatchNotFound on mark, got %v", err)
+	}
+
+	if err = repository.Delete(ctx, "b1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repository.Find(ctx, "b1"); !errors.Is(err, ErrBatchNotFound) {
+		t.Fatalf("expected the deleted batch to be missing, got %v", err)
+	}
+}
+
+func TestBatchRepositories(t *testing.T) {
+	t.Run("cache", func(t *testing.T) {
+		testBatchRepository(t, NewCacheBatchRepository(cache.NewMemoryStore(cache.MemoryConfig{}), 0))
+	})
+	t.Run("database", func(t *testing.T) {
+		repository, err := NewDatabaseBatchRepository(openQueueDB(t), "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testBatchRepository(t, repository)
+	})
+}
+
+func TestDatabaseBatchRepositoryPrune(t *testing.T) {
+	ctx := context.Background()
+	repository, err := NewDatabaseBatchRepository(openQueueDB(t), "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, recent := time.Now().Add(-48*time.Hour), time.Now()
+	_ = repository.Store(ctx, &BatchState{ID: "old", FinishedAt: &old})
+	_ = repository.Store(ctx, &BatchState{ID: "recent", FinishedAt: &recent})
+	_ = repository.Store(ctx, &BatchState{ID: "running"})
+
+	_, _, _ = repository.MarkProcessed(ctx, "old", "job", func(state *BatchState) {})
+	_, _, _ = repository.MarkProcessed(ctx, "recent", "job", func(state *BatchState) {})
+
+	if n, err := repository.Prune(ctx, time.Now().Add(-24*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected the old batch to be pruned, got %d (%v)", n, err)
+	}
+	var jobs []string
+	repository.db.Table(repository.jobs()).Pluck("batch_id", &jobs)
+	if len(jobs) != 1 || jobs[0] != "recent" {
+		t.Fatalf("expected the processed jobs of the old batch to be pruned, got %v", jobs)
+	}
+	for _, id := range []string{"recent", "running"} {
+		if _, err = repository.Find(ctx, id); err != nil {
+			t.Errorf("expected batch %s to be kept, got %v", id, err)
+		}
+	}
+}
+
+func TestDatabaseBatchRepositoryLocksUpdates(t *testing.T) {
+	db, recorder := dryRun(t)
+	repository, _ := NewDatabaseBatchRepository(db, "", false)
+
+	_, _ = repository.find(db, "b1", true)
+	want := "SELECT * FROM `job_batches` WHERE id = 'b1' LIMIT 1 FOR UPDATE"
+	if len(recorder.statements) != 1 || recorder.statements[0] != want {
+		t.Errorf("expected %s, got %v", want, recorder.statements)
+	}
+}
+
+func TestRecordBatchJobCountsEachJobOnce(t *testing.T) {
+	ctx := context.Background()
+	repository := NewCacheBatchRepository(cache.NewMemoryStore(cache.MemoryConfig{}), 0)
+	finally := &Envelope{Type: "test.finally"}
+	_ = repository.Store(ctx, &BatchState{ID: "b1", Total: 2, Pending: 2, Finally: finally})
+
+	first := &Envelope{ID: "first", BatchID: "b1"}
+	for i := 0; i < 2; i++ {
+		if callbacks, err := recordBatchJob(ctx, repository, first, false); err != nil || len(callbacks) != 0 {
+			t.Fatalf("expected no callbacks, got %v (%v)", callbacks, err)
+		}
+	}
+	if state, _ := repository.Find(ctx, "b1"); state.Pending != 1 {
+		t.Fatalf("expected a redelivered job to be counted once, %d pending", state.Pending)
+	}
+
+	callbacks, err := recordBatchJob(ctx, repository, &Envelope{ID: "second", BatchID: "b1"}, false)
+	if err != nil || len(callbacks) != 1 || callbacks[0].Type != finally.Type {
+		t.Fatalf("expected the finally callback, got %v (%v)", callbacks, err)
+	}
+}
+
+// limitedQueue legacy queue refusing jobs beyond its limit
+type limitedQueue struct {
+	sliceQueue
+	limit int
+}
+
+func (q *limitedQueue) Push(job Job) error {
+	if n, _ := q.Size(); n >= q.limit {
+		return ErrQueueFull
+	}
+	return q.sliceQueue.Push(job)
+}
+
+func TestBatchPartialDispatch(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager()
+	m.AddConnection("limited", Adapt(&limitedQueue{limit: 1}, 0))
+	m.SetBatchRepository(NewCacheBatchRepository(cache.NewMemoryStore(cache.MemoryConfig{}), 0))
+
+	state, err := m.Batch(&stepJob{Step: "1"}, &stepJob{Step: "2"}, &stepJob{Step: "3"}).Dispatch(ctx)
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected the dispatch to fail, got %v", err)
+	}
+	if state.Total != 1 || state.Pending != 1 || !state.Cancelled() {
+		t.Fatalf("expected the batch to be cancelled with the dispatched job only, got %+v", state)
+	}
+	if stored, _ := m.FindBatch(ctx, state.ID); stored.Total != 1 || stored.Pending != 1 {
+		t.Fatalf("expected the stored counts to match, got %+v", stored)
+	}
+}
+
+// batchRefusingQueue legacy queue refusing the jobs of batches, accepting their callbacks
+type batchRefusingQueue struct {
+	sliceQueue
+}
+
+func (q *batchRefusingQueue) Push(job Job) error {
+	if envelope, ok := job.(*Envelope); ok && envelope.BatchID != "" {
+		return ErrQueueFull
+	}
+	return q.sliceQueue.Push(job)
+}
+
+func TestBatchAbortRunsFinally(t *testing.T) {
+	ctx := context.Background()
+	q := &batchRefusingQueue{}
+	m := NewManager()
+	m.AddConnection("refusing", Adapt(q, 0))
+	m.SetBatchRepository(NewCacheBatchRepository(cache.NewMemoryStore(cache.MemoryConfig{}), 0))
+
+	// No job was dispatched, so no worker would ever finish the batch
+	state, err := m.Batch(&stepJob{Step: "1"}).Finally(&stepJob{Step: "finally"}).Dispatch(ctx)
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected the dispatch to fail, got %v", err)
+	}
+	if !state.Finished() || !state.Cancelled() {
+		t.Fatalf("expected the batch to be cancelled and finished, got %+v", state)
+	}
+
+	job, _ := q.Pop()
+	if envelope, ok := job.(*Envelope); !ok || envelope.Metadata[MetadataBatchID] != state.ID {
+		t.Fatalf("expected the finally job of the batch, got %#v", job)
+	}
+}
+
+func TestCacheBatchRepositoryRequiresLocks(t *testing.T) {
+	ctx := context.Background()
+	store, err := cache.NewFileStore(cache.FileConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := NewCacheBatchRepository(cache.NewInstrumented("file", store), 0)
+	_ = repository.Store(ctx, &BatchState{ID: "b1", Total: 1, Pending: 1})
+
+	if _, err = repository.Update(ctx, "b1", func(state *BatchState) { state.Pending-- }); err == nil {
+		t.Fatal("expected updates to need a store supporting locks")
+	}
+}
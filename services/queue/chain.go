@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+)
+
+// PendingChain jobs run one after another, each dispatched once the previous one succeeded
+type PendingChain struct {
+	manager *Manager
+	jobs    []Job
+	catch   Job
+}
+
+// Chain creates a chain of registered jobs on the global manager (Facade pattern)
+func Chain(jobs ...Job) *PendingChain {
+	return manager.Chain(jobs...)
+}
+
+// Chain creates a chain of registered jobs
+func (m *Manager) Chain(jobs ...Job) *PendingChain {
+	return &PendingChain{manager: m, jobs: jobs}
+}
+
+// Catch dispatches job when a job of the chain fails for good, the rest of the chain is dropped
+func (c *PendingChain) Catch(job Job) *PendingChain {
+	c.catch = job
+	return c
+}
+
+// Dispatch dispatches the first job of the chain, the others travel in its envelope
+// and are pushed on the same connection and queue
+func (c *PendingChain) Dispatch(ctx context.Context, opts ...DispatchOption) error {
+	if c.manager == nil {
+		return fmt.Errorf("queue manager not initialized")
+	}
+	if len(c.jobs) == 0 {
+		return fmt.Errorf("queue chain is empty")
+	}
+
+	envelopes := make([]*Envelope, len(c.jobs))
+	for i, job := range c.jobs {
+		envelope, err := wrap(job)
+		if err != nil {
+			return err
+		}
+		envelopes[i] = envelope
+	}
+
+	first := envelopes[0]
+	first.Chain = envelopes[1:]
+	if c.catch != nil {
+		catch, err := wrap(c.catch)
+		if err != nil {
+			return err
+		}
+		first.ChainCatch = catch
+	}
+	return c.manager.Dispatch(ctx, first, opts...)
+}
+
+// next returns the next job of a chain, carrying the rest of it along with the metadata of the current job
+func (e *Envelope) next() *Envelope {
+	if len(e.Chain) == 0 {
+		return nil
+	}
+
+	next := e.Chain[0]
+	next.Chain = e.Chain[1:]
+	next.ChainCatch = e.ChainCatch
+	if next.Metadata == nil {
+		next.Metadata = make(map[string]string)
+	}
+	for key, value := range e.Metadata {
+		if _, ok := next.Metadata[key]; !ok {
+			next.Metadata[key] = value
+		}
+	}
+	return next
+}
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrNoFailedStore is returned when no failed job store is configured
+var ErrNoFailedStore = errors.New("queue: failed job store not configured")
+
+// RetryFailed pushes a failed job back on its connection with its attempts reset, then forgets it
+func (m *Manager) RetryFailed(ctx context.Context, id string) error {
+	store := m.FailedStore()
+	if store == nil {
+		return ErrNoFailedStore
+	}
+
+	failed, err := store.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := failed.Envelope()
+	if err != nil {
+		return err
+	}
+	envelope.Attempts = 0
+
+	queue, err := m.Connection(failed.Connection)
+	if err != nil {
+		return err
+	}
+	if err = queue.Push(ctx, failed.Queue, envelope); err != nil {
+		return fmt.Errorf("failed to push job %s: %w", id, err)
+	}
+	return store.Forget(ctx, id)
+}
+
+// RunFailedCommand runs a failed job command, for applications exposing them from their own binary:
+//
+//	list [-limit n] [-offset n]   list failed jobs, most recent first
+//	retry <id>... | retry all     push failed jobs back on their connection
+//	forget <id>...                remove failed jobs
+//	purge [-hours n]              remove failed jobs older than n hours, all of them by default
+func RunFailedCommand(ctx context.Context, m *Manager, args []string, out io.Writer) error {
+	store := m.FailedStore()
+	if store == nil {
+		return ErrNoFailedStore
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: list | retry <id>... | forget <id>... | purge")
+	}
+
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("list", flag.ContinueOnError)
+		limit := flags.Int("limit", 50, "maximum jobs listed")
+		offset := flags.Int("offset", 0, "jobs skipped")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		jobs, err := store.List(ctx, *limit, *offset)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCONNECTION\tTYPE\tFAILED AT\tERROR")
+		for _, job := range jobs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", job.ID, job.Connection, job.Type, job.FailedAt.Format(time.DateTime), job.Error)
+		}
+		return w.Flush()
+
+	case "retry":
+		ids := args[1:]
+		if len(ids) == 1 && ids[0] == "all" {
+			ids = ids[:0]
+			for {
+				jobs, err := store.List(ctx, 100, len(ids))
+				if err != nil {
+					return err
+				}
+				for _, job := range jobs {
+					ids = append(ids, job.ID)
+				}
+				if len(jobs) < 100 {
+					break
+				}
+			}
+		}
+
+		for _, id := range ids {
+			if err := m.RetryFailed(ctx, id); err != nil {
+				return fmt.Errorf("retry %s: %w", id, err)
+			}
+			fmt.Fprintf(out, "retried %s\n", id)
+		}
+		return nil
+
+	case "forget":
+		for _, id := range args[1:] {
+			if err := store.Forget(ctx, id); err != nil {
+				return fmt.Errorf("forget %s: %w", id, err)
+			}
+			fmt.Fprintf(out, "forgot %s\n", id)
+		}
+		return nil
+
+	case "purge":
+		flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+		hours := flags.Int("hours", 0, "only purge jobs that failed more than n hours ago")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		var before time.Time
+		if *hours > 0 {
+			before = time.Now().Add(-time.Duration(*hours) * time.Hour)
+		}
+
+		n, err := store.Purge(ctx, before)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "purged %d failed jobs\n", n)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown failed job command %s", args[0])
+	}
+}
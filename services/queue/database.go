@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// jobRecord row of the jobs table
+type jobRecord struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement"`
+	Queue         string     `gorm:"size:191;not null;index:idx_jobs_pop,priority:1"`
+	Priority      int        `gorm:"not null;default:0;index:idx_jobs_pop,priority:2"`
+	Payload       []byte     `gorm:"not null"`
+	Reservations  int        `gorm:"not null;default:0"`                     // times the job was popped
+	AvailableAt   time.Time  `gorm:"not null;index:idx_jobs_pop,priority:3"` // delayed jobs are not popped before
+	ReservedUntil *time.Time `gorm:"index"`                                  // popped jobs are invisible until, then popped again
+	CreatedAt     time.Time  `gorm:"not null"`
+}
+
+// DatabaseQueue queue on a database table. Jobs are popped with
+// SELECT ... FOR UPDATE SKIP LOCKED, so concurrent consumers never pop the same
+// row, and stay in the table reserved until they are acknowledged. Jobs of a
+// consumer that died mid-handle are popped again once their reservation expires.
+// Requires MySQL 8 or PostgreSQL 9.5 and later.
+type DatabaseQueue struct {
+	db         *gorm.DB
+	table      string
+	queue      string
+	visibility time.Duration
+	poll       time.Duration
+}
+
+// NewDatabaseQueue creates a database queue on a connection of the database manager
+func NewDatabaseQueue(db *gorm.DB, cfg ConnectionConfig) (*DatabaseQueue, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database queue connection is not configured")
+	}
+
+	q := NewDatabaseQueueFromDB(db, cfg.Table, cfg.Queue, time.Duration(cfg.Visibility)*time.Second)
+	if cfg.Poll > 0 {
+		q.poll = time.Duration(cfg.Poll) * time.Millisecond
+	}
+	if cfg.AutoMigrate {
+		if err := q.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// NewDatabaseQueueFromDB creates a database queue on a table, jobs when empty, queue is used when none is named
+func NewDatabaseQueueFromDB(db *gorm.DB, table, queue string, visibility time.Duration) *DatabaseQueue {
+	if table == "" {
+		table = "jobs"
+	}
+	if visibility <= 0 {
+		visibility = 90 * time.Second
+	}
+	return &DatabaseQueue{
+		db:         db,
+		table:      table,
+		queue:      queueName(queue),
+		visibility: visibility,
+		poll:       time.Second,
+	}
+}
+
+// Migrate creates or updates the jobs table
+func (q *DatabaseQueue) Migrate() error {
+	return q.db.Table(q.table).AutoMigrate(&jobRecord{})
+}
+
+// name returns the name of a queue, the connection queue when empty
+func (q *DatabaseQueue) name(queue string) string {
+	if queue == "" {
+		return q.queue
+	}
+	return queue
+}
+
+// Push inserts a job available now
+func (q *DatabaseQueue) Push(ctx context.Context, queue string, job Job) error {
+	return q.PushDelayed(ctx, queue, job, time.Now())
+}
+
+// PushDelayed inserts a job available at a time
+func (q *DatabaseQueue) PushDelayed(ctx context.Context, queue string, job Job, at time.Time) error {
+	envelope, err := wrap(job)
+	if err != nil {
+		return err
+	}
+	payload, err := envelope.Encode()
+	if err != nil {
+		return err
+	}
+
+	return q.db.WithContext(ctx).Table(q.table).Create(&jobRecord{
+		Queue:       q.name(queue),
+		Priority:    envelope.Priority,
+		Payload:     payload,
+		AvailableAt: at,
+		CreatedAt:   time.Now(),
+	}).Error
+}
+
+// Pop reserves the available job of highest priority, oldest first, polling until ctx is done
+func (q *DatabaseQueue) Pop(ctx context.Context, queue string) (*Delivery, error) {
+	return poll(ctx, q.poll, func() (*Delivery, error) {
+		return q.reserve(ctx, queue)
+	})
+}
+
+// reserve reserves the available job of highest priority
+func (q *DatabaseQueue) reserve(ctx context.Context, queue string) (*Delivery, error) {
+	now := time.Now()
+
+	var record jobRecord
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := q.next(tx, queue, now).Take(&record).Error
+		if err != nil {
+			return err
+		}
+
+		record.Reservations++
+		return tx.Table(q.table).Where("id = ?", record.ID).Updates(map[string]any{
+			"reservations":   record.Reservations,
+			"reserved_until": now.Add(q.visibility),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reservation := &databaseReservation{queue: q, id: record.ID, reservations: record.Reservations}
+	envelope, err := DecodeEnvelope(record.Payload)
+	if err != nil {
+		// Drop the undecodable row so it is not popped forever
+		_ = reservation.Ack(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return NewDelivery(envelope, record.Queue, reservation), nil
+}
+
+// Size returns the number of available jobs
+func (q *DatabaseQueue) Size(ctx context.Context, queue string) (int, error) {
+	now := time.Now()
+
+	var n int64
+	err := q.available(q.db.WithContext(ctx), queue, now).Count(&n).Error
+	return int(n), err
+}
+
+// available starts a query on the jobs of a queue that are due and not reserved
+func (q *DatabaseQueue) available(tx *gorm.DB, queue string, now time.Time) *gorm.DB {
+	return tx.Table(q.table).
+		Where("queue = ? AND available_at <= ?", q.name(queue), now).
+		Where("reserved_until IS NULL OR reserved_until <= ?", now)
+}
+
+// next starts a query locking the available job of highest priority, skipping rows other consumers locked
+func (q *DatabaseQueue) next(tx *gorm.DB, queue string, now time.Time) *gorm.DB {
+	return q.available(tx, queue, now).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Order("priority DESC, id")
+}
+
+// databaseReservation reserved row, identified by its id and reservation count
+type databaseReservation struct {
+	queue        *DatabaseQueue
+	id           uint64
+	reservations int
+}
+
+// query starts a query on the row while it is still reserved by this delivery
+func (r *databaseReservation) query(ctx context.Context) *gorm.DB {
+	return r.queue.db.WithContext(ctx).Table(r.queue.table).
+		Where("id = ? AND reservations = ?", r.id, r.reservations)
+}
+
+// Ack deletes the row
+func (r *databaseReservation) Ack(ctx context.Context) error {
+	return r.query(ctx).Delete(&jobRecord{}).Error
+}
+
+// Nack releases the row when requeue is set, deletes it otherwise
+func (r *databaseReservation) Nack(ctx context.Context, requeue bool) error {
+	if !requeue {
+		return r.Ack(ctx)
+	}
+	return r.query(ctx).Update("reserved_until", nil).Error
+}
+
+// Extend moves the reservation deadline, ErrReservationLost when the row was popped again or deleted
+func (r *databaseReservation) Extend(ctx context.Context, visibility time.Duration) error {
+	tx := r.query(ctx).Update("reserved_until", time.Now().Add(visibility))
+	if tx.Error != nil || tx.RowsAffected > 0 {
+		return tx.Error
+	}
+
+	// MySQL reports changed rows only, an extension writing the same deadline changes nothing
+	var count int64
+	if err := r.query(ctx).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrReservationLost
+	}
+	return nil
+}
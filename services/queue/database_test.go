@@ -0,0 +1,219 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openQueueDB opens a temporary SQLite database, it ignores locking clauses
+func openQueueDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "queue.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// sqlRecorder gorm logger collecting the statements of a dry run
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRun opens a MySQL dialect connection recording statements instead of running them
+func dryRun(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:3306)/app", SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, recorder
+}
+
+// newTestDatabaseQueue creates a migrated database queue polling every few milliseconds
+func newTestDatabaseQueue(t *testing.T, visibility time.Duration) *DatabaseQueue {
+	t.Helper()
+	q := NewDatabaseQueueFromDB(openQueueDB(t), "", "", visibility)
+	q.poll = 5 * time.Millisecond
+	if err := q.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// popName pops a job, returning its delivery and the name of its greetJob, nil when none is available
+func popName(t *testing.T, q Queue) (*Delivery, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	delivery, err := q.Pop(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery == nil {
+		return nil, ""
+	}
+	inner, err := delivery.Job.(*Envelope).Job()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery, inner.(*greetJob).Name
+}
+
+func TestDatabaseQueuePriorityAndDelay(t *testing.T) {
+	ctx := context.Background()
+	q := newTestDatabaseQueue(t, time.Minute)
+
+	for _, job := range []struct {
+		name     string
+		priority int
+	}{{"low", 0}, {"high", 10}, {"low-later", 0}, {"high-later", 10}} {
+		envelope, err := NewEnvelope(&greetJob{Name: job.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		envelope.Priority = job.priority
+		if err = q.Push(ctx, "", envelope); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.PushDelayed(ctx, "", &greetJob{Name: "delayed"}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.Size(ctx, ""); n != 4 {
+		t.Fatalf("expected 4 available jobs, got %d", n)
+	}
+
+	for _, want := range []string{"high", "high-later", "low", "low-later"} {
+		delivery, got := popName(t, q)
+		if got != want {
+			t.Fatalf("expected %s, got %q", want, got)
+		}
+		if err := delivery.Ack(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if delivery, _ := popName(t, q); delivery != nil {
+		t.Fatal("expected the delayed job to wait")
+	}
+}
+
+func TestDatabaseQueueReservations(t *testing.T) {
+	ctx := context.Background()
+	q := newTestDatabaseQueue(t, 50*time.Millisecond)
+	_ = q.Push(ctx, "", &greetJob{Name: "job"})
+
+	first, _ := popName(t, q)
+	if first == nil {
+		t.Fatal("expected the pushed job")
+	}
+	if n, _ := q.Size(ctx, ""); n != 0 {
+		t.Fatalf("expected the reserved job to be invisible, got %d", n)
+	}
+
+	// An extended reservation outlives the visibility timeout
+	if err := first.Extend(ctx, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if delivery, _ := popName(t, q); delivery != nil {
+		t.Fatal("expected the extended reservation to hold")
+	}
+
+	// An expired reservation is popped again, the first consumer loses it
+	if err := first.Extend(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := popName(t, q)
+	if second == nil {
+		t.Fatal("expected the expired reservation to be popped again")
+	}
+	if err := first.Extend(ctx, time.Minute); !errors.Is(err, ErrReservationLost) {
+		t.Fatalf("expected ErrReservationLost, got %v", err)
+	}
+	if err := first.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Extend(ctx, time.Minute); err != nil {
+		t.Fatalf("expected the stale acknowledgement to leave the job, got %v", err)
+	}
+
+	// Nacked jobs are available again or dropped
+	if err := second.Nack(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := popName(t, q)
+	if third == nil {
+		t.Fatal("expected the nacked job to be available again")
+	}
+	if err := third.Nack(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	q.db.Table(q.table).Count(&n)
+	if n != 0 {
+		t.Fatalf("expected the dropped job to be deleted, %d rows left", n)
+	}
+}
+
+func TestDatabaseQueueExtendUnchangedRows(t *testing.T) {
+	ctx := context.Background()
+	q := newTestDatabaseQueue(t, time.Minute)
+
+	// Report no affected rows on updates, as MySQL does when the values are unchanged
+	err := q.db.Callback().Update().After("gorm:update").Register("test:changed_rows", func(db *gorm.DB) {
+		db.RowsAffected = 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = q.Push(ctx, "", &greetJob{Name: "job"})
+	delivery, _ := popName(t, q)
+	if delivery == nil {
+		t.Fatal("expected the pushed job")
+	}
+	if err = delivery.Extend(ctx, time.Minute); err != nil {
+		t.Fatalf("expected the reservation to be confirmed, got %v", err)
+	}
+
+	if err = delivery.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = delivery.Extend(ctx, time.Minute); !errors.Is(err, ErrReservationLost) {
+		t.Fatalf("expected ErrReservationLost, got %v", err)
+	}
+}
+
+func TestDatabaseQueueReserveStatement(t *testing.T) {
+	db, _ := dryRun(t)
+	q := NewDatabaseQueueFromDB(db, "", "", 0)
+
+	stmt := q.next(db, "", time.Now()).Take(&jobRecord{}).Statement
+	want := "SELECT * FROM `jobs` WHERE (queue = ? AND available_at <= ?) AND (reserved_until IS NULL OR reserved_until <= ?) " +
+		"ORDER BY priority DESC, id LIMIT ? FOR UPDATE SKIP LOCKED"
+	if got := stmt.SQL.String(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/logger"
+	"time"
+)
+
+// ErrDelayNotSupported is returned by queues that cannot hold jobs until a given time
+var ErrDelayNotSupported = errors.New("queue: delayed jobs not supported")
+
+// DelayedQueue queues that hold jobs until a given time
+type DelayedQueue interface {
+	PushDelayed(ctx context.Context, queue string, job Job, at time.Time) error
+}
+
+// Migrator queues keeping delayed or abandoned jobs apart from ready ones, such as
+// Redis sorted sets, that need due jobs moved over
+type Migrator interface {
+	// MigrateDue moves the jobs of a queue due by now onto its ready jobs, returning how many moved
+	MigrateDue(ctx context.Context, queue string, now time.Time) (int, error)
+}
+
+// Later dispatches a job that becomes available after delay (Facade pattern)
+func Later(ctx context.Context, delay time.Duration, job Job, opts ...DispatchOption) error {
+	return Dispatch(ctx, job, append(opts, Delay(delay))...)
+}
+
+// Delay makes the dispatched job available after delay
+func Delay(delay time.Duration) DispatchOption {
+	return At(time.Now().Add(delay))
+}
+
+// At makes the dispatched job available at a time
+func At(at time.Time) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.at = at
+	}
+}
+
+// pushAt pushes a job at a time, straight to the ready jobs when it is already due
+func pushAt(ctx context.Context, q Queue, queue string, job Job, at time.Time) error {
+	if at.IsZero() || !at.After(time.Now()) {
+		return q.Push(ctx, queue, job)
+	}
+
+	delayed, ok := q.(DelayedQueue)
+	if !ok {
+		return fmt.Errorf("queue %T: %w", q, ErrDelayNotSupported)
+	}
+	return delayed.PushDelayed(ctx, queue, job, at)
+}
+
+// RunMigrator moves due jobs of a queue every interval until ctx is done
+func RunMigrator(ctx context.Context, name, queue string, migrator Migrator, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := migrator.MigrateDue(ctx, queue, time.Now()); err != nil && ctx.Err() == nil {
+			logger.ReportError(fmt.Sprintf("queue %s failed to migrate due jobs", name), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
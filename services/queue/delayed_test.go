@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type delayedSliceQueue struct {
+	sliceQueue
+	mu      sync.Mutex
+	delayed []delayedJob
+}
+
+type delayedJob struct {
+	job Job
+	at  time.Time
+}
+
+func (q *delayedSliceQueue) PushDelayed(job Job, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delayed = append(q.delayed, delayedJob{job: job, at: at})
+	sort.Slice(q.delayed, func(i, j int) bool { return q.delayed[i].at.Before(q.delayed[j].at) })
+	return nil
+}
+
+func (q *delayedSliceQueue) MigrateDue(ctx context.Context, now time.Time) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for n < len(q.delayed) && !q.delayed[n].at.After(now) {
+		_ = q.Push(q.delayed[n].job)
+		n++
+	}
+	q.delayed = q.delayed[n:]
+	return n, nil
+}
+
+func TestDispatchLater(t *testing.T) {
+
+	q := &delayedSliceQueue{}
+	m := NewManager()
+	m.AddConnection("test", Adapt(q, 0))
+
+	if err := m.Dispatch(context.Background(), &greetJob{Name: "later"}, Delay(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Dispatch(context.Background(), &greetJob{Name: "now"}, At(time.Now().Add(-time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.Size(); n != 1 || len(q.delayed) != 1 {
+		t.Fatalf("expected one ready and one delayed job, got %d and %d", n, len(q.delayed))
+	}
+
+	if n, _ := q.MigrateDue(context.Background(), time.Now()); n != 0 {
+		t.Fatalf("expected no due job, got %d", n)
+	}
+	if n, _ := q.MigrateDue(context.Background(), time.Now().Add(2*time.Hour)); n != 1 {
+		t.Fatalf("expected the delayed job to be due, got %d", n)
+	}
+
+	m.AddConnection("plain", Adapt(&sliceQueue{}, 0))
+	if err := m.Dispatch(context.Background(), &greetJob{}, OnConnection("plain"), Delay(time.Hour)); err == nil {
+		t.Fatal("expected queues without delayed support to reject delayed jobs")
+	}
+}
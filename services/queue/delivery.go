@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrReservationLost is returned when extending a job that was already delivered again
+var ErrReservationLost = errors.New("queue: reservation lost")
+
+// deliveryKey context key of the delivery being handled
+type deliveryKey struct{}
+
+// Reservation driver side of a delivery
+type Reservation interface {
+	// Ack removes the job from the queue
+	Ack(ctx context.Context) error
+	// Nack releases the job, back onto the queue when requeue is set, dropped otherwise
+	Nack(ctx context.Context, requeue bool) error
+	// Extend keeps the job reserved for visibility from now on
+	Extend(ctx context.Context, visibility time.Duration) error
+}
+
+// Delivery job popped from a queue, reserved until it is acknowledged. A job
+// that is neither acknowledged nor extended before its visibility timeout is
+// delivered again, so a consumer crash does not lose it.
+type Delivery struct {
+	Job   Job
+	Queue string
+
+	reservation Reservation
+}
+
+// NewDelivery creates a delivery, for drivers
+func NewDelivery(job Job, queue string, reservation Reservation) *Delivery {
+	return &Delivery{Job: job, Queue: queue, reservation: reservation}
+}
+
+// Ack acknowledges the job, removing it from the queue
+func (d *Delivery) Ack(ctx context.Context) error {
+	return d.reservation.Ack(ctx)
+}
+
+// Nack rejects the job, putting it back onto the queue when requeue is set
+func (d *Delivery) Nack(ctx context.Context, requeue bool) error {
+	return d.reservation.Nack(ctx, requeue)
+}
+
+// Extend keeps the job reserved for visibility from now on, for jobs outliving the visibility timeout
+func (d *Delivery) Extend(ctx context.Context, visibility time.Duration) error {
+	return d.reservation.Extend(ctx, visibility)
+}
+
+// DeliveryFromContext returns the delivery of the job being handled
+func DeliveryFromContext(ctx context.Context) (*Delivery, bool) {
+	delivery, ok := ctx.Value(deliveryKey{}).(*Delivery)
+	return delivery, ok
+}
+
+// poll calls pop every interval until it returns a delivery, an error or ctx is done
+func poll(ctx context.Context, interval time.Duration, pop func() (*Delivery, error)) (*Delivery, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		delivery, err := pop()
+		if delivery != nil || err != nil {
+			return delivery, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-generator/sugar/services/logger"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Metadata keys set by Dispatch
+const (
+	MetadataRequestID = "request_id"
+)
+
+// envelopeKey context key of the envelope being handled
+type envelopeKey struct{}
+
+// Envelope serialized form of a job. It is itself a job, so queues store and
+// return envelopes and workers handle them like any other job.
+type Envelope struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"`
+	Payload  json.RawMessage   `json:"payload"`
+	Priority int               `json:"priority,omitempty"` // higher first, on drivers supporting priorities
+	Attempts int               `json:"attempts"`
+	QueuedAt time.Time         `json:"queued_at"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	Chain      []*Envelope `json:"chain,omitempty"`       // jobs dispatched one after another once this one succeeded
+	ChainCatch *Envelope   `json:"chain_catch,omitempty"` // job dispatched when a job of the chain fails for good
+	BatchID    string      `json:"batch_id,omitempty"`    // batch the job belongs to
+
+	job Job
+}
+
+// NewEnvelope wraps a registered job, encoding it as JSON
+func NewEnvelope(job Job) (*Envelope, error) {
+	name, err := JobName(job)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode queue job %s: %w", name, err)
+	}
+
+	return &Envelope{
+		ID:       uuid.NewString(),
+		Type:     name,
+		Payload:  payload,
+		QueuedAt: time.Now(),
+		Metadata: make(map[string]string),
+		job:      job,
+	}, nil
+}
+
+// DecodeEnvelope decodes an envelope encoded with Encode
+func DecodeEnvelope(data []byte) (*Envelope, error) {
+	envelope := new(Envelope)
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode queue envelope: %w", err)
+	}
+	return envelope, nil
+}
+
+// Encode encodes the envelope as JSON
+func (e *Envelope) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Job decodes the wrapped job from the registry
+func (e *Envelope) Job() (Job, error) {
+	if e.job != nil {
+		return e.job, nil
+	}
+
+	job, err := NewJob(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(e.Payload, job); err != nil {
+		return nil, fmt.Errorf("failed to decode queue job %s: %w", e.Type, err)
+	}
+
+	e.job = job
+	return job, nil
+}
+
+// JobName returns the name of the wrapped job
+func (e *Envelope) JobName() string {
+	return e.Type
+}
+
+// Timeout returns the timeout of the wrapped job, 0 when it has none
+func (e *Envelope) Timeout() time.Duration {
+	job, err := e.Job()
+	if err != nil {
+		return 0
+	}
+	if t, ok := job.(Timeouter); ok {
+		return t.Timeout()
+	}
+	return 0
+}
+
+// Handle decodes and handles the wrapped job. The envelope is available to the
+// job through EnvelopeFromContext and the request ID it was dispatched in is restored.
+func (e *Envelope) Handle(ctx context.Context) error {
+	job, err := e.Job()
+	if err != nil {
+		return err
+	}
+
+	ctx = context.WithValue(ctx, envelopeKey{}, e)
+	if id := e.Metadata[MetadataRequestID]; id != "" {
+		ctx = logger.WithRequestID(ctx, id)
+	}
+	return job.Handle(ctx)
+}
+
+// EnvelopeFromContext returns the envelope of the job being handled
+func EnvelopeFromContext(ctx context.Context) (*Envelope, bool) {
+	envelope, ok := ctx.Value(envelopeKey{}).(*Envelope)
+	return envelope, ok
+}
+
+// wrap returns the envelope of a job, wrapping registered jobs in a new one
+func wrap(job Job) (*Envelope, error) {
+	if envelope, ok := job.(*Envelope); ok {
+		return envelope, nil
+	}
+	return NewEnvelope(job)
+}
+
+// encodeJob encodes a job for drivers storing payloads
+func encodeJob(job Job) ([]byte, error) {
+	envelope, err := wrap(job)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Encode()
+}
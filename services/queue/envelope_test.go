@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"context"
+	"testing"
+)
+
+type greetJob struct {
+	Name string `json:"name"`
+	got  *string
+}
+
+func (j *greetJob) Handle(ctx context.Context) error {
+	envelope, _ := EnvelopeFromContext(ctx)
+	*j.got = envelope.Metadata["source"] + ":" + j.Name
+	return nil
+}
+
+func init() {
+	RegisterJob[greetJob]("test.greet")
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+
+	envelope, err := NewEnvelope(&greetJob{Name: "sugar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope.Metadata["source"] = "test"
+
+	data, err := envelope.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeEnvelope(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != envelope.ID || decoded.Type != "test.greet" {
+		t.Fatalf("unexpected envelope %+v", decoded)
+	}
+
+	job, err := decoded.Job()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	job.(*greetJob).got = &got
+	if err = decoded.Handle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got != "test:sugar" {
+		t.Fatalf("expected test:sugar, got %q", got)
+	}
+
+	if _, err = NewEnvelope(funcJob(nil)); err == nil {
+		t.Error("expected an unregistered job to be refused")
+	}
+}
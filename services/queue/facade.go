@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-generator/sugar/services/logger"
+	"time"
+)
+
+// Global queue manager instance
+var manager *Manager
+
+// SetManager sets the global queue manager
+func SetManager(m *Manager) {
+	manager = m
+}
+
+// dispatchOptions Dispatch options
+type dispatchOptions struct {
+	connection string
+	metadata   map[string]string
+	queue      string
+	priority   int
+	at         time.Time
+}
+
+// DispatchOption configures Dispatch
+type DispatchOption func(*dispatchOptions)
+
+// OnConnection dispatches on a named connection instead of the default one
+func OnConnection(name string) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.connection = name
+	}
+}
+
+// ToQueue dispatches on a named queue of the connection
+func ToQueue(name string) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.queue = name
+	}
+}
+
+// WithPriority dispatches the job ahead of lower priority ones, on drivers supporting priorities
+func WithPriority(priority int) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.priority = priority
+	}
+}
+
+// WithMetadata adds metadata to the envelope of the job
+func WithMetadata(key, value string) DispatchOption {
+	return func(o *dispatchOptions) {
+		o.metadata[key] = value
+	}
+}
+
+// Dispatch wraps a registered job in an envelope and pushes it (Facade pattern)
+func Dispatch(ctx context.Context, job Job, opts ...DispatchOption) error {
+	if manager == nil {
+		return fmt.Errorf("queue manager not initialized")
+	}
+	return manager.Dispatch(ctx, job, opts...)
+}
+
+// Dispatch wraps a registered job in an envelope and pushes it, jobs already
+// wrapped are pushed as they are. The request ID of ctx is kept as metadata.
+// Jobs dispatched with Delay or At need a connection implementing DelayedQueue.
+func (m *Manager) Dispatch(ctx context.Context, job Job, opts ...DispatchOption) error {
+	options := dispatchOptions{metadata: make(map[string]string)}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var (
+		queue Queue
+		err   error
+	)
+	if options.connection == "" {
+		queue, err = m.Queue()
+	} else {
+		queue, err = m.Connection(options.connection)
+	}
+	if err != nil {
+		return err
+	}
+
+	envelope, err := wrap(job)
+	if err != nil {
+		return err
+	}
+
+	if envelope.Metadata == nil {
+		envelope.Metadata = make(map[string]string)
+	}
+	if id, ok := logger.RequestIDFromContext(ctx); ok {
+		envelope.Metadata[MetadataRequestID] = id
+	}
+	if options.priority != 0 {
+		envelope.Priority = options.priority
+	}
+	for key, value := range options.metadata {
+		envelope.Metadata[key] = value
+	}
+
+	return pushAt(ctx, queue, options.queue, envelope, options.at)
+}
@@ -0,0 +1,285 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrFailedJobNotFound is returned when a failed job does not exist
+var ErrFailedJobNotFound = errors.New("queue: failed job not found")
+
+// FailedConfig failed job store configuration
+type FailedConfig struct {
+	Driver      string `validate:"required,oneof=database file"`
+	Connection  string `validate:"required_if=Driver database"` // database connection name
+	Table       string `validate:"omitempty"`                   // defaults to failed_jobs
+	Path        string `validate:"required_if=Driver file"`     // directory of the file store
+	AutoMigrate bool   `validate:"omitempty"`                   // create the table on boot
+}
+
+// FailedJob job that ran out of attempts
+type FailedJob struct {
+	ID         string    `gorm:"primaryKey;size:64" json:"id"` // envelope ID
+	Connection string    `gorm:"size:191;not null;index" json:"connection"`
+	Queue      string    `gorm:"size:191" json:"queue,omitempty"` // named queue, empty for the connection queue
+	Type       string    `gorm:"size:191;not null" json:"type"`
+	Payload    []byte    `gorm:"not null" json:"payload"` // encoded envelope
+	Error      string    `gorm:"type:text" json:"error"`
+	Stack      string    `gorm:"type:text" json:"stack,omitempty"`
+	FailedAt   time.Time `gorm:"not null;index" json:"failed_at"`
+}
+
+// Envelope decodes the envelope of the failed job
+func (f *FailedJob) Envelope() (*Envelope, error) {
+	return DecodeEnvelope(f.Payload)
+}
+
+// FailedStore stores failed jobs
+type FailedStore interface {
+	Record(ctx context.Context, job *FailedJob) error
+	// List returns failed jobs, most recent first
+	List(ctx context.Context, limit, offset int) ([]*FailedJob, error)
+	Find(ctx context.Context, id string) (*FailedJob, error)
+	Forget(ctx context.Context, id string) error
+	// Purge removes failed jobs that failed before a time, all of them when zero
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// NewFailedStore creates a failed job store, db is used by the database driver
+func NewFailedStore(db *gorm.DB, cfg FailedConfig) (FailedStore, error) {
+	switch cfg.Driver {
+	case "database":
+		return NewDatabaseFailedStore(db, cfg.Table, cfg.AutoMigrate)
+	case "file":
+		return NewFileFailedStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unsupported failed job driver %s", cfg.Driver)
+	}
+}
+
+// DatabaseFailedStore failed job store backed by a table
+type DatabaseFailedStore struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewDatabaseFailedStore creates a database failed job store
+func NewDatabaseFailedStore(db *gorm.DB, table string, autoMigrate bool) (*DatabaseFailedStore, error) {
+	if table == "" {
+		table = "failed_jobs"
+	}
+
+	s := &DatabaseFailedStore{db: db, table: table}
+	if autoMigrate {
+		if err := s.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Migrate creates or updates the failed job table
+func (s *DatabaseFailedStore) Migrate() error {
+	return s.db.Table(s.table).AutoMigrate(&FailedJob{})
+}
+
+// query starts a query on the failed job table
+func (s *DatabaseFailedStore) query(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table(s.table)
+}
+
+// Record stores a failed job, replacing a previous failure of the same envelope
+func (s *DatabaseFailedStore) Record(ctx context.Context, job *FailedJob) error {
+	return s.query(ctx).Save(job).Error
+}
+
+// List returns failed jobs, most recent first
+func (s *DatabaseFailedStore) List(ctx context.Context, limit, offset int) ([]*FailedJob, error) {
+	var jobs []*FailedJob
+	err := s.query(ctx).Order("failed_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, err
+}
+
+// Find returns a failed job
+func (s *DatabaseFailedStore) Find(ctx context.Context, id string) (*FailedJob, error) {
+	var job FailedJob
+	err := s.query(ctx).Where("id = ?", id).Take(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFailedJobNotFound
+	}
+	return &job, err
+}
+
+// Forget removes a failed job
+func (s *DatabaseFailedStore) Forget(ctx context.Context, id string) error {
+	tx := s.query(ctx).Where("id = ?", id).Delete(&FailedJob{})
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		return ErrFailedJobNotFound
+	}
+	return tx.Error
+}
+
+// Purge removes failed jobs that failed before a time, all of them when zero
+func (s *DatabaseFailedStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx := s.query(ctx)
+	if before.IsZero() {
+		tx = tx.Where("1 = 1")
+	} else {
+		tx = tx.Where("failed_at < ?", before)
+	}
+	tx = tx.Delete(&FailedJob{})
+	return tx.RowsAffected, tx.Error
+}
+
+// FileFailedStore failed job store keeping a JSON file per job in a directory
+type FileFailedStore struct {
+	root string
+}
+
+// NewFileFailedStore creates a file failed job store
+func NewFileFailedStore(root string) (*FileFailedStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &FileFailedStore{root: root}, nil
+}
+
+// path returns the file of a failed job, ids are envelope uuids and never contain separators
+func (s *FileFailedStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", ErrFailedJobNotFound
+	}
+	return filepath.Join(s.root, id+".json"), nil
+}
+
+// Record stores a failed job, replacing a previous failure of the same envelope
+func (s *FileFailedStore) Record(ctx context.Context, job *FailedJob) error {
+	path, err := s.path(job.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// all reads every failed job, most recent first
+func (s *FileFailedStore) all() ([]*FailedJob, error) {
+	paths, err := filepath.Glob(filepath.Join(s.root, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*FailedJob, 0, len(paths))
+	for _, path := range paths {
+		job, err := s.read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].FailedAt.After(jobs[j].FailedAt)
+	})
+	return jobs, nil
+}
+
+// read reads a failed job file
+func (s *FileFailedStore) read(path string) (*FailedJob, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	job := new(FailedJob)
+	if err = json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("failed to decode failed job %s: %w", filepath.Base(path), err)
+	}
+	return job, nil
+}
+
+// List returns failed jobs, most recent first
+func (s *FileFailedStore) List(ctx context.Context, limit, offset int) ([]*FailedJob, error) {
+	jobs, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= len(jobs) {
+		return nil, nil
+	}
+	jobs = jobs[offset:]
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// Find returns a failed job
+func (s *FileFailedStore) Find(ctx context.Context, id string) (*FailedJob, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrFailedJobNotFound
+	}
+	return job, err
+}
+
+// Forget removes a failed job
+func (s *FileFailedStore) Forget(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrFailedJobNotFound
+	}
+	return err
+}
+
+// Purge removes failed jobs that failed before a time, all of them when zero
+func (s *FileFailedStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	jobs, err := s.all()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, job := range jobs {
+		if !before.IsZero() && !job.FailedAt.Before(before) {
+			continue
+		}
+		if err = s.Forget(ctx, job.ID); err != nil && !errors.Is(err, ErrFailedJobNotFound) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
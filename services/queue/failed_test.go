@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testFailedStore checks the behavior shared by failed job stores
+func testFailedStore(t *testing.T, store FailedStore) {
+	ctx := context.Background()
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		job := &FailedJob{ID: id, Connection: "test", Type: "test.job", Payload: []byte("{}"), Error: "boom", FailedAt: now.Add(time.Duration(i) * time.Hour)}
+		if err := store.Record(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Recording the same envelope again replaces its failure
+	if err := store.Record(ctx, &FailedJob{ID: "a", Connection: "test", Type: "test.job", Payload: []byte("{}"), Error: "again", FailedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := store.Find(ctx, "a"); err != nil || job.Error != "again" {
+		t.Fatalf("expected the replaced failure, got %+v (%v)", job, err)
+	}
+
+	jobs, err := store.List(ctx, 2, 0)
+	if err != nil || len(jobs) != 2 || jobs[0].ID != "c" || jobs[1].ID != "b" {
+		t.Fatalf("expected the most recent failures first, got %v (%v)", jobs, err)
+	}
+	if jobs, _ = store.List(ctx, 2, 2); len(jobs) != 1 || jobs[0].ID != "a" {
+		t.Fatalf("expected the second page, got %v", jobs)
+	}
+
+	if err = store.Forget(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Find(ctx, "b"); !errors.Is(err, ErrFailedJobNotFound) {
+		t.Fatalf("expected ErrFailedJobNotFound, got %v", err)
+	}
+	if err = store.Forget(ctx, "b"); !errors.Is(err, ErrFailedJobNotFound) {
+		t.Fatalf("expected forgetting twice to fail, got %v", err)
+	}
+
+	if n, err := store.Purge(ctx, now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("expected the oldest failure to be purged, got %d (%v)", n, err)
+	}
+	if n, err := store.Purge(ctx, time.Time{}); err != nil || n != 1 {
+		t.Fatalf("expected the remaining failure to be purged, got %d (%v)", n, err)
+	}
+}
+
+func TestFailedStores(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		store, err := NewFileFailedStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		testFailedStore(t, store)
+	})
+	t.Run("database", func(t *testing.T) {
+		store, err := NewDatabaseFailedStore(openQueueDB(t), "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testFailedStore(t, store)
+	})
+}
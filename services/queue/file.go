@@ -0,0 +1,420 @@
+package queue
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQueueClosed is returned when a job popped from a file queue is settled after the queue was closed
+var ErrQueueClosed = errors.New("queue: queue is closed")
+
+// compactThreshold acknowledged records kept in a log before it may be compacted
+const compactThreshold = 1000
+
+// FileQueue persistent queue for development, keeping an append-only log per
+// named queue in a directory. Logs are replayed on open, so jobs pushed and not
+// acknowledged before a restart, including the ones being handled, are delivered
+// again. A directory must only be used by one process at a time.
+type FileQueue struct {
+	mu    sync.Mutex
+	dir   string
+	sync  bool
+	queue string
+	logs  map[string]*fileLog
+}
+
+// fileRecord line of a log
+type fileRecord struct {
+	Op          string          `json:"op"` // push or ack
+	ID          uint64          `json:"id"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	AvailableAt int64           `json:"available_at,omitempty"` // unix milliseconds
+}
+
+// fileLog log and index of the pending jobs of a named queue
+type fileLog struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	sync    bool
+	nextID  uint64
+	pending map[uint64]*fileRecord // pushed and not acknowledged
+	ready   []*fileRecord
+	delayed recordHeap
+	acked   int  // acknowledged records still in the log
+	closed  bool // the file was closed, reservations can no longer be settled
+}
+
+// NewFileQueue creates a file queue in dir, syncing every write to disk when sync is set.
+// queue is used when none is named.
+func NewFileQueue(dir, queue string, sync bool) (*FileQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	q := &FileQueue{dir: dir, sync: sync, queue: queueName(queue), logs: make(map[string]*fileLog)}
+	if _, err := q.log(""); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// log returns the log of a named queue, opening it on first use
+func (q *FileQueue) log(queue string) (*fileLog, error) {
+	if queue == "" {
+		queue = q.queue
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	log, ok := q.logs[queue]
+	if !ok {
+		if strings.ContainsAny(queue, `/\`) || queue[0] == '.' {
+			return nil, fmt.Errorf("invalid file queue name %q", queue)
+		}
+
+		var err error
+		if log, err = openFileLog(filepath.Join(q.dir, queue+".log"), q.sync); err != nil {
+			return nil, err
+		}
+		q.logs[queue] = log
+	}
+	return log, nil
+}
+
+// openFileLog replays a log, compacting it when its tail was torn by a crash
+func openFileLog(path string, sync bool) (*fileLog, error) {
+	l := &fileLog{path: path, sync: sync, nextID: 1, pending: make(map[uint64]*fileRecord)}
+
+	torn := false
+	file, err := os.Open(path)
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			record := new(fileRecord)
+			if err = json.Unmarshal(scanner.Bytes(), record); err != nil {
+				torn = true
+				break
+			}
+			switch record.Op {
+			case "push":
+				l.pending[record.ID] = record
+			case "ack":
+				delete(l.pending, record.ID)
+				l.acked++
+			}
+			l.nextID = max(l.nextID, record.ID+1)
+		}
+		err = scanner.Err()
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queue log %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	ids := make([]uint64, 0, len(l.pending))
+	for id := range l.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	now := time.Now().UnixMilli()
+	for _, id := range ids {
+		l.schedule(l.pending[id], now)
+	}
+
+	if torn {
+		if err = l.compact(); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	if l.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// schedule indexes a pending record as ready or delayed
+func (l *fileLog) schedule(record *fileRecord, now int64) {
+	if record.AvailableAt > now {
+		heap.Push(&l.delayed, record)
+		return
+	}
+	l.ready = append(l.ready, record)
+}
+
+// append writes a record to the log
+func (l *fileLog) append(record *fileRecord) error {
+	if l.closed {
+		return ErrQueueClosed
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err = l.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if l.sync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// compact rewrites the log with the pending jobs only
+func (l *fileLog) compact() error {
+	ids := make([]uint64, 0, len(l.pending))
+	for id := range l.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, id := range ids {
+		data, err := json.Marshal(l.pending[id])
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		_, _ = writer.Write(append(data, '\n'))
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if l.file != nil {
+		_ = l.file.Close()
+	}
+	if err = os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	if l.file, err = os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return err
+	}
+	l.acked = 0
+	return nil
+}
+
+// Push appends a job available now
+func (q *FileQueue) Push(ctx context.Context, queue string, job Job) error {
+	return q.push(queue, job, 0)
+}
+
+// PushDelayed appends a job available at a time
+func (q *FileQueue) PushDelayed(ctx context.Context, queue string, job Job, at time.Time) error {
+	return q.push(queue, job, at.UnixMilli())
+}
+
+// push appends a job available at a unix millisecond, 0 for now
+func (q *FileQueue) push(queue string, job Job, at int64) error {
+	payload, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+
+	l, err := q.log(queue)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record := &fileRecord{Op: "push", ID: l.nextID, Payload: payload, AvailableAt: at}
+	if err = l.append(record); err != nil {
+		return err
+	}
+	l.nextID++
+	l.pending[record.ID] = record
+	l.schedule(record, time.Now().UnixMilli())
+	return nil
+}
+
+// Pop takes the oldest ready job, polling until ctx is done. The job stays in
+// the log until it is acknowledged.
+func (q *FileQueue) Pop(ctx context.Context, queue string) (*Delivery, error) {
+	if queue == "" {
+		queue = q.queue
+	}
+	l, err := q.log(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return poll(ctx, 100*time.Millisecond, func() (*Delivery, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.closed {
+			return nil, ErrQueueClosed
+		}
+		if len(l.ready) == 0 {
+			return nil, nil
+		}
+		record := l.ready[0]
+		l.ready[0] = nil
+		l.ready = l.ready[1:]
+
+		envelope, err := DecodeEnvelope(record.Payload)
+		if err != nil {
+			// Drop the undecodable job so it is not delivered forever
+			_ = l.ack(record.ID)
+			return nil, err
+		}
+		return NewDelivery(envelope, queue, fileReservation{log: l, id: record.ID}), nil
+	})
+}
+
+// ack appends an ack record, compacting the log once acknowledged records dominate it
+func (l *fileLog) ack(id uint64) error {
+	if _, ok := l.pending[id]; !ok {
+		return nil
+	}
+	if err := l.append(&fileRecord{Op: "ack", ID: id}); err != nil {
+		return err
+	}
+	delete(l.pending, id)
+	l.acked++
+
+	if l.acked >= compactThreshold && l.acked > len(l.pending) {
+		return l.compact()
+	}
+	return nil
+}
+
+// Size returns the number of ready jobs
+func (q *FileQueue) Size(ctx context.Context, queue string) (int, error) {
+	l, err := q.log(queue)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.ready), nil
+}
+
+// MigrateDue moves due delayed jobs onto the ready jobs
+func (q *FileQueue) MigrateDue(ctx context.Context, queue string, now time.Time) (int, error) {
+	l, err := q.log(queue)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	moved := 0
+	for l.delayed.Len() > 0 && l.delayed[0].AvailableAt <= now.UnixMilli() {
+		l.ready = append(l.ready, heap.Pop(&l.delayed).(*fileRecord))
+		moved++
+	}
+	return moved, nil
+}
+
+// Close closes the logs of every named queue, jobs popped and not settled yet
+// stay in the logs and are delivered again once the queue is reopened
+func (q *FileQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var err error
+	for name, log := range q.logs {
+		log.mu.Lock()
+		log.closed = true
+		if closeErr := log.file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		log.mu.Unlock()
+		delete(q.logs, name)
+	}
+	return err
+}
+
+// fileReservation job popped from a log, pending until it is acknowledged
+type fileReservation struct {
+	log *fileLog
+	id  uint64
+}
+
+// Ack appends an ack record for the job
+func (r fileReservation) Ack(ctx context.Context) error {
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+
+	return r.log.ack(r.id)
+}
+
+// Nack puts the job back ahead of the ready jobs when requeue is set, acknowledges it otherwise
+func (r fileReservation) Nack(ctx context.Context, requeue bool) error {
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+
+	if !requeue {
+		return r.log.ack(r.id)
+	}
+	if r.log.closed {
+		return ErrQueueClosed
+	}
+	if record, ok := r.log.pending[r.id]; ok {
+		r.log.ready = append([]*fileRecord{record}, r.log.ready...)
+	}
+	return nil
+}
+
+// Extend does nothing, a popped job stays reserved until the queue is closed
+func (r fileReservation) Extend(ctx context.Context, visibility time.Duration) error {
+	r.log.mu.Lock()
+	defer r.log.mu.Unlock()
+
+	if r.log.closed {
+		return ErrQueueClosed
+	}
+	return nil
+}
+
+// recordHeap delayed records ordered by due time
+type recordHeap []*fileRecord
+
+func (h recordHeap) Len() int           { return len(h) }
+func (h recordHeap) Less(i, j int) bool { return h[i].AvailableAt < h[j].AvailableAt }
+func (h recordHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// Push implements heap.Interface
+func (h *recordHeap) Push(x any) {
+	*h = append(*h, x.(*fileRecord))
+}
+
+// Pop implements heap.Interface
+func (h *recordHeap) Pop() any {
+	old := *h
+	record := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return record
+}
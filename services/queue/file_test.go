@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileQueueSurvivesRestarts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	q, err := NewFileQueue(dir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"handled", "in-flight", "pending"} {
+		if err = q.Push(ctx, "", &greetJob{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = q.PushDelayed(ctx, "", &greetJob{Name: "delayed"}, time.Now().Add(time.Minute))
+
+	handled, _ := q.Pop(ctx, "")
+	if err = handled.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = q.Pop(ctx, "") // in flight when the process dies
+	_ = q.Close()
+
+	q, err = NewFileQueue(dir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if n, _ := q.Size(ctx, ""); n != 2 {
+		t.Fatalf("expected the in-flight and pending jobs to be ready again, got %d", n)
+	}
+	for _, want := range []string{"in-flight", "pending"} {
+		delivery, _ := q.Pop(ctx, "")
+		inner, _ := delivery.Job.(*Envelope).Job()
+		if got := inner.(*greetJob).Name; got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	}
+	if n, _ := q.MigrateDue(ctx, "", time.Now().Add(time.Minute)); n != 1 {
+		t.Fatalf("expected the delayed job to survive the restart, got %d", n)
+	}
+}
+
+func TestFileQueueReservationsAfterClose(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	q, err := NewFileQueue(dir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"acked", "nacked"} {
+		_ = q.Push(ctx, "", &greetJob{Name: name})
+	}
+	acked, _ := q.Pop(ctx, "")
+	nacked, _ := q.Pop(ctx, "")
+	_ = q.Close()
+
+	if err = acked.Ack(ctx); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("expected ErrQueueClosed on ack, got %v", err)
+	}
+	if err = nacked.Nack(ctx, true); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("expected ErrQueueClosed on nack, got %v", err)
+	}
+	if err = acked.Extend(ctx, time.Minute); !errors.Is(err, ErrQueueClosed) {
+		t.Fatalf("expected ErrQueueClosed on extend, got %v", err)
+	}
+
+	// Both jobs are delivered again once the queue is reopened
+	q, err = NewFileQueue(dir, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if n, _ := q.Size(ctx, ""); n != 2 {
+		t.Fatalf("expected both jobs to be ready again, got %d", n)
+	}
+}
+
+func TestWorkerRetriesOnFileQueue(t *testing.T) {
+	flakyRuns.Store(0)
+
+	q, err := NewFileQueue(t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	envelope, _ := NewEnvelope(&flakyJob{Fail: 2})
+	_ = q.Push(context.Background(), "", envelope)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	NewWorker("file", q, WorkerConfig{MigrateInterval: 5}).Run(ctx)
+
+	if n := flakyRuns.Load(); n != 3 {
+		t.Fatalf("expected 3 runs, got %d", n)
+	}
+	log, _ := q.log("")
+	if n := len(log.pending); n != 0 {
+		t.Fatalf("expected every attempt to be acknowledged, got %d pending", n)
+	}
+}
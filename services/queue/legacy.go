@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LegacyQueue queue interface before deliveries, Pop returns a nil job when the queue is empty
+type LegacyQueue interface {
+	Push(job Job) error
+	Pop() (Job, error)
+	Size() (int, error)
+}
+
+// BlockingQueue legacy queues that can wait for a job instead of being polled
+type BlockingQueue interface {
+	// BlockingPop waits up to timeout for a job, returning a nil job when none arrived
+	BlockingPop(ctx context.Context, timeout time.Duration) (Job, error)
+}
+
+// LegacyDelayedQueue legacy queues that hold jobs until a given time
+type LegacyDelayedQueue interface {
+	PushDelayed(job Job, at time.Time) error
+}
+
+// LegacyMigrator legacy queues that need due delayed jobs moved onto the ready queue
+type LegacyMigrator interface {
+	MigrateDue(ctx context.Context, now time.Time) (int, error)
+}
+
+// Adapted queue adapting a LegacyQueue. A legacy queue has a single queue, so
+// named queues are rejected, and no reservation, so a job is acknowledged as soon
+// as it is popped and a requeued job is pushed again.
+type Adapted struct {
+	legacy LegacyQueue
+	poll   time.Duration
+}
+
+// Adapt adapts a legacy queue, polled every interval when it is not a BlockingQueue, 1s when 0
+func Adapt(legacy LegacyQueue, interval time.Duration) *Adapted {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Adapted{legacy: legacy, poll: interval}
+}
+
+// Unwrap returns the legacy queue
+func (a *Adapted) Unwrap() LegacyQueue {
+	return a.legacy
+}
+
+// check rejects named queues
+func (a *Adapted) check(queue string) error {
+	if queue != "" && queue != DefaultQueue {
+		return fmt.Errorf("queue %T does not support named queues", a.legacy)
+	}
+	return nil
+}
+
+// Push pushes a job
+func (a *Adapted) Push(ctx context.Context, queue string, job Job) error {
+	if err := a.check(queue); err != nil {
+		return err
+	}
+	return a.legacy.Push(job)
+}
+
+// PushDelayed pushes a job held until at, ErrDelayNotSupported when the legacy queue cannot
+func (a *Adapted) PushDelayed(ctx context.Context, queue string, job Job, at time.Time) error {
+	if err := a.check(queue); err != nil {
+		return err
+	}
+	delayed, ok := a.legacy.(LegacyDelayedQueue)
+	if !ok {
+		return ErrDelayNotSupported
+	}
+	return delayed.PushDelayed(job, at)
+}
+
+// MigrateDue moves due delayed jobs of legacy queues needing it
+func (a *Adapted) MigrateDue(ctx context.Context, queue string, now time.Time) (int, error) {
+	migrator, ok := a.legacy.(LegacyMigrator)
+	if !ok {
+		return 0, nil
+	}
+	return migrator.MigrateDue(ctx, now)
+}
+
+// Pop waits for a job until ctx is done
+func (a *Adapted) Pop(ctx context.Context, queue string) (*Delivery, error) {
+	if err := a.check(queue); err != nil {
+		return nil, err
+	}
+
+	if blocking, ok := a.legacy.(BlockingQueue); ok {
+		timeout := time.Hour
+		if deadline, ok := ctx.Deadline(); ok {
+			if timeout = time.Until(deadline); timeout <= 0 {
+				return nil, nil
+			}
+		}
+		job, err := blocking.BlockingPop(ctx, timeout)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil
+		}
+		return a.deliver(job, err)
+	}
+
+	return poll(ctx, a.poll, func() (*Delivery, error) {
+		return a.deliver(a.legacy.Pop())
+	})
+}
+
+// deliver wraps a popped job in a delivery
+func (a *Adapted) deliver(job Job, err error) (*Delivery, error) {
+	if job == nil || err != nil {
+		return nil, err
+	}
+	return NewDelivery(job, DefaultQueue, legacyReservation{legacy: a.legacy, job: job}), nil
+}
+
+// Size returns the number of jobs of the legacy queue
+func (a *Adapted) Size(ctx context.Context, queue string) (int, error) {
+	if err := a.check(queue); err != nil {
+		return 0, err
+	}
+	return a.legacy.Size()
+}
+
+// legacyReservation reservation of a job popped from a legacy queue, already removed from it
+type legacyReservation struct {
+	legacy LegacyQueue
+	job    Job
+}
+
+// Ack does nothing, the job left the queue when it was popped
+func (r legacyReservation) Ack(ctx context.Context) error {
+	return nil
+}
+
+// Nack pushes the job again when requeue is set
+func (r legacyReservation) Nack(ctx context.Context, requeue bool) error {
+	if !requeue {
+		return nil
+	}
+	return r.legacy.Push(r.job)
+}
+
+// Extend does nothing, legacy queues have no visibility timeout
+func (r legacyReservation) Extend(ctx context.Context, visibility time.Duration) error {
+	return nil
+}
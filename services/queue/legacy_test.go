@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingSliceQueue legacy queue recording the timeouts it blocks for
+type blockingSliceQueue struct {
+	sliceQueue
+	timeouts []time.Duration
+}
+
+func (q *blockingSliceQueue) BlockingPop(ctx context.Context, timeout time.Duration) (Job, error) {
+	q.timeouts = append(q.timeouts, timeout)
+	return q.Pop()
+}
+
+func TestAdaptedBlockingPop(t *testing.T) {
+	q := &blockingSliceQueue{}
+	adapted := Adapt(q, 0)
+	_ = q.Push(funcJob(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if delivery, err := adapted.Pop(ctx, ""); err != nil || delivery == nil {
+		t.Fatalf("expected the pushed job, got %v", err)
+	}
+	if len(q.timeouts) != 1 || q.timeouts[0] <= 0 || q.timeouts[0] > time.Minute {
+		t.Fatalf("expected the time left before the deadline, got %v", q.timeouts)
+	}
+
+	// A passed deadline returns without blocking
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if delivery, err := adapted.Pop(expired, ""); err != nil || delivery != nil {
+		t.Fatalf("expected no delivery, got %v %v", delivery, err)
+	}
+	if len(q.timeouts) != 1 {
+		t.Fatalf("expected no blocking pop past the deadline, got %v", q.timeouts)
+	}
+}
@@ -11,11 +11,15 @@ type Job interface {
 	Handle(ctx context.Context) error
 }
 
-// Queue queue interface
+// Queue queue interface. The queue argument names a queue of the connection,
+// the connection queue when empty.
 type Queue interface {
-	Push(job Job) error
-	Pop() (Job, error)
-	Size() (int, error)
+	Push(ctx context.Context, queue string, job Job) error
+	// Pop waits for a job until ctx is done, returning a nil delivery when none arrived.
+	// The job stays reserved until the delivery is acknowledged.
+	Pop(ctx context.Context, queue string) (*Delivery, error)
+	// Size returns the number of jobs ready to be popped
+	Size(ctx context.Context, queue string) (int, error)
 }
 
 // Manager queue manager
@@ -23,6 +27,8 @@ type Manager struct {
 	connections       map[string]Queue
 	mu                sync.RWMutex
 	defaultConnection string
+	failed            FailedStore
+	batches           BatchRepository
 }
 
 // NewManager creates a new queue manager
@@ -72,6 +78,14 @@ func (m *Manager) Queue() (Queue, error) {
 	return queue, nil
 }
 
+// DefaultName returns the name of the default connection
+func (m *Manager) DefaultName() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.defaultConnection
+}
+
 // SetDefault sets the default connection
 func (m *Manager) SetDefault(name string) error {
 	m.mu.Lock()
@@ -84,3 +98,30 @@ func (m *Manager) SetDefault(name string) error {
 	m.defaultConnection = name
 	return nil
 }
+
+// SetFailedStore sets the store recording jobs that ran out of attempts
+func (m *Manager) SetFailedStore(store FailedStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failed = store
+}
+
+// FailedStore returns the failed job store, nil when failures are only logged
+func (m *Manager) FailedStore() FailedStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.failed
+}
+
+// DefaultQueue name of the queue used when none is given
+const DefaultQueue = "default"
+
+// queueName returns the name of a queue, DefaultQueue when empty
+func queueName(name string) string {
+	if name == "" {
+		return DefaultQueue
+	}
+	return name
+}
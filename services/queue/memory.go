@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when pushing onto a bounded queue at capacity
+var ErrQueueFull = errors.New("queue: queue is full")
+
+// MemoryQueue bounded in-process queue for tests and development. Jobs are lost on
+// exit, so popped jobs are not reserved and a requeued job is pushed again.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	capacity int
+	queue    string
+	states   map[string]*memoryState
+}
+
+// memoryState jobs of a named queue
+type memoryState struct {
+	ready   chan Job
+	mu      sync.Mutex
+	delayed delayedHeap
+}
+
+// NewMemoryQueue creates a memory queue holding up to capacity ready jobs per named queue, 1024 when 0.
+// queue is used when none is named.
+func NewMemoryQueue(capacity int, queue string) *MemoryQueue {
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	return &MemoryQueue{capacity: capacity, queue: queueName(queue), states: make(map[string]*memoryState)}
+}
+
+// state returns the jobs of a named queue, creating them on first use
+func (q *MemoryQueue) state(queue string) *memoryState {
+	if queue == "" {
+		queue = q.queue
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	state, ok := q.states[queue]
+	if !ok {
+		state = &memoryState{ready: make(chan Job, q.capacity)}
+		q.states[queue] = state
+	}
+	return state
+}
+
+// Push pushes a job, ErrQueueFull when the queue is at capacity
+func (q *MemoryQueue) Push(ctx context.Context, queue string, job Job) error {
+	return q.state(queue).push(job)
+}
+
+// push pushes a job without blocking
+func (s *memoryState) push(job Job) error {
+	select {
+	case s.ready <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// PushDelayed holds a job until at
+func (q *MemoryQueue) PushDelayed(ctx context.Context, queue string, job Job, at time.Time) error {
+	state := q.state(queue)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	heap.Push(&state.delayed, delayedEntry{job: job, at: at})
+	return nil
+}
+
+// Pop waits for a job until ctx is done
+func (q *MemoryQueue) Pop(ctx context.Context, queue string) (*Delivery, error) {
+	if queue == "" {
+		queue = q.queue
+	}
+	state := q.state(queue)
+
+	select {
+	case job := <-state.ready:
+		return NewDelivery(job, queue, memoryReservation{state: state, job: job}), nil
+	case <-ctx.Done():
+		return nil, nil
+	}
+}
+
+// Size returns the number of ready jobs
+func (q *MemoryQueue) Size(ctx context.Context, queue string) (int, error) {
+	return len(q.state(queue).ready), nil
+}
+
+// MigrateDue moves due delayed jobs onto the ready jobs, leaving them delayed while the queue is full
+func (q *MemoryQueue) MigrateDue(ctx context.Context, queue string, now time.Time) (int, error) {
+	state := q.state(queue)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	moved := 0
+	for state.delayed.Len() > 0 && !state.delayed[0].at.After(now) {
+		if err := state.push(state.delayed[0].job); err != nil {
+			return moved, nil
+		}
+		heap.Pop(&state.delayed)
+		moved++
+	}
+	return moved, nil
+}
+
+// memoryReservation job popped from a memory queue, already removed from it
+type memoryReservation struct {
+	state *memoryState
+	job   Job
+}
+
+// Ack does nothing, the job left the queue when it was popped
+func (r memoryReservation) Ack(ctx context.Context) error {
+	return nil
+}
+
+// Nack pushes the job again when requeue is set
+func (r memoryReservation) Nack(ctx context.Context, requeue bool) error {
+	if !requeue {
+		return nil
+	}
+	return r.state.push(r.job)
+}
+
+// Extend does nothing, memory queues have no visibility timeout
+func (r memoryReservation) Extend(ctx context.Context, visibility time.Duration) error {
+	return nil
+}
+
+// delayedEntry job held until at
+type delayedEntry struct {
+	job Job
+	at  time.Time
+}
+
+// delayedHeap delayed jobs ordered by due time
+type delayedHeap []delayedEntry
+
+func (h delayedHeap) Len() int           { return len(h) }
+func (h delayedHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h delayedHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// Push implements heap.Interface
+func (h *delayedHeap) Push(x any) {
+	*h = append(*h, x.(delayedEntry))
+}
+
+// Pop implements heap.Interface
+func (h *delayedHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(1, "")
+
+	if err := q.Push(ctx, "", funcJob(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(ctx, "", funcJob(nil)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if n, _ := q.Size(ctx, "other"); n != 0 {
+		t.Fatalf("expected named queues to be separate, got %d", n)
+	}
+
+	popCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	delivery, _ := q.Pop(popCtx, "")
+	if delivery == nil {
+		t.Fatal("expected the pushed job")
+	}
+	if err := delivery.Nack(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.Size(ctx, ""); n != 1 {
+		t.Fatalf("expected the requeued job, got %d", n)
+	}
+	_, _ = q.Pop(popCtx, "")
+	if delivery, _ = q.Pop(popCtx, ""); delivery != nil {
+		t.Fatal("expected Pop to give up once ctx is done")
+	}
+
+	_ = q.PushDelayed(ctx, "", funcJob(nil), time.Now().Add(time.Minute))
+	if n, _ := q.MigrateDue(ctx, "", time.Now()); n != 0 {
+		t.Fatalf("expected no due job, got %d", n)
+	}
+	if n, _ := q.MigrateDue(ctx, "", time.Now().Add(time.Minute)); n != 1 {
+		t.Fatalf("expected the delayed job to be due, got %d", n)
+	}
+}
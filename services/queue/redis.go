@@ -0,0 +1,272 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/cache"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// popScript moves the oldest ready job into the reserved set until its visibility deadline
+var popScript = redis.NewScript(`
+local job = redis.call('RPOP', KEYS[1])
+if job then
+	redis.call('ZADD', KEYS[2], ARGV[1], job)
+end
+return job
+`)
+
+// migrateScript moves the jobs of a sorted set scored up to now onto the ready list
+var migrateScript = redis.NewScript(`
+local jobs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, job in ipairs(jobs) do
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('LPUSH', KEYS[2], job)
+end
+return #jobs
+`)
+
+// requeueScript moves a reserved job back onto the ready list, ahead of the others
+var requeueScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	redis.call('RPUSH', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// recoverScript moves a reserved job onto the ready list as ARGV[2], unless it was settled meanwhile
+var recoverScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	redis.call('LPUSH', KEYS[2], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// extendScript moves the visibility deadline of a job still reserved. ZADD XX CH
+// alone reports 0 when the deadline is unchanged, which is not a lost reservation.
+var extendScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[1], ARGV[2]) then
+	redis.call('ZADD', KEYS[1], 'XX', ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// migrateBatch jobs moved per script call, bounding the time Redis is blocked
+const migrateBatch = 1000
+
+// RedisQueue reliable queue on Redis. Popped jobs stay in a reserved sorted set
+// scored by their visibility deadline until they are acknowledged, so the jobs
+// of a consumer that died mid-handle are requeued once the deadline passes.
+type RedisQueue struct {
+	client     redis.UniversalClient
+	prefix     string
+	queue      string
+	visibility time.Duration
+	poll       time.Duration
+}
+
+// NewRedisQueue creates a Redis queue from a connection configuration
+func NewRedisQueue(cfg ConnectionConfig) (*RedisQueue, error) {
+	if cfg.Redis == nil {
+		return nil, fmt.Errorf("redis queue connection is not configured")
+	}
+
+	client, err := cache.NewRedisClient(*cfg.Redis)
+	if err != nil {
+		return nil, err
+	}
+
+	q := NewRedisQueueFromClient(client, cfg.Redis.Prefix, cfg.Queue, time.Duration(cfg.Visibility)*time.Second)
+	if cfg.Poll > 0 {
+		q.poll = time.Duration(cfg.Poll) * time.Millisecond
+	}
+	return q, nil
+}
+
+// NewRedisQueueFromClient creates a Redis queue on an existing client, queue is used when none is named
+func NewRedisQueueFromClient(client redis.UniversalClient, prefix, queue string, visibility time.Duration) *RedisQueue {
+	if visibility <= 0 {
+		visibility = 90 * time.Second
+	}
+	return &RedisQueue{
+		client:     client,
+		prefix:     prefix,
+		queue:      queueName(queue),
+		visibility: visibility,
+		poll:       100 * time.Millisecond,
+	}
+}
+
+// key returns a key of a queue, hash tagged so all keys of a queue share a cluster slot
+func (q *RedisQueue) key(queue, suffix string) string {
+	if queue == "" {
+		queue = q.queue
+	}
+	return q.prefix + "queue:{" + queue + "}:" + suffix
+}
+
+// name returns the name of a queue, the connection queue when empty
+func (q *RedisQueue) name(queue string) string {
+	if queue == "" {
+		return q.queue
+	}
+	return queue
+}
+
+// Push pushes a job onto the ready list
+func (q *RedisQueue) Push(ctx context.Context, queue string, job Job) error {
+	payload, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	return q.client.LPush(ctx, q.key(queue, "ready"), payload).Err()
+}
+
+// PushDelayed pushes a job into the delayed set until at
+func (q *RedisQueue) PushDelayed(ctx context.Context, queue string, job Job, at time.Time) error {
+	payload, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	return q.client.ZAdd(ctx, q.key(queue, "delayed"), redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: payload,
+	}).Err()
+}
+
+// Pop reserves the oldest ready job, polling until ctx is done
+func (q *RedisQueue) Pop(ctx context.Context, queue string) (*Delivery, error) {
+	return poll(ctx, q.poll, func() (*Delivery, error) {
+		return q.reserve(ctx, queue)
+	})
+}
+
+// reserve moves the oldest ready job into the reserved set
+func (q *RedisQueue) reserve(ctx context.Context, queue string) (*Delivery, error) {
+	reserved := q.key(queue, "reserved")
+	deadline := time.Now().Add(q.visibility).UnixMilli()
+
+	payload, err := popScript.Run(ctx, q.client, []string{q.key(queue, "ready"), reserved}, deadline).Text()
+	if errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := DecodeEnvelope([]byte(payload))
+	if err != nil {
+		// Drop the undecodable payload so it is not requeued forever
+		_ = q.client.ZRem(context.WithoutCancel(ctx), reserved, payload).Err()
+		return nil, err
+	}
+	return NewDelivery(envelope, q.name(queue), &redisReservation{queue: q, name: queue, payload: payload}), nil
+}
+
+// Size returns the number of ready jobs
+func (q *RedisQueue) Size(ctx context.Context, queue string) (int, error) {
+	n, err := q.client.LLen(ctx, q.key(queue, "ready")).Result()
+	return int(n), err
+}
+
+// MigrateDue moves due delayed jobs and reserved jobs past their visibility deadline onto the ready list
+func (q *RedisQueue) MigrateDue(ctx context.Context, queue string, now time.Time) (int, error) {
+	moved := 0
+	for {
+		n, err := migrateScript.Run(ctx, q.client,
+			[]string{q.key(queue, "delayed"), q.key(queue, "ready")}, strconv.FormatInt(now.UnixMilli(), 10), migrateBatch).Int()
+		if err != nil {
+			return moved, err
+		}
+		moved += n
+		if n < migrateBatch {
+			break
+		}
+	}
+
+	n, err := q.recoverExpired(ctx, queue, now)
+	return moved + n, err
+}
+
+// recoverExpired moves reserved jobs past their visibility deadline onto the
+// ready list. The consumer holding them died mid-handle, so the lost
+// reservation counts as an attempt and a job crashing every consumer runs out.
+func (q *RedisQueue) recoverExpired(ctx context.Context, queue string, now time.Time) (int, error) {
+	reserved, ready := q.key(queue, "reserved"), q.key(queue, "ready")
+
+	moved := 0
+	for {
+		payloads, err := q.client.ZRangeArgs(ctx, redis.ZRangeArgs{
+			Key:     reserved,
+			Start:   "-inf",
+			Stop:    strconv.FormatInt(now.UnixMilli(), 10),
+			ByScore: true,
+			Count:   migrateBatch,
+		}).Result()
+		if err != nil {
+			return moved, err
+		}
+
+		for _, payload := range payloads {
+			// Undecodable payloads are requeued as they are and dropped when popped
+			recovered := payload
+			if envelope, err := DecodeEnvelope([]byte(payload)); err == nil {
+				envelope.Attempts++
+				if data, err := envelope.Encode(); err == nil {
+					recovered = string(data)
+				}
+			}
+
+			n, err := recoverScript.Run(ctx, q.client, []string{reserved, ready}, payload, recovered).Int()
+			if err != nil {
+				return moved, err
+			}
+			moved += n
+		}
+
+		if len(payloads) < migrateBatch {
+			return moved, nil
+		}
+	}
+}
+
+// Close closes the Redis client
+func (q *RedisQueue) Close() error {
+	return q.client.Close()
+}
+
+// redisReservation job held in the reserved set, identified by its payload
+type redisReservation struct {
+	queue   *RedisQueue
+	name    string
+	payload string
+}
+
+// Ack removes the job from the reserved set
+func (r *redisReservation) Ack(ctx context.Context) error {
+	return r.queue.client.ZRem(ctx, r.queue.key(r.name, "reserved"), r.payload).Err()
+}
+
+// Nack moves the job back onto the ready list when requeue is set, removes it otherwise
+func (r *redisReservation) Nack(ctx context.Context, requeue bool) error {
+	if !requeue {
+		return r.Ack(ctx)
+	}
+	return requeueScript.Run(ctx, r.queue.client,
+		[]string{r.queue.key(r.name, "reserved"), r.queue.key(r.name, "ready")}, r.payload).Err()
+}
+
+// Extend moves the visibility deadline of the job, ErrReservationLost when it was already requeued
+func (r *redisReservation) Extend(ctx context.Context, visibility time.Duration) error {
+	n, err := extendScript.Run(ctx, r.queue.client, []string{r.queue.key(r.name, "reserved")},
+		time.Now().Add(visibility).UnixMilli(), r.payload).Int()
+	if err == nil && n == 0 {
+		return ErrReservationLost
+	}
+	return err
+}
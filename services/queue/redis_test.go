@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func newTestRedisQueue(t *testing.T) (*RedisQueue, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	q := NewRedisQueueFromClient(client, "app:", "", time.Minute)
+	t.Cleanup(func() { _ = q.Close() })
+	return q, mr
+}
+
+func TestRedisQueueReliableDelivery(t *testing.T) {
+	ctx := context.Background()
+	q, mr := newTestRedisQueue(t)
+
+	for _, name := range []string{"first", "second"} {
+		if err := q.Push(ctx, "", &greetJob{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !mr.Exists("app:queue:{default}:ready") {
+		t.Fatal("expected the ready list to be hash tagged by queue name")
+	}
+
+	delivery, err := q.Pop(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, _ := delivery.Job.(*Envelope).Job()
+	if inner.(*greetJob).Name != "first" {
+		t.Fatalf("expected jobs in push order, got %+v", inner)
+	}
+	if n, _ := q.Size(ctx, ""); n != 1 {
+		t.Fatalf("expected 1 ready job, got %d", n)
+	}
+
+	// Acknowledged jobs are gone for good
+	if err = delivery.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.MigrateDue(ctx, "", time.Now().Add(time.Hour)); n != 0 {
+		t.Fatalf("expected nothing to requeue, got %d", n)
+	}
+	if err = delivery.Extend(ctx, time.Minute); !errors.Is(err, ErrReservationLost) {
+		t.Fatalf("expected ErrReservationLost, got %v", err)
+	}
+
+	// Jobs of a dead consumer are requeued once their visibility expires, unless extended
+	abandoned, _ := q.Pop(ctx, "")
+	if err = abandoned.Extend(ctx, 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.MigrateDue(ctx, "", time.Now().Add(2*time.Minute)); n != 0 {
+		t.Fatalf("expected the extended reservation to hold, got %d requeued", n)
+	}
+	if n, _ := q.MigrateDue(ctx, "", time.Now().Add(6*time.Minute)); n != 1 {
+		t.Fatalf("expected the abandoned job to be requeued, got %d", n)
+	}
+	again, _ := q.Pop(ctx, "")
+	if again.Job.(*Envelope).ID != abandoned.Job.(*Envelope).ID {
+		t.Fatal("expected the abandoned job to be delivered again")
+	}
+	if attempts := again.Job.(*Envelope).Attempts; attempts != 1 {
+		t.Fatalf("expected the lost reservation to count as an attempt, got %d", attempts)
+	}
+
+	// Back to back extensions may write the same deadline
+	for i := 0; i < 20; i++ {
+		if err = again.Extend(ctx, time.Minute); err != nil {
+			t.Fatalf("extension %d: %v", i, err)
+		}
+	}
+
+	// Nacked jobs go back ahead of the others
+	_ = q.Push(ctx, "", &greetJob{Name: "third"})
+	if err = again.Nack(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	next, _ := q.Pop(ctx, "")
+	if next.Job.(*Envelope).ID != again.Job.(*Envelope).ID {
+		t.Fatal("expected the nacked job to be delivered first")
+	}
+}
+
+func TestRedisQueueDelayedAndNamed(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestRedisQueue(t)
+
+	if err := q.PushDelayed(ctx, "emails", &greetJob{Name: "later"}, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	popCtx, cancel := context.WithTimeout(ctx, 150*time.Millisecond)
+	defer cancel()
+	if delivery, _ := q.Pop(popCtx, "emails"); delivery != nil {
+		t.Fatal("expected the delayed job to wait")
+	}
+
+	if n, _ := q.MigrateDue(ctx, "emails", time.Now().Add(time.Minute)); n != 1 {
+		t.Fatalf("expected the delayed job to be due, got %d", n)
+	}
+	if n, _ := q.Size(ctx, ""); n != 0 {
+		t.Fatalf("expected the default queue to stay empty, got %d", n)
+	}
+	if delivery, _ := q.Pop(ctx, "emails"); delivery == nil || delivery.Queue != "emails" {
+		t.Fatal("expected the due job on its named queue")
+	}
+}
@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Named jobs choosing the name they are registered and serialized under
+type Named interface {
+	JobName() string
+}
+
+// registry job constructors by name
+var registry = struct {
+	mu           sync.RWMutex
+	constructors map[string]func() Job
+	names        map[reflect.Type]string
+}{
+	constructors: make(map[string]func() Job),
+	names:        make(map[reflect.Type]string),
+}
+
+// Register registers a job constructor under name. The constructor returns a
+// new job, usually a pointer, that payloads are decoded into.
+func Register(name string, constructor func() Job) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.constructors[name]; ok {
+		panic(fmt.Sprintf("queue job %s is already registered", name))
+	}
+	registry.constructors[name] = constructor
+	registry.names[reflect.TypeOf(constructor())] = name
+}
+
+// RegisterJob registers the job type *T under name
+func RegisterJob[T any, P interface {
+	*T
+	Job
+}](name string) {
+	Register(name, func() Job {
+		return P(new(T))
+	})
+}
+
+// JobName returns the registered name of a job
+func JobName(job Job) (string, error) {
+	if named, ok := job.(Named); ok {
+		return named.JobName(), nil
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	name, ok := registry.names[reflect.TypeOf(job)]
+	if !ok {
+		return "", fmt.Errorf("queue job type %T is not registered", job)
+	}
+	return name, nil
+}
+
+// NewJob creates an empty job of a registered name
+func NewJob(name string) (Job, error) {
+	registry.mu.RLock()
+	constructor, ok := registry.constructors[name]
+	registry.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("queue job %s is not registered", name)
+	}
+	return constructor(), nil
+}
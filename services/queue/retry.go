@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff strategies
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+	BackoffJitter      = "jitter"
+)
+
+// Backoff computes the delay before a retry
+type Backoff interface {
+	// Delay returns the delay after the given failed attempt, starting at 1
+	Delay(attempt int) time.Duration
+}
+
+// BackoffFunc adapts a function to Backoff
+type BackoffFunc func(attempt int) time.Duration
+
+// Delay returns the delay after a failed attempt
+func (f BackoffFunc) Delay(attempt int) time.Duration {
+	return f(attempt)
+}
+
+// FixedBackoff waits the same delay before every retry
+type FixedBackoff struct {
+	Base time.Duration
+}
+
+// Delay returns the delay after a failed attempt
+func (b FixedBackoff) Delay(attempt int) time.Duration {
+	return b.Base
+}
+
+// ExponentialBackoff doubles the delay after every attempt up to Max
+type ExponentialBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the delay after a failed attempt
+func (b ExponentialBackoff) Delay(attempt int) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempt && delay > 0 && (b.Max <= 0 || delay < b.Max); i++ {
+		// Without a maximum the doubling stops at the largest duration
+		if delay > math.MaxInt64/2 {
+			return math.MaxInt64
+		}
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// JitterBackoff picks a random delay up to the exponential one, spreading retries of jobs failing together
+type JitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the delay after a failed attempt
+func (b JitterBackoff) Delay(attempt int) time.Duration {
+	ceiling := ExponentialBackoff(b).Delay(attempt)
+	if ceiling <= 0 {
+		return 0
+	}
+	if ceiling == math.MaxInt64 {
+		return rand.N(ceiling)
+	}
+	return rand.N(ceiling + 1)
+}
+
+// NewBackoff creates a backoff strategy by name, exponential when empty
+func NewBackoff(strategy string, base, max time.Duration) (Backoff, error) {
+	switch strategy {
+	case BackoffFixed:
+		return FixedBackoff{Base: base}, nil
+	case "", BackoffExponential:
+		return ExponentialBackoff{Base: base, Max: max}, nil
+	case BackoffJitter:
+		return JitterBackoff{Base: base, Max: max}, nil
+	default:
+		return nil, fmt.Errorf("unsupported queue backoff %s", strategy)
+	}
+}
+
+// Retryable jobs customizing their retry policy
+type Retryable interface {
+	// MaxAttempts returns how many times the job runs before it fails, 0 keeps the worker setting
+	MaxAttempts() int
+	// Backoff returns the delay after the given failed attempt, a negative delay keeps the worker setting
+	Backoff(attempt int) time.Duration
+}
+
+// permanentError error that is not retried
+type permanentError struct {
+	err error
+}
+
+// Error returns the error message
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error returned by a job as not worth retrying, the job fails at once
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent checks if an error was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// PanicError error of a job that panicked
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error returns the error message
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
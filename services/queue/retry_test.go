@@ -0,0 +1,252 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var flakyRuns atomic.Int32
+
+type flakyJob struct {
+	Fail int `json:"fail"`
+}
+
+func (j *flakyJob) Handle(ctx context.Context) error {
+	if int(flakyRuns.Add(1)) <= j.Fail {
+		return errors.New("flaky")
+	}
+	return nil
+}
+
+func (j *flakyJob) MaxAttempts() int {
+	return 3
+}
+
+func (j *flakyJob) Backoff(attempt int) time.Duration {
+	return time.Millisecond
+}
+
+func init() {
+	RegisterJob[flakyJob]("test.flaky")
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Base: time.Second, Max: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 5 * time.Second} {
+		if got := b.Delay(attempt); got != want {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+	if d := (JitterBackoff{Base: time.Second, Max: 5 * time.Second}).Delay(3); d < 0 || d > 4*time.Second {
+		t.Fatalf("unexpected jitter %s", d)
+	}
+
+	// Without a maximum the delay saturates instead of overflowing
+	if d := (ExponentialBackoff{Base: time.Second}).Delay(1000); d != math.MaxInt64 {
+		t.Fatalf("expected the largest duration, got %s", d)
+	}
+	if d := (JitterBackoff{Base: time.Second}).Delay(1000); d < 0 {
+		t.Fatalf("unexpected jitter %s", d)
+	}
+	if d := (ExponentialBackoff{}).Delay(math.MaxInt); d != 0 {
+		t.Fatalf("expected no delay, got %s", d)
+	}
+}
+
+func TestWorkerRetriesAndRecordsFailures(t *testing.T) {
+
+	store, err := NewFileFailedStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &sliceQueue{}
+	w := NewWorker("test", Adapt(q, 5*time.Millisecond), WorkerConfig{}).WithFailedStore(store)
+
+	run := func(job Job) {
+		t.Helper()
+		flakyRuns.Store(0)
+		envelope, err := NewEnvelope(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = q.Push(envelope)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		w.Run(ctx)
+	}
+
+	// Succeeds on the third and last attempt
+	run(&flakyJob{Fail: 2})
+	if n := flakyRuns.Load(); n != 3 {
+		t.Fatalf("expected 3 runs, got %d", n)
+	}
+	if jobs, _ := store.List(context.Background(), 0, 0); len(jobs) != 0 {
+		t.Fatalf("expected no failed jobs, got %d", len(jobs))
+	}
+
+	// Runs out of attempts and is recorded
+	run(&flakyJob{Fail: 5})
+	jobs, err := store.List(context.Background(), 0, 0)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected a failed job, got %v %v", jobs, err)
+	}
+	if jobs[0].Connection != "test" || jobs[0].Type != "test.flaky" || jobs[0].Error != "flaky" {
+		t.Fatalf("unexpected failed job %+v", jobs[0])
+	}
+
+	// Retrying pushes it back with its attempts reset
+	m := NewManager()
+	m.AddConnection("test", Adapt(q, 0))
+	m.SetFailedStore(store)
+	if err = m.RetryFailed(context.Background(), jobs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	job, _ := q.Pop()
+	if envelope, ok := job.(*Envelope); !ok || envelope.Attempts != 0 || envelope.ID != jobs[0].ID {
+		t.Fatalf("unexpected retried job %+v", job)
+	}
+	if _, err = store.Find(context.Background(), jobs[0].ID); !errors.Is(err, ErrFailedJobNotFound) {
+		t.Fatalf("expected the failed job to be forgotten, got %v", err)
+	}
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	q := &sliceQueue{}
+	w := NewWorker("test", Adapt(q, 0), WorkerConfig{Tries: 5})
+
+	envelope := &Envelope{Type: "test.permanent", job: funcJob(func(ctx context.Context) error {
+		return Permanent(errors.New("invalid"))
+	})}
+	w.handleError(NewDelivery(envelope, "", nil), envelope.Handle(context.Background()))
+	if n, _ := q.Size(); n != 0 || len(w.retries) != 0 {
+		t.Fatal("expected a permanent error not to be retried")
+	}
+}
+
+func TestWorkerFailsRecoveredJobsOutOfAttempts(t *testing.T) {
+	store, err := NewFileFailedStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorker("test", Adapt(&sliceQueue{}, 0), WorkerConfig{Tries: 2}).WithFailedStore(store)
+
+	ran := false
+	envelope := &Envelope{ID: "crashing", Type: "test.crashing", Attempts: 2, job: funcJob(func(ctx context.Context) error {
+		ran = true
+		return nil
+	})}
+	reservation := &recordingReservation{}
+	w.handle(context.Background(), NewDelivery(envelope, "", reservation))
+
+	if ran {
+		t.Error("expected a job without attempts left not to run")
+	}
+	if acked, _ := reservation.settled(); !acked {
+		t.Error("expected the delivery to be acknowledged")
+	}
+	if jobs, _ := store.List(context.Background(), 0, 0); len(jobs) != 1 {
+		t.Fatalf("expected the job to be recorded as failed, got %d", len(jobs))
+	}
+}
+
+// recordingReservation reservation recording how a delivery was settled
+type recordingReservation struct {
+	mu       sync.Mutex
+	acked    bool
+	extended time.Duration
+	extends  int
+}
+
+func (r *recordingReservation) Ack(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.acked = true
+	return nil
+}
+
+func (r *recordingReservation) Nack(ctx context.Context, requeue bool) error {
+	return nil
+}
+
+func (r *recordingReservation) Extend(ctx context.Context, visibility time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extended = visibility
+	r.extends++
+	return nil
+}
+
+func (r *recordingReservation) settled() (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.acked, r.extended
+}
+
+// failingDelivery delivers a job that always fails
+func failingDelivery() (*Delivery, *recordingReservation) {
+	reservation := &recordingReservation{}
+	envelope := &Envelope{Type: "test.failing", job: funcJob(func(ctx context.Context) error {
+		return errors.New("failing")
+	})}
+	return NewDelivery(envelope, "", reservation), reservation
+}
+
+func TestInProcessRetryHoldsTheReservation(t *testing.T) {
+	q := &sliceQueue{}
+	w := NewWorker("test", Adapt(q, 0), WorkerConfig{Tries: 3, BackoffDelay: 20})
+
+	delivery, reservation := failingDelivery()
+	w.handle(context.Background(), delivery)
+	if acked, extended := reservation.settled(); acked || extended != 20*time.Millisecond+retryGrace {
+		t.Fatalf("expected the reservation to be held through the delay, acked %v extended %s", acked, extended)
+	}
+	if n, _ := q.Size(); n != 0 {
+		t.Fatalf("expected the retry to wait, got %d jobs", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for acked, _ := reservation.settled(); !acked; acked, _ = reservation.settled() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the delivery to be acknowledged once the retry was pushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n, _ := q.Size(); n != 1 {
+		t.Fatalf("expected the retry to be pushed, got %d jobs", n)
+	}
+}
+
+func TestFlushRetriesHonorsTheBackoff(t *testing.T) {
+	q := &sliceQueue{}
+	w := NewWorker("test", Adapt(q, 0), WorkerConfig{Tries: 3, BackoffDelay: 50, ShutdownTimeout: 1})
+
+	delivery, reservation := failingDelivery()
+	w.handle(context.Background(), delivery)
+	start := time.Now()
+	w.flushRetries()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected the flush to wait for the backoff, took %s", elapsed)
+	}
+	if acked, _ := reservation.settled(); !acked {
+		t.Fatal("expected the delivery to be acknowledged")
+	}
+
+	// Retries due after the shutdown timeout are pushed early rather than lost
+	w = NewWorker("test", Adapt(q, 0), WorkerConfig{Tries: 3, BackoffDelay: 60000, ShutdownTimeout: 1})
+	delivery, _ = failingDelivery()
+	w.handle(context.Background(), delivery)
+	start = time.Now()
+	w.flushRetries()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the flush not to wait past the shutdown timeout, took %s", elapsed)
+	}
+	if n, _ := q.Size(); n != 2 {
+		t.Fatalf("expected both retries to be pushed, got %d jobs", n)
+	}
+}
@@ -0,0 +1,595 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-generator/sugar/services/cache"
+	"github.com/gin-generator/sugar/services/logger"
+	"gorm.io/gorm"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Config queue configuration with validation tags
+type Config struct {
+	Default     string                      `validate:"omitempty"`      // default connection, the first configured one when empty
+	Connections map[string]ConnectionConfig `validate:"omitempty,dive"` // connections created by the queue provider
+	Workers     []WorkerConfig              `validate:"omitempty,dive"` // workers run by the worker server
+	Failed      *FailedConfig               `validate:"omitempty"`      // failed job store, failures are only logged when nil
+	Batches     *BatchConfig                `validate:"omitempty"`      // batch repository, required by queue.Batch
+}
+
+// ConnectionConfig queue connection configuration with validation tags
+type ConnectionConfig struct {
+	Driver      string             `validate:"required,oneof=redis database memory file"`
+	Redis       *cache.RedisConfig `validate:"required_if=Driver redis,omitempty"`
+	Connection  string             `validate:"required_if=Driver database"` // database connection name
+	Table       string             `validate:"omitempty"`                   // jobs table of the database driver, defaults to jobs
+	AutoMigrate bool               `validate:"omitempty"`                   // create the jobs table on boot
+	Capacity    int                `validate:"omitempty,gt=0"`              // ready jobs held per named queue by the memory driver, defaults to 1024
+	Path        string             `validate:"required_if=Driver file"`     // log directory of the file driver
+	Sync        bool               `validate:"omitempty"`                   // sync every write of the file driver to disk
+	Queue       string             `validate:"omitempty"`                   // queue used when none is named, defaults to default
+	Visibility  int                `validate:"omitempty,gt=0"`              // seconds a popped job stays reserved before it is requeued, defaults to 90
+	Poll        int                `validate:"omitempty,gt=0"`              // milliseconds between polls while a pop waits, defaults to 100, 1000 for the database driver
+}
+
+// NewConnection creates a queue connection from its configuration, db is used by the database driver
+func NewConnection(db *gorm.DB, cfg ConnectionConfig) (Queue, error) {
+	switch cfg.Driver {
+	case "redis":
+		return NewRedisQueue(cfg)
+	case "database":
+		return NewDatabaseQueue(db, cfg)
+	case "memory":
+		return NewMemoryQueue(cfg.Capacity, cfg.Queue), nil
+	case "file":
+		return NewFileQueue(cfg.Path, cfg.Queue, cfg.Sync)
+	default:
+		return nil, fmt.Errorf("unsupported queue driver %s", cfg.Driver)
+	}
+}
+
+// WorkerConfig worker configuration with validation tags
+type WorkerConfig struct {
+	Connection      string `validate:"omitempty"`                                // queue connection, the default one when empty
+	Queue           string `validate:"omitempty"`                                // named queue of the connection, the connection queue when empty
+	Concurrency     int    `validate:"omitempty,gt=0"`                           // jobs processed in parallel, defaults to 1
+	Timeout         int    `validate:"omitempty,gte=0"`                          // seconds a job may run, defaults to 60, 0 keeps the default
+	Sleep           int    `validate:"omitempty,gt=0"`                           // milliseconds to wait after a failed pop, defaults to 1000
+	Block           int    `validate:"omitempty,gt=0"`                           // seconds a pop waits for a job, defaults to 5
+	ShutdownTimeout int    `validate:"omitempty,gt=0"`                           // seconds in-flight jobs, then retries waiting in process, may drain on shutdown, defaults to 30
+	Tries           int    `validate:"omitempty,gt=0"`                           // attempts before a job fails, defaults to 1
+	Backoff         string `validate:"omitempty,oneof=fixed exponential jitter"` // retry backoff, defaults to exponential
+	BackoffDelay    int    `validate:"omitempty,gt=0"`                           // milliseconds before the first retry, defaults to 1000
+	BackoffMax      int    `validate:"omitempty,gt=0"`                           // maximum milliseconds between retries, defaults to 60000
+	MigrateInterval int    `validate:"omitempty,gt=0"`                           // milliseconds between moves of due delayed jobs, defaults to 1000
+}
+
+// retryGrace time the reservation held by an in-process retry outlives its delay
+const retryGrace = 30 * time.Second
+
+// reservationLease time a running job is kept reserved for, extended every third of it
+const reservationLease = 30 * time.Second
+
+// Timeouter jobs overriding the worker timeout
+type Timeouter interface {
+	Timeout() time.Duration
+}
+
+// Worker processes the jobs of a queue connection with a pool of goroutines
+type Worker struct {
+	name            string
+	queue           Queue
+	concurrency     int
+	timeout         time.Duration
+	sleep           time.Duration
+	block           time.Duration
+	shutdownTimeout time.Duration
+	tries           int
+	backoff         Backoff
+	queueName       string
+	failed          FailedStore
+	batches         BatchRepository
+	migrateInterval time.Duration
+	lease           time.Duration
+
+	retryMu sync.Mutex
+	retries map[*Envelope]*pendingRetry
+}
+
+// pendingRetry retry waiting on its backoff in process, holding the reservation of its delivery
+type pendingRetry struct {
+	envelope *Envelope
+	delivery *Delivery
+	due      time.Time
+	timer    *time.Timer
+}
+
+// NewWorker creates a worker on a queue connection
+func NewWorker(name string, queue Queue, cfg WorkerConfig) *Worker {
+	base := time.Duration(cfg.BackoffDelay) * time.Millisecond
+	if base <= 0 {
+		base = time.Second
+	}
+	max := time.Duration(cfg.BackoffMax) * time.Millisecond
+	if max <= 0 {
+		max = time.Minute
+	}
+	backoff, err := NewBackoff(cfg.Backoff, base, max)
+	if err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s backoff", name), err)
+		backoff = ExponentialBackoff{Base: base, Max: max}
+	}
+
+	w := &Worker{
+		name:            name,
+		queue:           queue,
+		queueName:       cfg.Queue,
+		concurrency:     cfg.Concurrency,
+		timeout:         time.Duration(cfg.Timeout) * time.Second,
+		sleep:           time.Duration(cfg.Sleep) * time.Millisecond,
+		block:           time.Duration(cfg.Block) * time.Second,
+		shutdownTimeout: time.Duration(cfg.ShutdownTimeout) * time.Second,
+		tries:           cfg.Tries,
+		backoff:         backoff,
+		retries:         make(map[*Envelope]*pendingRetry),
+		migrateInterval: time.Duration(cfg.MigrateInterval) * time.Millisecond,
+		lease:           reservationLease,
+	}
+	if w.concurrency <= 0 {
+		w.concurrency = 1
+	}
+	if w.timeout <= 0 {
+		w.timeout = time.Minute
+	}
+	if w.sleep <= 0 {
+		w.sleep = time.Second
+	}
+	if w.block <= 0 {
+		w.block = 5 * time.Second
+	}
+	if w.shutdownTimeout <= 0 {
+		w.shutdownTimeout = 30 * time.Second
+	}
+	if w.tries <= 0 {
+		w.tries = 1
+	}
+	if w.migrateInterval <= 0 {
+		w.migrateInterval = time.Second
+	}
+	return w
+}
+
+// WithFailedStore records jobs that ran out of attempts in store
+func (w *Worker) WithFailedStore(store FailedStore) *Worker {
+	w.failed = store
+	return w
+}
+
+// WithBatchRepository tracks the jobs of batches in repository
+func (w *Worker) WithBatchRepository(repository BatchRepository) *Worker {
+	w.batches = repository
+	return w
+}
+
+// Run processes jobs until ctx is done, then waits for in-flight jobs to drain.
+// Jobs still running after the shutdown timeout have their context canceled.
+func (w *Worker) Run(ctx context.Context) {
+	// Jobs outlive ctx so a shutdown lets them finish
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	if migrator, ok := w.queue.(Migrator); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RunMigrator(ctx, w.name, w.queueName, migrator, w.migrateInterval)
+		}()
+	}
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, jobCtx)
+		}()
+	}
+
+	<-ctx.Done()
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(w.shutdownTimeout):
+		logger.ReportError(fmt.Sprintf("queue worker %s shutdown timeout, canceling in-flight jobs", w.name), context.DeadlineExceeded)
+		cancelJobs()
+		<-drained
+	}
+
+	w.flushRetries()
+}
+
+// loop pops and processes jobs until ctx is done
+func (w *Worker) loop(ctx, jobCtx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := w.pop(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.ReportError(fmt.Sprintf("queue worker %s pop failed", w.name), err)
+				w.wait(ctx)
+			}
+			continue
+		}
+		if delivery == nil {
+			continue
+		}
+
+		w.handle(jobCtx, delivery)
+	}
+}
+
+// handle processes a delivery, then acknowledges it. Failed jobs are pushed
+// again or recorded before, so the acknowledgement never loses them.
+func (w *Worker) handle(ctx context.Context, delivery *Delivery) {
+	ctx = context.WithValue(ctx, deliveryKey{}, delivery)
+	envelope, _ := delivery.Job.(*Envelope)
+
+	if envelope != nil && w.batchCancelled(ctx, envelope) {
+		// Jobs of a cancelled batch are skipped, still counting as processed
+		w.recordBatch(envelope, false)
+	} else if envelope != nil && w.exhausted(envelope) {
+		// Reservations lost by crashed consumers count as attempts, a job crashing them all runs out
+		err := fmt.Errorf("job %s ran out of attempts in consumers that stopped before settling it", envelope.Type)
+		logger.ReportError(fmt.Sprintf("queue worker %s job %s failed after %d attempts", w.name, envelope.Type, envelope.Attempts), err)
+		w.fail(envelope, err)
+		w.failedForGood(envelope)
+	} else if err := w.process(ctx, delivery); err != nil {
+		if w.handleError(delivery, err) {
+			return
+		}
+	} else if envelope != nil {
+		w.succeeded(envelope)
+	}
+
+	w.ack(delivery)
+}
+
+// process runs the job of a delivery, extending its reservation while it runs so
+// jobs outliving the visibility timeout of the connection are not delivered twice
+func (w *Worker) process(ctx context.Context, delivery *Delivery) error {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		w.keepReserved(delivery, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	return w.Process(ctx, delivery.Job)
+}
+
+// keepReserved extends the reservation of a delivery every third of the lease until stop is closed
+func (w *Worker) keepReserved(delivery *Delivery, stop <-chan struct{}) {
+	ticker := time.NewTicker(w.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := delivery.Extend(context.Background(), w.lease); err != nil {
+			logger.ReportError(fmt.Sprintf("queue worker %s failed to extend the reservation of job %T", w.name, delivery.Job), err)
+			if errors.Is(err, ErrReservationLost) {
+				return
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ack acknowledges a delivery, logging failures
+func (w *Worker) ack(delivery *Delivery) {
+	if err := delivery.Ack(context.Background()); err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s failed to acknowledge job %T", w.name, delivery.Job), err)
+	}
+}
+
+// handleError retries a failed job after its backoff, or records it once out of
+// attempts. It reports whether a pending retry now holds the delivery.
+func (w *Worker) handleError(delivery *Delivery, err error) bool {
+	envelope, ok := delivery.Job.(*Envelope)
+	if !ok {
+		// Jobs pushed without an envelope carry no attempt count and cannot be retried
+		logger.ReportError(fmt.Sprintf("queue worker %s job %T failed", w.name, delivery.Job), err)
+		w.fail(delivery.Job, err)
+		return false
+	}
+
+	envelope.Attempts++
+	tries, delay := w.policy(envelope)
+	if envelope.Attempts >= tries || IsPermanent(err) {
+		logger.ReportError(fmt.Sprintf("queue worker %s job %s failed after %d attempts", w.name, envelope.Type, envelope.Attempts), err)
+		w.fail(envelope, err)
+		w.failedForGood(envelope)
+		return false
+	}
+
+	logger.ReportError(fmt.Sprintf("queue worker %s job %s attempt %d failed, retrying in %s", w.name, envelope.Type, envelope.Attempts, delay), err)
+	return w.retry(delivery, envelope, delay)
+}
+
+// succeeded dispatches the next job of a chain and counts the job of a batch
+func (w *Worker) succeeded(envelope *Envelope) {
+	if next := envelope.next(); next != nil {
+		w.push(next)
+	}
+	w.recordBatch(envelope, false)
+}
+
+// failedForGood dispatches the catch job of a chain and counts the failed job of a batch
+func (w *Worker) failedForGood(envelope *Envelope) {
+	if envelope.ChainCatch != nil {
+		w.push(envelope.ChainCatch)
+	}
+	w.recordBatch(envelope, true)
+}
+
+// batchCancelled checks if the batch of a job was cancelled
+func (w *Worker) batchCancelled(ctx context.Context, envelope *Envelope) bool {
+	if envelope.BatchID == "" || w.batches == nil {
+		return false
+	}
+
+	state, err := w.batches.Find(ctx, envelope.BatchID)
+	if err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s failed to find batch %s", w.name, envelope.BatchID), err)
+		return false
+	}
+	return state.Cancelled()
+}
+
+// recordBatch counts a processed job of a batch and dispatches the callbacks it made due
+func (w *Worker) recordBatch(envelope *Envelope, failed bool) {
+	if envelope.BatchID == "" || w.batches == nil {
+		return
+	}
+
+	callbacks, err := recordBatchJob(context.Background(), w.batches, envelope, failed)
+	if err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s failed to update batch %s", w.name, envelope.BatchID), err)
+		return
+	}
+	for _, callback := range callbacks {
+		w.push(callback)
+	}
+}
+
+// exhausted checks if a job has no attempt left before it runs
+func (w *Worker) exhausted(envelope *Envelope) bool {
+	tries, _ := w.policy(envelope)
+	return envelope.Attempts >= tries
+}
+
+// policy returns the attempts allowed for a job and the delay before its next attempt
+func (w *Worker) policy(envelope *Envelope) (int, time.Duration) {
+	tries, delay := w.tries, w.backoff.Delay(envelope.Attempts)
+
+	job, err := envelope.Job()
+	if err != nil {
+		return tries, delay
+	}
+	if retryable, ok := job.(Retryable); ok {
+		if n := retryable.MaxAttempts(); n > 0 {
+			tries = n
+		}
+		if d := retryable.Backoff(envelope.Attempts); d >= 0 {
+			delay = d
+		}
+	}
+	return tries, delay
+}
+
+// retry pushes the envelope back once its delay elapsed, through the queue when it
+// supports delayed jobs. Otherwise the retry waits in process, holding the
+// reservation of the delivery until the envelope is pushed, and retry reports true.
+func (w *Worker) retry(delivery *Delivery, envelope *Envelope, delay time.Duration) bool {
+	if delayed, ok := w.queue.(DelayedQueue); ok && delay > 0 {
+		err := delayed.PushDelayed(context.Background(), w.queueName, envelope, time.Now().Add(delay))
+		if !errors.Is(err, ErrDelayNotSupported) {
+			if err != nil {
+				logger.ReportError(fmt.Sprintf("queue worker %s failed to retry job %s", w.name, envelope.Type), err)
+				w.fail(envelope, err)
+			}
+			return false
+		}
+	}
+
+	// The reservation must outlive the delay, a job delivered again meanwhile is not retried twice
+	if err := delivery.Extend(context.Background(), delay+retryGrace); err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s failed to hold job %s until its retry", w.name, envelope.Type), err)
+		if errors.Is(err, ErrReservationLost) {
+			return true
+		}
+	}
+
+	w.retryMu.Lock()
+	defer w.retryMu.Unlock()
+
+	pending := &pendingRetry{envelope: envelope, delivery: delivery, due: time.Now().Add(delay)}
+	pending.timer = time.AfterFunc(delay, func() {
+		w.retryMu.Lock()
+		_, ok := w.retries[envelope]
+		delete(w.retries, envelope)
+		w.retryMu.Unlock()
+
+		if ok {
+			w.release(pending)
+		}
+	})
+	w.retries[envelope] = pending
+	return true
+}
+
+// release pushes a pending retry, then acknowledges the delivery it held
+func (w *Worker) release(pending *pendingRetry) {
+	w.push(pending.envelope)
+	w.ack(pending.delivery)
+}
+
+// flushRetries waits up to the shutdown timeout for the retries still waiting on
+// their delay, then pushes the others early so a shutdown does not lose them
+func (w *Worker) flushRetries() {
+	w.retryMu.Lock()
+	retries := make([]*pendingRetry, 0, len(w.retries))
+	for _, pending := range w.retries {
+		pending.timer.Stop()
+		retries = append(retries, pending)
+	}
+	w.retries = make(map[*Envelope]*pendingRetry)
+	w.retryMu.Unlock()
+
+	slices.SortFunc(retries, func(a, b *pendingRetry) int {
+		return a.due.Compare(b.due)
+	})
+
+	deadline := time.Now().Add(w.shutdownTimeout)
+	for _, pending := range retries {
+		if pending.due.After(deadline) {
+			logger.ReportError(fmt.Sprintf("queue worker %s retrying job %s before its backoff elapsed", w.name, pending.envelope.Type), context.DeadlineExceeded)
+		} else {
+			time.Sleep(time.Until(pending.due))
+		}
+		w.release(pending)
+	}
+}
+
+// push pushes a job onto the queue of the worker, recording it as failed when that is not possible
+func (w *Worker) push(envelope *Envelope) {
+	if err := w.queue.Push(context.Background(), w.queueName, envelope); err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s failed to push job %s", w.name, envelope.Type), err)
+		w.fail(envelope, err)
+	}
+}
+
+// fail records a job in the failed job store
+func (w *Worker) fail(job Job, err error) {
+	if w.failed == nil {
+		return
+	}
+
+	envelope, ok := job.(*Envelope)
+	if !ok {
+		var encodeErr error
+		if envelope, encodeErr = NewEnvelope(job); encodeErr != nil {
+			logger.ReportError(fmt.Sprintf("queue worker %s cannot record failed job %T", w.name, job), encodeErr)
+			return
+		}
+	}
+
+	payload, encodeErr := envelope.Encode()
+	if encodeErr != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s cannot record failed job %s", w.name, envelope.Type), encodeErr)
+		return
+	}
+
+	failed := &FailedJob{
+		ID:         envelope.ID,
+		Connection: w.name,
+		Queue:      w.queueName,
+		Type:       envelope.Type,
+		Payload:    payload,
+		Error:      err.Error(),
+		FailedAt:   time.Now(),
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		failed.Stack = string(panicErr.Stack)
+	}
+
+	if err = w.failed.Record(context.Background(), failed); err != nil {
+		logger.ReportError(fmt.Sprintf("queue worker %s failed to record failed job %s", w.name, envelope.Type), err)
+	}
+}
+
+// pop waits up to the block timeout for the next job
+func (w *Worker) pop(ctx context.Context) (*Delivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.block)
+	defer cancel()
+
+	return w.queue.Pop(ctx, w.queueName)
+}
+
+// wait sleeps between polls unless ctx is done
+func (w *Worker) wait(ctx context.Context) {
+	timer := time.NewTimer(w.sleep)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// Process runs a job with its timeout, turning a panic into an error
+func (w *Worker) Process(ctx context.Context, job Job) (err error) {
+	timeout := w.timeout
+	if t, ok := job.(Timeouter); ok && t.Timeout() > 0 {
+		timeout = t.Timeout()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return job.Handle(ctx)
+}
+
+// RunWorkers runs a worker per configuration until ctx is done and all of them drained
+func RunWorkers(ctx context.Context, manager *Manager, configs []WorkerConfig) error {
+	workers := make([]*Worker, 0, len(configs))
+	for _, cfg := range configs {
+		name := cfg.Connection
+		if name == "" {
+			name = manager.DefaultName()
+		}
+
+		queue, err := manager.Connection(name)
+		if err != nil {
+			return err
+		}
+		worker := NewWorker(name, queue, cfg).
+			WithFailedStore(manager.FailedStore()).
+			WithBatchRepository(manager.BatchRepository())
+		workers = append(workers, worker)
+	}
+
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.Run(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sliceQueue struct {
+	mu   sync.Mutex
+	jobs []Job
+}
+
+func (q *sliceQueue) Push(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func (q *sliceQueue) Pop() (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.jobs) == 0 {
+		return nil, nil
+	}
+	job := q.jobs[0]
+	q.jobs = q.jobs[1:]
+	return job, nil
+}
+
+func (q *sliceQueue) Size() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs), nil
+}
+
+type funcJob func(ctx context.Context) error
+
+func (f funcJob) Handle(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestWorker(t *testing.T) {
+	q := &sliceQueue{}
+	started := make(chan struct{}, 6)
+	var done atomic.Int32
+	for i := 0; i < 5; i++ {
+		_ = q.Push(funcJob(func(ctx context.Context) error {
+			started <- struct{}{}
+			time.Sleep(50 * time.Millisecond)
+			done.Add(1)
+			return nil
+		}))
+	}
+	_ = q.Push(funcJob(func(ctx context.Context) error {
+		started <- struct{}{}
+		panic("boom")
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		NewWorker("test", Adapt(q, 10*time.Millisecond), WorkerConfig{Concurrency: 6}).Run(ctx)
+		close(stopped)
+	}()
+
+	// Shutting down while jobs run lets them finish, a panic does not stop the worker
+	for i := 0; i < 6; i++ {
+		<-started
+	}
+	cancel()
+	<-stopped
+
+	if done.Load() != 5 {
+		t.Fatalf("expected in-flight jobs to drain, %d done", done.Load())
+	}
+	if size, _ := q.Size(); size != 0 {
+		t.Fatalf("expected the queue to be consumed, %d left", size)
+	}
+}
+
+func TestWorkerProcessRecoversPanics(t *testing.T) {
+	w := NewWorker("test", Adapt(&sliceQueue{}, 10*time.Millisecond), WorkerConfig{})
+	err := w.Process(context.Background(), funcJob(func(ctx context.Context) error {
+		panic("boom")
+	}))
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a PanicError, got %v", err)
+	}
+	if panicErr.Value != "boom" || !strings.Contains(string(panicErr.Stack), "worker_test.go") {
+		t.Fatalf("unexpected panic %v\n%s", panicErr.Value, panicErr.Stack)
+	}
+}
+
+func TestWorkerExtendsRunningJobs(t *testing.T) {
+	w := NewWorker("test", Adapt(&sliceQueue{}, 10*time.Millisecond), WorkerConfig{})
+	w.lease = 30 * time.Millisecond
+
+	reservation := &recordingReservation{}
+	envelope := &Envelope{Type: "test.slow", job: funcJob(func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})}
+	w.handle(context.Background(), NewDelivery(envelope, "", reservation))
+
+	reservation.mu.Lock()
+	defer reservation.mu.Unlock()
+	if reservation.extends < 3 || reservation.extended != w.lease || !reservation.acked {
+		t.Fatalf("expected the reservation to be extended while the job ran, got %d extensions of %s", reservation.extends, reservation.extended)
+	}
+}